@@ -0,0 +1,338 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/rbac"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// versionColumns are the columns shared by the records and record_history tables
+var versionColumns = []string{
+	"cluster_name",
+	"instance_id",
+	"application_name",
+	"application_instance",
+	"application_version",
+}
+
+// recordColumns are the columns of the records table that may be used for filtering and sorting
+var recordColumns = append(append([]string{}, versionColumns...),
+	"sync_status",
+	"health_status",
+	"revision",
+	"target_revision",
+	"repo_url",
+	"chart_name",
+	"destination_namespace",
+)
+
+// historyFilterColumns are the columns of the record_history table that may be used for filtering,
+// the times are filtered with the since and until parameters instead
+var historyFilterColumns = append([]string{"action"}, versionColumns...)
+
+// historyColumns are the columns of the record_history table that may be used for sorting
+var historyColumns = append([]string{"observed_at", "event_time"}, historyFilterColumns...)
+
+// Page is the envelope returned by all paginated API endpoints
+type Page struct {
+	Items  interface{} `json:"items"`
+	Total  int64       `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// pagination holds the parsed limit, offset and sort parameters of a request
+type pagination struct {
+	limit  int
+	offset int
+	order  []clause.OrderByColumn
+}
+
+// HandleListRecords returns a filtered, sorted and paginated list of records
+func (s *Server) HandleListRecords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, recordColumns, strings.Join(models.RecordKeyColumns, ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := s.readScope(r, filterQuery(s.database.WithContext(r.Context()).Model(&models.Record{}), params, recordColumns))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.WithError(err).Error("failed to count records")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	records := []models.Record{}
+	if err := page.apply(query).Find(&records).Error; err != nil {
+		logrus.WithError(err).Error("failed to list records")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page{
+		Items:  records,
+		Total:  total,
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// HandleGetRecord returns a single record by its unique key together with its images
+//
+// The cluster and application name are taken from the path
+// (/api/v1/records/{cluster_name}/{application_name}), the optional
+// instance_id and application_instance parts of the key from the query.
+func (s *Server) HandleGetRecord(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/records/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "expected /api/v1/records/{cluster_name}/{application_name}", http.StatusNotFound)
+		return
+	}
+	params := r.URL.Query()
+
+	key := models.RecordKey{
+		ClusterName:         parts[0],
+		InstanceId:          params.Get("instance_id"),
+		ApplicationName:     parts[1],
+		ApplicationInstance: params.Get("application_instance"),
+	}
+	query, err := s.readScope(r, s.database.WithContext(r.Context()))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+	record := &models.Record{}
+	err = query.Preload("Images").Where(key.Conditions()).Take(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "record not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("failed to get record")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record)
+}
+
+// HandleListHistory returns the version history of records, newest transitions first
+//
+// Besides the record columns the history may be limited to a time range
+// with the since and until parameters in RFC 3339 format.
+func (s *Server) HandleListHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, historyColumns, "-observed_at")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := s.readScope(r, filterQuery(s.database.WithContext(r.Context()).Model(&models.RecordHistory{}), params, historyFilterColumns))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query history", http.StatusInternalServerError)
+		return
+	}
+	for param, condition := range map[string]string{"since": "observed_at >= ?", "until": "observed_at < ?"} {
+		if value := params.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, param+" must be a RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			query = query.Where(condition, t)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.WithError(err).Error("failed to count history")
+		http.Error(w, "failed to query history", http.StatusInternalServerError)
+		return
+	}
+
+	history := []models.RecordHistory{}
+	if err := page.apply(query).Find(&history).Error; err != nil {
+		logrus.WithError(err).Error("failed to list history")
+		http.Error(w, "failed to query history", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page{
+		Items:  history,
+		Total:  total,
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// filterQuery adds an equality condition for each allowed column present in the query parameters
+func filterQuery(query *gorm.DB, params url.Values, columns []string) *gorm.DB {
+	for _, column := range columns {
+		if values, ok := params[column]; ok {
+			query = query.Where(clause.IN{Column: clause.Column{Name: column}, Values: toInterfaces(values)})
+		}
+	}
+	return query
+}
+
+// readScope limits a query to the clusters the identity of the request may read
+//
+// The query must be on a table with a cluster_name column. Requests without a
+// scoped identity are not limited.
+func (s *Server) readScope(r *http.Request, query *gorm.DB) (*gorm.DB, error) {
+	identity, ok := r.Context().Value(types.ContextIdentity).(*rbac.Identity)
+	if !ok || !identity.Scoped() {
+		return query, nil
+	}
+	readable, err := s.readableClusters(r, identity)
+	if err != nil {
+		return nil, err
+	}
+	return query.Where(clause.IN{Column: clause.Column{Name: "cluster_name"}, Values: toInterfaces(readable)}), nil
+}
+
+// readableClusters returns the clusters listed in an identity and the managed clusters matching its labels
+func (s *Server) readableClusters(r *http.Request, identity *rbac.Identity) ([]string, error) {
+	readable := append([]string{}, identity.Clusters...)
+	if len(identity.Labels) == 0 || s.clusters == nil {
+		return readable, nil
+	}
+	managed, err := s.clusters.List(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range managed {
+		if identity.CanRead(cluster.Name, cluster.Labels) && !contains(readable, cluster.Name) {
+			readable = append(readable, cluster.Name)
+		}
+	}
+	return readable, nil
+}
+
+// parsePagination reads the limit, offset and sort query parameters
+//
+// sort takes a comma separated list of columns, a leading '-' sorts descending.
+func parsePagination(params url.Values, columns []string, defaultSort string) (*pagination, error) {
+	page := &pagination{limit: defaultPageLimit}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return nil, errors.New("limit must be a number between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		page.limit = limit
+	}
+	if value := params.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return nil, errors.New("offset must be a positive number")
+		}
+		page.offset = offset
+	}
+
+	sort := params.Get("sort")
+	if sort == "" {
+		sort = defaultSort
+	}
+	for _, field := range strings.Split(sort, ",") {
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+		if !contains(columns, field) {
+			return nil, errors.New("cannot sort by " + field)
+		}
+		page.order = append(page.order, clause.OrderByColumn{Column: clause.Column{Name: field}, Desc: desc})
+	}
+
+	return page, nil
+}
+
+// apply adds the pagination to a query
+func (p *pagination) apply(query *gorm.DB) *gorm.DB {
+	for _, order := range p.order {
+		query = query.Order(order)
+	}
+	return query.Limit(p.limit).Offset(p.offset)
+}
+
+// bounds returns the slice bounds of the page for results that are paginated in memory
+func (p *pagination) bounds(length int) (int, int) {
+	start := p.offset
+	if start > length {
+		start = length
+	}
+	end := start + p.limit
+	if end > length {
+		end = length
+	}
+	return start, end
+}
+
+// writeJSON encodes a value as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logrus.WithError(err).Error("error encoding response")
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// deadLetterColumns are the columns of the dead_letters table that may be used for filtering and sorting
+var deadLetterColumns = []string{"id", "created_at", "cluster_name", "event_id", "event_type", "attempts"}
+
+// HandleListDeadLetters returns the events that could not be stored
+//
+// Besides the columns the list can be filtered by replayed=true or replayed=false.
+func (s *Server) HandleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, deadLetterColumns, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := filterQuery(s.database.WithContext(r.Context()).Model(&models.DeadLetter{}), params, deadLetterColumns)
+	if value := params.Get("replayed"); value != "" {
+		replayed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "replayed must be a boolean", http.StatusBadRequest)
+			return
+		}
+		if replayed {
+			query = query.Where("replayed_at IS NOT NULL")
+		} else {
+			query = query.Where("replayed_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.WithError(err).Error("failed to count dead letters")
+		http.Error(w, "failed to query dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	letters := []models.DeadLetter{}
+	if err := page.apply(query).Find(&letters).Error; err != nil {
+		logrus.WithError(err).Error("failed to list dead letters")
+		http.Error(w, "failed to query dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page{
+		Items:  letters,
+		Total:  total,
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// HandleReplayDeadLetter stores a dead-lettered event again (POST /api/v1/admin/deadletters/{id}/replay)
+func (s *Server) HandleReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/deadletters/"), "/")
+	if len(parts) != 2 || parts[1] != "replay" {
+		http.Error(w, "expected /api/v1/admin/deadletters/{id}/replay", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid dead letter id", http.StatusNotFound)
+		return
+	}
+
+	letter := &models.DeadLetter{}
+	err = s.database.WithContext(r.Context()).Take(letter, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("failed to get dead letter")
+		http.Error(w, "failed to query dead letters", http.StatusInternalServerError)
+		return
+	}
+	if letter.ReplayedAt != nil {
+		http.Error(w, "dead letter has already been replayed", http.StatusConflict)
+		return
+	}
+
+	err = s.handler.Replay(letter)
+	if errors.Is(err, ErrStaleDeadLetter) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("id", letter.ID).Error("failed to replay dead letter")
+		http.Error(w, "failed to replay dead letter: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, letter)
+}
@@ -0,0 +1,79 @@
+package app_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func Test_HandleDeadLetters(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleDeadLetters")
+	data := eventStub(&models.Record{
+		ClusterName:        "cluster",
+		ApplicationName:    "app",
+		ApplicationVersion: "1.0.0",
+	})
+	data.Event.SetID("1")
+	data.Event.SetSource("test")
+	letter, err := models.NewDeadLetter(data, errors.New("database is down"), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(letter).Error; err != nil {
+		t.Fatalf("failed to seed database: %v", err)
+	}
+
+	s := mopsos.NewServer(&mopsos.Config{}).
+		WithDatabase(gdb).
+		WithHandler(mopsos.NewHandler(false, gdb))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/admin/deadletters?replayed=false", nil)
+	res := httptest.NewRecorder()
+	s.HandleListDeadLetters(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.Code)
+	}
+	page := struct {
+		Items []models.DeadLetter `json:"items"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Error != "database is down" {
+		t.Fatalf("expected the dead letter, got %+v", page.Items)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"wrong method", http.MethodGet, "/api/v1/admin/deadletters/1/replay", http.StatusMethodNotAllowed},
+		{"unknown dead letter", http.MethodPost, "/api/v1/admin/deadletters/42/replay", http.StatusNotFound},
+		{"invalid path", http.MethodPost, "/api/v1/admin/deadletters/1", http.StatusNotFound},
+		{"replay", http.MethodPost, "/api/v1/admin/deadletters/1/replay", http.StatusOK},
+		{"replay twice", http.MethodPost, "/api/v1/admin/deadletters/1/replay", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+			res := httptest.NewRecorder()
+			s.HandleReplayDeadLetter(res, req)
+			if res.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+		})
+	}
+
+	var records int64
+	gdb.Model(&models.Record{}).Count(&records)
+	if records != 1 {
+		t.Errorf("expected the replayed record to be stored, got %d records", records)
+	}
+}
@@ -0,0 +1,204 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/clusters"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// defaultRotationGrace is how long the previous token stays valid after a rotation
+const defaultRotationGrace = 24 * time.Hour
+
+// clusterColumns are the columns of the clusters table that may be used for filtering and sorting
+var clusterColumns = []string{"id", "created_at", "name"}
+
+// ClusterToken is returned once when a cluster is added or its token rotated
+type ClusterToken struct {
+	Cluster *models.Cluster `json:"cluster,omitempty"`
+	Token   string          `json:"token"`
+}
+
+type addClusterRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+}
+
+type rotateClusterRequest struct {
+	// Grace is a duration like 1h, defaults to 24h
+	Grace string `json:"grace"`
+}
+
+type revokeClusterRequest struct {
+	// TokenID is the token to revoke, all tokens are revoked if it is 0
+	TokenID uint `json:"token_id"`
+}
+
+// HandleClusters lists (GET) and adds (POST) clusters on /api/v1/admin/clusters
+//
+// The token of a new cluster is only part of the response, it can't be retrieved later.
+func (s *Server) HandleClusters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		params := r.URL.Query()
+		page, err := parsePagination(params, clusterColumns, "name")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := filterQuery(s.database.WithContext(r.Context()).Model(&models.Cluster{}), params, clusterColumns)
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			logrus.WithError(err).Error("failed to count clusters")
+			http.Error(w, "failed to query clusters", http.StatusInternalServerError)
+			return
+		}
+
+		list := []models.Cluster{}
+		if err := page.apply(query).Preload("Tokens").Find(&list).Error; err != nil {
+			logrus.WithError(err).Error("failed to list clusters")
+			http.Error(w, "failed to query clusters", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, Page{
+			Items:  list,
+			Total:  total,
+			Limit:  page.limit,
+			Offset: page.offset,
+		})
+
+	case http.MethodPost:
+		request := addClusterRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		cluster, token, err := s.clusters.Add(r.Context(), request.Name, request.Description, request.Labels)
+		if err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		logrus.WithField("cluster", cluster.Name).Info("added cluster")
+		writeJSON(w, http.StatusCreated, ClusterToken{Cluster: cluster, Token: token})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleCluster manages a single cluster
+//
+//	GET   /api/v1/admin/clusters/{name}         returns the cluster
+//	PATCH /api/v1/admin/clusters/{name}         updates description, labels or enabled
+//	POST  /api/v1/admin/clusters/{name}/rotate  adds a new token, the previous one stays valid for the grace period
+//	POST  /api/v1/admin/clusters/{name}/revoke  revokes a token or all tokens
+func (s *Server) HandleCluster(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/clusters/"), "/")
+	if parts[0] == "" || len(parts) > 2 {
+		http.Error(w, "expected /api/v1/admin/clusters/{name}", http.StatusNotFound)
+		return
+	}
+	name := parts[0]
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		cluster, err := s.clusters.Get(r.Context(), name)
+		if err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, cluster)
+
+	case action == "" && r.Method == http.MethodPatch:
+		update := clusters.Update{}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cluster, err := s.clusters.Update(r.Context(), name, update)
+		if err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, cluster)
+
+	case action == "rotate" && r.Method == http.MethodPost:
+		request := rotateClusterRequest{}
+		if err := decodeOptional(r, &request); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		grace := defaultRotationGrace
+		if request.Grace != "" {
+			var err error
+			if grace, err = time.ParseDuration(request.Grace); err != nil || grace < 0 {
+				http.Error(w, "grace must be a positive duration like 24h", http.StatusBadRequest)
+				return
+			}
+		}
+		token, err := s.clusters.Rotate(r.Context(), name, grace)
+		if err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		logrus.WithField("cluster", name).Info("rotated cluster token")
+		writeJSON(w, http.StatusOK, ClusterToken{Token: token})
+
+	case action == "revoke" && r.Method == http.MethodPost:
+		request := revokeClusterRequest{}
+		if err := decodeOptional(r, &request); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.clusters.Revoke(r.Context(), name, request.TokenID); err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"cluster": name, "token": request.TokenID}).Info("revoked cluster token")
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "" || action == "rotate" || action == "revoke":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		http.Error(w, "unknown cluster action", http.StatusNotFound)
+	}
+}
+
+// decodeOptional decodes a JSON request body that may be empty
+func decodeOptional(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func writeClusterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, clusters.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, clusters.ErrExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logrus.WithError(err).Error("failed to manage cluster")
+		http.Error(w, "failed to manage cluster", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,92 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/clusters"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func Test_HandleClusters(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleClusters")
+	store := clusters.NewStore(gdb)
+	s := mopsos.NewServer(&mopsos.Config{}).WithDatabase(gdb).WithClusters(store)
+
+	// add a cluster and keep its token
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/admin/clusters",
+		strings.NewReader(`{"name":"cluster-a","description":"production","labels":{"env":"prod"}}`))
+	res := httptest.NewRecorder()
+	s.HandleClusters(res, req)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", res.Code, res.Body.String())
+	}
+	added := mopsos.ClusterToken{}
+	if err := json.NewDecoder(res.Body).Decode(&added); err != nil {
+		t.Fatal(err)
+	}
+	if added.Token == "" || added.Cluster.Labels["env"] != "prod" {
+		t.Fatalf("unexpected response %+v", added)
+	}
+	if strings.Contains(res.Body.String(), "sha256") {
+		t.Error("token hashes must not be returned")
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"add existing cluster", http.MethodPost, "/api/v1/admin/clusters", `{"name":"cluster-a"}`, http.StatusConflict},
+		{"add without name", http.MethodPost, "/api/v1/admin/clusters", `{}`, http.StatusBadRequest},
+		{"list", http.MethodGet, "/api/v1/admin/clusters?name=cluster-a", "", http.StatusOK},
+		{"list with invalid sort", http.MethodGet, "/api/v1/admin/clusters?sort=labels", "", http.StatusBadRequest},
+		{"get", http.MethodGet, "/api/v1/admin/clusters/cluster-a", "", http.StatusOK},
+		{"get unknown", http.MethodGet, "/api/v1/admin/clusters/cluster-b", "", http.StatusNotFound},
+		{"update", http.MethodPatch, "/api/v1/admin/clusters/cluster-a", `{"description":"staging"}`, http.StatusOK},
+		{"rotate", http.MethodPost, "/api/v1/admin/clusters/cluster-a/rotate", `{"grace":"1h"}`, http.StatusOK},
+		{"rotate with invalid grace", http.MethodPost, "/api/v1/admin/clusters/cluster-a/rotate", `{"grace":"soon"}`, http.StatusBadRequest},
+		{"rotate with wrong method", http.MethodGet, "/api/v1/admin/clusters/cluster-a/rotate", "", http.StatusMethodNotAllowed},
+		{"unknown action", http.MethodPost, "/api/v1/admin/clusters/cluster-a/delete", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.path, strings.NewReader(tt.body))
+			res := httptest.NewRecorder()
+			if strings.HasPrefix(tt.path, "/api/v1/admin/clusters/") {
+				s.HandleCluster(res, req)
+			} else {
+				s.HandleClusters(res, req)
+			}
+			if res.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+		})
+	}
+
+	// the first token stays valid during rotation and is gone once revoked
+	if !store.Verify(req.Context(), "cluster-a", added.Token) {
+		t.Error("expected the first token to be valid during rotation")
+	}
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/admin/clusters/cluster-a/revoke", nil)
+	res = httptest.NewRecorder()
+	s.HandleCluster(res, req)
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", res.Code)
+	}
+	if store.Verify(req.Context(), "cluster-a", added.Token) {
+		t.Error("expected the token to be revoked")
+	}
+
+	cluster := &models.Cluster{}
+	gdb.Where("name = ?", "cluster-a").Take(cluster)
+	if cluster.Description != "staging" || cluster.Labels["env"] != "prod" {
+		t.Errorf("expected only the description to be updated, got %+v", cluster)
+	}
+}
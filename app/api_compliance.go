@@ -0,0 +1,118 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// HandleRules lists (GET) and adds (POST) compliance rules on /api/v1/admin/rules
+//
+// Adding a rule evaluates it against all records before the response is sent.
+func (s *Server) HandleRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := s.compliance.Rules(r.Context())
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rules)
+
+	case http.MethodPost:
+		rule := models.ComplianceRule{}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := s.compliance.Rule(r.Context(), rule.Name); err == nil {
+			http.Error(w, "rule "+rule.Name+" already exists", http.StatusConflict)
+			return
+		}
+		saved, err := s.compliance.SaveRule(r.Context(), rule)
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		logrus.WithField("rule", saved.Name).Info("added compliance rule")
+		writeJSON(w, http.StatusCreated, saved)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleRule manages a single compliance rule
+//
+//	GET    /api/v1/admin/rules/{name}  returns the rule
+//	PUT    /api/v1/admin/rules/{name}  adds or replaces the rule
+//	DELETE /api/v1/admin/rules/{name}  removes the rule and its results
+//
+// Rules loaded from the rules file can't be changed using the API.
+func (s *Server) HandleRule(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/rules/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "expected /api/v1/admin/rules/{name}", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := s.compliance.Rule(r.Context(), name)
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+
+	case http.MethodPut:
+		rule := models.ComplianceRule{}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rule.Name != "" && rule.Name != name {
+			http.Error(w, "name must match the path", http.StatusBadRequest)
+			return
+		}
+		rule.Name = name
+		saved, err := s.compliance.SaveRule(r.Context(), rule)
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		logrus.WithField("rule", saved.Name).Info("saved compliance rule")
+		writeJSON(w, http.StatusOK, saved)
+
+	case http.MethodDelete:
+		if err := s.compliance.DeleteRule(r.Context(), name); err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		logrus.WithField("rule", name).Info("deleted compliance rule")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, compliance.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, compliance.ErrInvalidRule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, compliance.ErrReadOnly):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logrus.WithError(err).Error("failed to manage compliance rule")
+		http.Error(w, "failed to manage compliance rule", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,82 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func Test_HandleRules(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleRules")
+	for i := range apiRecords {
+		record := apiRecords[i]
+		if err := gdb.Create(&record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := compliance.NewEngine(gdb)
+	if err := engine.SyncFile(context.Background(), []models.ComplianceRule{{Name: "from-file", Version: ">=1.0.0"}}); err != nil {
+		t.Fatal(err)
+	}
+	s := mopsos.NewServer(&mopsos.Config{}).WithDatabase(gdb).WithCompliance(engine)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"add", http.MethodPost, "/api/v1/admin/rules", `{"name":"cert-manager","applications":["cert-manager"],"version":">=1.9"}`, http.StatusCreated},
+		{"add existing rule", http.MethodPost, "/api/v1/admin/rules", `{"name":"cert-manager","version":">=1.0"}`, http.StatusConflict},
+		{"add invalid rule", http.MethodPost, "/api/v1/admin/rules", `{"name":"broken","version":">= one"}`, http.StatusBadRequest},
+		{"list", http.MethodGet, "/api/v1/admin/rules", "", http.StatusOK},
+		{"get", http.MethodGet, "/api/v1/admin/rules/cert-manager", "", http.StatusOK},
+		{"get unknown", http.MethodGet, "/api/v1/admin/rules/unknown", "", http.StatusNotFound},
+		{"replace", http.MethodPut, "/api/v1/admin/rules/nginx", `{"applications":["ingress-nginx"],"version":">=4.3"}`, http.StatusOK},
+		{"replace with other name", http.MethodPut, "/api/v1/admin/rules/nginx", `{"name":"other","version":">=4.3"}`, http.StatusBadRequest},
+		{"replace file rule", http.MethodPut, "/api/v1/admin/rules/from-file", `{"version":">=2.0"}`, http.StatusConflict},
+		{"delete file rule", http.MethodDelete, "/api/v1/admin/rules/from-file", "", http.StatusConflict},
+		{"delete unknown", http.MethodDelete, "/api/v1/admin/rules/unknown", "", http.StatusNotFound},
+		{"wrong method", http.MethodPatch, "/api/v1/admin/rules/nginx", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.path, strings.NewReader(tt.body))
+			res := httptest.NewRecorder()
+			if strings.HasPrefix(tt.path, "/api/v1/admin/rules/") {
+				s.HandleRule(res, req)
+			} else {
+				s.HandleRules(res, req)
+			}
+			if res.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+		})
+	}
+
+	rules := []models.ComplianceRule{}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/admin/rules", nil)
+	res := httptest.NewRecorder()
+	s.HandleRules(res, req)
+	if err := json.NewDecoder(res.Body).Decode(&rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[1].Source != models.RuleSourceFile || rules[2].Name != "nginx" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "http://example.com/api/v1/admin/rules/nginx", nil)
+	res = httptest.NewRecorder()
+	s.HandleRule(res, req)
+	if res.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", res.Code)
+	}
+}
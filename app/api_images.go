@@ -0,0 +1,100 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/images"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+// imageColumns are the columns of images joined with their records that may be used for filtering and sorting
+var imageColumns = append([]string{"registry", "repository", "tag", "digest"}, models.RecordKeyColumns...)
+
+// ImageUsage is an image run by the application of a record
+type ImageUsage struct {
+	ClusterName         string `json:"cluster_name"`
+	InstanceId          string `json:"instance_id"`
+	ApplicationName     string `json:"application_name"`
+	ApplicationInstance string `json:"application_instance"`
+	Registry            string `json:"registry"`
+	Repository          string `json:"repository"`
+	Tag                 string `json:"tag"`
+	Digest              string `json:"digest,omitempty"`
+}
+
+// HandleListImages returns the images run by the applications of all records
+//
+// Besides the image and record columns the images can be filtered by a
+// reference with the image parameter, i.e. image=nginx matches
+// docker.io/library/nginx with any tag, and by tag_below, which limits the
+// result to images with a tag older than the given version.
+func (s *Server) HandleListImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, imageColumns, "registry,repository,tag,cluster_name")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var below *version.Version
+	if value := params.Get("tag_below"); value != "" {
+		if below, err = version.Parse(value); err != nil {
+			http.Error(w, "tag_below: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := s.database.WithContext(r.Context()).Table("images").
+		Select("records.cluster_name, records.instance_id, records.application_name, records.application_instance, " +
+			"images.registry, images.repository, images.tag, images.digest").
+		Joins("JOIN records ON records.id = images.record_id AND records.deleted_at IS NULL")
+	if value := params.Get("image"); value != "" {
+		ref, err := images.Parse(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = query.Where("images.registry = ? AND images.repository = ?", ref.Registry, ref.Repository)
+	}
+	query, err = s.readScope(r, filterQuery(query, params, imageColumns))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query images", http.StatusInternalServerError)
+		return
+	}
+	for _, order := range page.order {
+		query = query.Order(order)
+	}
+	rows := []ImageUsage{}
+	if err := query.Scan(&rows).Error; err != nil {
+		logrus.WithError(err).Error("failed to list images")
+		http.Error(w, "failed to query images", http.StatusInternalServerError)
+		return
+	}
+
+	usages := rows
+	if below != nil {
+		// tags are compared as versions, tags that are no version like latest never match
+		usages = []ImageUsage{}
+		for _, usage := range rows {
+			if tag, err := version.Parse(usage.Tag); err == nil && version.Compare(tag, below) < 0 {
+				usages = append(usages, usage)
+			}
+		}
+	}
+
+	start, end := page.bounds(len(usages))
+	writeJSON(w, http.StatusOK, Page{
+		Items:  usages[start:end],
+		Total:  int64(len(usages)),
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
@@ -0,0 +1,91 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func Test_HandleListImages(t *testing.T) {
+	controller := func(tag string) models.Image {
+		return models.Image{Registry: "quay.io", Repository: "jetstack/cert-manager-controller", Tag: tag}
+	}
+	s := newAPIServer(t, "Test_HandleListImages",
+		models.Record{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "1.9.1", Images: []models.Image{controller("v1.9.1")}},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "1.8.0", Images: []models.Image{controller("v1.8.0")}},
+		models.Record{ClusterName: "cluster-c", ApplicationName: "cert-manager", ApplicationVersion: "1.7.2", Images: []models.Image{controller("latest")}},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "proxy", ApplicationVersion: "1.0.0", Images: []models.Image{
+			{Registry: "docker.io", Repository: "library/nginx", Tag: "1.23.1-alpine"},
+		}},
+	)
+
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantClusters []string
+	}{
+		{
+			name:         "all images",
+			query:        "",
+			wantStatus:   http.StatusOK,
+			wantClusters: []string{"cluster-b", "cluster-c", "cluster-b", "cluster-a"},
+		},
+		{
+			name:         "image with tag below",
+			query:        "?image=quay.io/jetstack/cert-manager-controller&tag_below=1.9.0",
+			wantStatus:   http.StatusOK,
+			wantClusters: []string{"cluster-b"},
+		},
+		{
+			name:         "normalized image reference",
+			query:        "?image=nginx",
+			wantStatus:   http.StatusOK,
+			wantClusters: []string{"cluster-b"},
+		},
+		{
+			name:         "filter by record column",
+			query:        "?cluster_name=cluster-a",
+			wantStatus:   http.StatusOK,
+			wantClusters: []string{"cluster-a"},
+		},
+		{
+			name:       "invalid tag_below",
+			query:      "?tag_below=latest",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/images"+tt.query, nil)
+			res := httptest.NewRecorder()
+
+			s.HandleListImages(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			page := struct {
+				Items []mopsos.ImageUsage `json:"items"`
+			}{}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(page.Items) != len(tt.wantClusters) {
+				t.Fatalf("expected %d items, got %+v", len(tt.wantClusters), page.Items)
+			}
+			for i, cluster := range tt.wantClusters {
+				if page.Items[i].ClusterName != cluster {
+					t.Errorf("item %d: expected cluster %s, got %+v", i, cluster, page.Items[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// RecordSupport is a record annotated with the support status of its version
+type RecordSupport struct {
+	models.Record
+	lifecycle.Support
+}
+
+// recordCycleOfRecord joins the stored cycle of a record
+const recordCycleOfRecord = "SELECT 1 FROM record_cycles WHERE " +
+	"record_cycles.cluster_name = records.cluster_name AND record_cycles.instance_id = records.instance_id AND " +
+	"record_cycles.application_name = records.application_name AND record_cycles.application_instance = records.application_instance"
+
+// supportConditions match the records of each support status as of @today, records without stored cycle are unknown
+var supportConditions = map[lifecycle.Status]string{
+	lifecycle.StatusSupported: "EXISTS (" + recordCycleOfRecord + " AND NOT record_cycles.eol_reached AND " +
+		"(record_cycles.eol_date IS NULL OR record_cycles.eol_date >= @today))",
+	lifecycle.StatusEOL:     "EXISTS (" + recordCycleOfRecord + " AND (record_cycles.eol_reached OR record_cycles.eol_date < @today))",
+	lifecycle.StatusUnknown: "NOT EXISTS (" + recordCycleOfRecord + ")",
+}
+
+// HandleListLifecycle returns records with the support status of their version
+//
+// Records can be filtered like in HandleListRecords and by support_status
+// (supported, eol or unknown). The cycles are stored whenever a record is
+// written or the lifecycle dataset is imported, the status is derived from
+// their end of life as of today.
+func (s *Server) HandleListLifecycle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, recordColumns, strings.Join(models.RecordKeyColumns, ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := s.readScope(r, filterQuery(s.database.WithContext(r.Context()).Model(&models.Record{}), params, recordColumns))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	if values, ok := params["support_status"]; ok {
+		conditions := []string{}
+		for _, value := range values {
+			condition, ok := supportConditions[lifecycle.Status(value)]
+			if !ok {
+				http.Error(w, "invalid support_status: "+value, http.StatusBadRequest)
+				return
+			}
+			conditions = append(conditions, condition)
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", map[string]interface{}{"today": lifecycle.Today(now)})
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.WithError(err).Error("failed to count records")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+	records := []models.Record{}
+	if err := page.apply(query).Find(&records).Error; err != nil {
+		logrus.WithError(err).Error("failed to list records")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	cycles, err := s.recordCycles(r, records)
+	if err != nil {
+		logrus.WithError(err).Error("failed to list record cycles")
+		http.Error(w, "failed to query record cycles", http.StatusInternalServerError)
+		return
+	}
+	items := make([]RecordSupport, len(records))
+	for i, record := range records {
+		var cycle *models.ProductCycle
+		if stored, ok := cycles[record.Key()]; ok {
+			cycle = &models.ProductCycle{
+				Product:    stored.Product,
+				Cycle:      stored.Cycle,
+				EOLDate:    stored.EOLDate,
+				EOLReached: stored.EOLReached,
+			}
+		}
+		items[i] = RecordSupport{Record: record, Support: lifecycle.SupportOf(cycle, now)}
+	}
+
+	writeJSON(w, http.StatusOK, Page{
+		Items:  items,
+		Total:  total,
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// recordCycles returns the stored cycles of records by their key
+func (s *Server) recordCycles(r *http.Request, records []models.Record) (map[models.RecordKey]models.RecordCycle, error) {
+	stored := make(map[models.RecordKey]models.RecordCycle, len(records))
+	if len(records) == 0 {
+		return stored, nil
+	}
+	clusters := make([]string, len(records))
+	applications := make([]string, len(records))
+	for i, record := range records {
+		clusters[i] = record.ClusterName
+		applications[i] = record.ApplicationName
+	}
+	rows := []models.RecordCycle{}
+	err := s.database.WithContext(r.Context()).
+		Where("cluster_name IN ? AND application_name IN ?", clusters, applications).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		key := models.RecordKey{
+			ClusterName:         row.ClusterName,
+			InstanceId:          row.InstanceId,
+			ApplicationName:     row.ApplicationName,
+			ApplicationInstance: row.ApplicationInstance,
+		}
+		stored[key] = row
+	}
+	return stored, nil
+}
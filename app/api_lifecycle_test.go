@@ -0,0 +1,110 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+)
+
+// importCertManagerCycles imports a supported 1.9 and an ended 1.8 cycle of cert-manager and matches the records to them
+func importCertManagerCycles(t *testing.T, gdb *gorm.DB) {
+	eol := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := lifecycle.Import(context.Background(), gdb, map[string][]lifecycle.Cycle{
+		"cert-manager": {
+			{Cycle: "1.9", EOL: lifecycle.EOL{Date: &eol}},
+			{Cycle: "1.8", EOL: lifecycle.EOL{Ended: true}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lifecycle.NewChecker(gdb, nil).EvaluateAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_HandleListLifecycle(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleListLifecycle", apiRecords...)
+	importCertManagerCycles(t, newTestDB(t, "Test_HandleListLifecycle"))
+
+	tests := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantStatus []lifecycle.Status
+	}{
+		{
+			name:       "all records",
+			query:      "",
+			wantStatus: []lifecycle.Status{lifecycle.StatusSupported, lifecycle.StatusUnknown, lifecycle.StatusEOL, lifecycle.StatusSupported},
+		},
+		{
+			name:       "only eol",
+			query:      "?support_status=eol",
+			wantStatus: []lifecycle.Status{lifecycle.StatusEOL},
+		},
+		{
+			name:       "supported or unknown",
+			query:      "?support_status=supported&support_status=unknown",
+			wantStatus: []lifecycle.Status{lifecycle.StatusSupported, lifecycle.StatusUnknown, lifecycle.StatusSupported},
+		},
+		{
+			name:       "paginated by the database",
+			query:      "?support_status=supported&limit=1&offset=1",
+			wantStatus: []lifecycle.Status{lifecycle.StatusSupported},
+		},
+		{
+			name:     "invalid status",
+			query:    "?support_status=expired",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/lifecycle"+tt.query, nil)
+			res := httptest.NewRecorder()
+
+			s.HandleListLifecycle(res, req)
+
+			wantCode := tt.wantCode
+			if wantCode == 0 {
+				wantCode = http.StatusOK
+			}
+			if res.Code != wantCode {
+				t.Fatalf("expected status %d, got %d", wantCode, res.Code)
+			}
+			if wantCode != http.StatusOK {
+				return
+			}
+			page := struct {
+				Items []mopsos.RecordSupport `json:"items"`
+				Total int64                  `json:"total"`
+			}{}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(page.Items) != len(tt.wantStatus) {
+				t.Fatalf("expected %d items, got %+v", len(tt.wantStatus), page.Items)
+			}
+			for i, status := range tt.wantStatus {
+				if page.Items[i].Status != status {
+					t.Errorf("item %d: expected status %s, got %+v", i, status, page.Items[i])
+				}
+			}
+			if tt.name == "paginated by the database" && page.Total != 2 {
+				t.Errorf("expected 2 supported records in total, got %d", page.Total)
+			}
+			if tt.query == "" && (page.Items[0].DaysUntilEOL == nil || page.Items[0].Cycle != "1.9") {
+				t.Errorf("expected the days until the eol of cycle 1.9, got %+v", page.Items[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/report"
+)
+
+// defaultEOLWithin is the number of days the eol report looks ahead by default
+const defaultEOLWithin = 90
+
+// HandleDriftReport compares the installed versions of applications across clusters
+//
+// The report can be limited to some applications with application_name and
+// to applications running in more than one version with drifting=true.
+func (s *Server) HandleDriftReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	drifting := false
+	if value := params.Get("drifting"); value != "" {
+		var err error
+		if drifting, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "drifting must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// scoped readers only see their own clusters in the report
+	query, err := s.readScope(r, s.database)
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to build drift report", http.StatusInternalServerError)
+		return
+	}
+	drift, err := report.Drift(r.Context(), query, params["application_name"])
+	if err != nil {
+		logrus.WithError(err).Error("failed to build drift report")
+		http.Error(w, "failed to build drift report", http.StatusInternalServerError)
+		return
+	}
+	if drifting {
+		drift = report.OnlyDrifting(drift)
+	}
+
+	writeJSON(w, http.StatusOK, drift)
+}
+
+// HandleEOLReport lists the records that are out of support or will be within some days
+//
+// The number of days is set with within and defaults to 90.
+func (s *Server) HandleEOLReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	within := defaultEOLWithin
+	if value := params.Get("within"); value != "" {
+		var err error
+		if within, err = strconv.Atoi(value); err != nil || within < 0 {
+			http.Error(w, "within must be a positive number of days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	query, err := s.readScope(r, s.database)
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to build eol report", http.StatusInternalServerError)
+		return
+	}
+	checker := lifecycle.NewChecker(s.database, s.config.LifecycleProductMapping)
+	expiring, err := report.EndOfLife(r.Context(), query, checker, within, time.Now())
+	if err != nil {
+		logrus.WithError(err).Error("failed to build eol report")
+		http.Error(w, "failed to build eol report", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, expiring)
+}
+
+// HandleComplianceReport summarizes the compliance results of each rule and lists the violations
+//
+// The report can be limited to some rules with rule.
+func (s *Server) HandleComplianceReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// scoped readers only see the results of their own clusters
+	query, err := s.readScope(r, s.database)
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to build compliance report", http.StatusInternalServerError)
+		return
+	}
+	summaries, err := report.Compliance(r.Context(), query, r.URL.Query()["rule"])
+	if err != nil {
+		logrus.WithError(err).Error("failed to build compliance report")
+		http.Error(w, "failed to build compliance report", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summaries)
+}
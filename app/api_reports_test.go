@@ -0,0 +1,118 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/report"
+)
+
+func Test_HandleDriftReport(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleDriftReport", apiRecords...)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/reports/drift?drifting=true", nil)
+	res := httptest.NewRecorder()
+
+	s.HandleDriftReport(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.Code)
+	}
+	drift := []report.ApplicationDrift{}
+	if err := json.NewDecoder(res.Body).Decode(&drift); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(drift) != 1 || drift[0].ApplicationName != "cert-manager" {
+		t.Fatalf("expected drift of cert-manager only, got %+v", drift)
+	}
+	if len(drift[0].Laggards) != 1 || drift[0].Laggards[0].ClusterName != "cluster-b" {
+		t.Errorf("expected cluster-b to lag behind, got %+v", drift[0].Laggards)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/reports/drift?drifting=maybe", nil)
+	res = httptest.NewRecorder()
+	s.HandleDriftReport(res, req)
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", res.Code)
+	}
+}
+
+func Test_HandleEOLReport(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleEOLReport", apiRecords...)
+	importCertManagerCycles(t, newTestDB(t, "Test_HandleEOLReport"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/reports/eol?within=30", nil)
+	res := httptest.NewRecorder()
+
+	s.HandleEOLReport(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.Code)
+	}
+	expiring := []report.Expiring{}
+	if err := json.NewDecoder(res.Body).Decode(&expiring); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(expiring) != 1 || expiring[0].ClusterName != "cluster-b" || expiring[0].Cycle != "1.8" {
+		t.Fatalf("expected cert-manager 1.8 on cluster-b only, got %+v", expiring)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/reports/eol?within=-1", nil)
+	res = httptest.NewRecorder()
+	s.HandleEOLReport(res, req)
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", res.Code)
+	}
+}
+
+func Test_HandleComplianceReport(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleComplianceReport", apiRecords...)
+	engine := compliance.NewEngine(newTestDB(t, "Test_HandleComplianceReport"))
+	rules := []models.ComplianceRule{
+		{Name: "cert-manager", Applications: []string{"cert-manager"}, Version: ">=1.9"},
+		{Name: "unused", Clusters: []string{"cluster-z"}, Version: ">=1.0"},
+	}
+	for _, rule := range rules {
+		if _, err := engine.SaveRule(context.Background(), rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/reports/compliance", nil)
+	res := httptest.NewRecorder()
+
+	s.HandleComplianceReport(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.Code)
+	}
+	summaries := []report.RuleCompliance{}
+	if err := json.NewDecoder(res.Body).Decode(&summaries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].Rule != "cert-manager" || summaries[1].Rule != "unused" {
+		t.Fatalf("expected both rules, got %+v", summaries)
+	}
+	if summaries[0].Compliant != 2 || summaries[0].Violations != 1 || summaries[0].Violating[0].ClusterName != "cluster-b" {
+		t.Errorf("expected cert-manager on cluster-b to violate the rule, got %+v", summaries[0])
+	}
+	if summaries[1].Compliant != 0 || summaries[1].Violations != 0 {
+		t.Errorf("expected no results for the unused rule, got %+v", summaries[1])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/reports/compliance?rule=unused", nil)
+	res = httptest.NewRecorder()
+	s.HandleComplianceReport(res, req)
+	summaries = []report.RuleCompliance{}
+	if err := json.NewDecoder(res.Body).Decode(&summaries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Rule != "unused" {
+		t.Errorf("expected the unused rule only, got %+v", summaries)
+	}
+}
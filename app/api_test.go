@@ -0,0 +1,316 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/clusters"
+	"github.com/adfinis-sygroup/mopsos/app/db"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/rbac"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+// newTestDB returns a migrated in memory database that is private to the named test
+func newTestDB(t *testing.T, name string) *gorm.DB {
+	gdb, err := db.NewDBConnection(&mopsos.Config{
+		DBProvider: "sqlite",
+		DBDSN:      "file:" + name + "?mode=memory&cache=shared",
+		DBMigrate:  true,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	return gdb
+}
+
+func newAPIServer(t *testing.T, name string, records ...models.Record) *mopsos.Server {
+	gdb := newTestDB(t, name)
+	for i := range records {
+		if err := gdb.Create(&records[i]).Error; err != nil {
+			t.Fatalf("failed to seed database: %v", err)
+		}
+	}
+	return mopsos.NewServer(&mopsos.Config{}).WithDatabase(gdb)
+}
+
+var apiRecords = []models.Record{
+	{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "1.9.1"},
+	{ClusterName: "cluster-a", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.0"},
+	{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "1.8.0", SyncStatus: "OutOfSync", HealthStatus: "Degraded"},
+	{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationInstance: "second", ApplicationVersion: "1.9.1", SyncStatus: "Synced", HealthStatus: "Healthy"},
+}
+
+func Test_HandleListRecords(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleListRecords", apiRecords...)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTotal  int64
+		wantFirst  string
+		wantItems  int
+	}{
+		{
+			name:       "all records sorted by key",
+			query:      "",
+			wantStatus: http.StatusOK,
+			wantTotal:  4,
+			wantFirst:  "cluster-a",
+			wantItems:  4,
+		},
+		{
+			name:       "filter by application",
+			query:      "?application_name=cert-manager",
+			wantStatus: http.StatusOK,
+			wantTotal:  3,
+			wantFirst:  "cluster-a",
+			wantItems:  3,
+		},
+		{
+			name:       "filter by multiple values and sort descending",
+			query:      "?application_version=1.8.0&application_version=4.2.0&sort=-cluster_name",
+			wantStatus: http.StatusOK,
+			wantTotal:  2,
+			wantFirst:  "cluster-b",
+			wantItems:  2,
+		},
+		{
+			name:       "filter by health status",
+			query:      "?health_status=Degraded",
+			wantStatus: http.StatusOK,
+			wantTotal:  1,
+			wantFirst:  "cluster-b",
+			wantItems:  1,
+		},
+		{
+			name:       "paginated",
+			query:      "?limit=1&offset=2",
+			wantStatus: http.StatusOK,
+			wantTotal:  4,
+			wantFirst:  "cluster-b",
+			wantItems:  1,
+		},
+		{
+			name:       "invalid sort column",
+			query:      "?sort=id",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid limit",
+			query:      "?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/records"+tt.query, nil)
+			res := httptest.NewRecorder()
+
+			s.HandleListRecords(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			page := struct {
+				Items []models.Record `json:"items"`
+				Total int64           `json:"total"`
+			}{}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if page.Total != tt.wantTotal {
+				t.Errorf("expected total %d, got %d", tt.wantTotal, page.Total)
+			}
+			if len(page.Items) != tt.wantItems {
+				t.Fatalf("expected %d items, got %d", tt.wantItems, len(page.Items))
+			}
+			if page.Items[0].ClusterName != tt.wantFirst {
+				t.Errorf("expected first item from %s, got %s", tt.wantFirst, page.Items[0].ClusterName)
+			}
+		})
+	}
+}
+
+func Test_HandleGetRecord(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleGetRecord", apiRecords...)
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantVersion string
+	}{
+		{
+			name:        "record with empty instance parts",
+			path:        "/api/v1/records/cluster-b/cert-manager",
+			wantStatus:  http.StatusOK,
+			wantVersion: "1.8.0",
+		},
+		{
+			name:        "record with application instance",
+			path:        "/api/v1/records/cluster-b/cert-manager?application_instance=second",
+			wantStatus:  http.StatusOK,
+			wantVersion: "1.9.1",
+		},
+		{
+			name:       "unknown record",
+			path:       "/api/v1/records/cluster-c/cert-manager",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "incomplete key",
+			path:       "/api/v1/records/cluster-b",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			res := httptest.NewRecorder()
+
+			s.HandleGetRecord(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			record := &models.Record{}
+			if err := json.NewDecoder(res.Body).Decode(record); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if record.ApplicationVersion != tt.wantVersion {
+				t.Errorf("expected version %s, got %s", tt.wantVersion, record.ApplicationVersion)
+			}
+		})
+	}
+}
+
+func Test_HandleListHistory(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleListHistory")
+	for _, entry := range []models.RecordHistory{
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "1.8.0", EventID: "1"},
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", PreviousVersion: "1.8.0", ApplicationVersion: "1.9.1", EventID: "2"},
+		{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "1.9.1", EventID: "3"},
+	} {
+		entry := entry
+		if err := gdb.Create(&entry).Error; err != nil {
+			t.Fatalf("failed to seed database: %v", err)
+		}
+	}
+	s := mopsos.NewServer(&mopsos.Config{}).WithDatabase(gdb)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/history?cluster_name=cluster-a&application_name=cert-manager&sort=-event_id", nil)
+	res := httptest.NewRecorder()
+	s.HandleListHistory(res, req)
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected sorting by event_id to be rejected, got %d", res.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/history?cluster_name=cluster-a&application_name=cert-manager&since=2000-01-01T00:00:00Z", nil)
+	res = httptest.NewRecorder()
+	s.HandleListHistory(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.Code)
+	}
+	page := struct {
+		Items []models.RecordHistory `json:"items"`
+		Total int64                  `json:"total"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if page.Total != 2 || len(page.Items) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", page.Total)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/history?until=yesterday", nil)
+	res = httptest.NewRecorder()
+	s.HandleListHistory(res, req)
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid until to be rejected, got %d", res.Code)
+	}
+}
+
+func Test_HandleListRecordsReadScope(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleListRecordsReadScope", apiRecords...)
+	store := clusters.NewStore(newTestDB(t, "Test_HandleListRecordsReadScope"))
+	if _, _, err := store.Add(context.Background(), "cluster-b", "", map[string]string{"team": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	s = s.WithClusters(store)
+
+	tests := []struct {
+		name         string
+		identity     *rbac.Identity
+		wantClusters []string
+	}{
+		{
+			name:         "unscoped reader",
+			identity:     &rbac.Identity{Name: "reader", Roles: []rbac.Role{rbac.RoleReader}},
+			wantClusters: []string{"cluster-a", "cluster-a", "cluster-b", "cluster-b"},
+		},
+		{
+			name:         "scoped by cluster",
+			identity:     &rbac.Identity{Name: "team-a", Roles: []rbac.Role{rbac.RoleReader}, Clusters: []string{"cluster-a"}},
+			wantClusters: []string{"cluster-a", "cluster-a"},
+		},
+		{
+			name:         "scoped by label",
+			identity:     &rbac.Identity{Name: "team-b", Roles: []rbac.Role{rbac.RoleReader}, Labels: map[string]string{"team": "b"}},
+			wantClusters: []string{"cluster-b", "cluster-b"},
+		},
+		{
+			name:     "no matching cluster",
+			identity: &rbac.Identity{Name: "team-c", Roles: []rbac.Role{rbac.RoleReader}, Labels: map[string]string{"team": "c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/records", nil)
+			req = req.WithContext(context.WithValue(req.Context(), types.ContextIdentity, tt.identity))
+			res := httptest.NewRecorder()
+
+			s.HandleListRecords(res, req)
+
+			if res.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", res.Code)
+			}
+			page := struct {
+				Items []models.Record `json:"items"`
+			}{}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			got := []string{}
+			for _, record := range page.Items {
+				got = append(got, record.ClusterName)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantClusters, ",") {
+				t.Errorf("expected records of %v, got %v", tt.wantClusters, got)
+			}
+		})
+	}
+
+	// records of other clusters are not found
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/records/cluster-b/cert-manager", nil)
+	req = req.WithContext(context.WithValue(req.Context(), types.ContextIdentity, tests[1].identity))
+	res := httptest.NewRecorder()
+	s.HandleGetRecord(res, req)
+	if res.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", res.Code)
+	}
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
+)
+
+// upstreamFilterColumns are the columns of the upstream_releases table that may be used for filtering
+var upstreamFilterColumns = []string{"chart_name", "latest_version", "source"}
+
+// upstreamColumns are the columns of the upstream_releases table that may be used for sorting
+var upstreamColumns = append(append([]string{}, upstreamFilterColumns...), "checked_at")
+
+// RecordStatus is a record annotated with the latest upstream release of its chart
+type RecordStatus struct {
+	models.Record
+	ChartName     string          `json:"chart_name"`
+	LatestVersion string          `json:"latest_version"`
+	Status        upstream.Status `json:"status"`
+}
+
+// HandleListUpstream returns the latest upstream release of every tracked chart
+func (s *Server) HandleListUpstream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, upstreamColumns, "chart_name")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := filterQuery(s.database.WithContext(r.Context()).Model(&models.UpstreamRelease{}), params, upstreamFilterColumns)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.WithError(err).Error("failed to count upstream releases")
+		http.Error(w, "failed to query upstream releases", http.StatusInternalServerError)
+		return
+	}
+
+	releases := []models.UpstreamRelease{}
+	if err := page.apply(query).Find(&releases).Error; err != nil {
+		logrus.WithError(err).Error("failed to list upstream releases")
+		http.Error(w, "failed to query upstream releases", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page{
+		Items:  releases,
+		Total:  total,
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// trackedStatuses are the statuses of records whose chart is tracked, all other records are unknown
+var trackedStatuses = []string{string(upstream.StatusCurrent), string(upstream.StatusOutdated)}
+
+// upstreamStatusExists matches records with a stored status in a list of statuses
+const upstreamStatusExists = "EXISTS (SELECT 1 FROM upstream_statuses WHERE " +
+	"upstream_statuses.cluster_name = records.cluster_name AND upstream_statuses.instance_id = records.instance_id AND " +
+	"upstream_statuses.application_name = records.application_name AND upstream_statuses.application_instance = records.application_instance AND " +
+	"upstream_statuses.status IN ?)"
+
+// HandleListOutdated returns records together with their upstream status
+//
+// Records can be filtered like in HandleListRecords and additionally by
+// status (current, outdated or unknown). The statuses are stored whenever a
+// record is written or the upstream releases are refreshed, records without
+// stored status are unknown.
+func (s *Server) HandleListOutdated(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, recordColumns, strings.Join(models.RecordKeyColumns, ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := s.readScope(r, filterQuery(s.database.WithContext(r.Context()).Model(&models.Record{}), params, recordColumns))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+	if values, ok := params["status"]; ok {
+		for _, value := range values {
+			if value != string(upstream.StatusUnknown) && !contains(trackedStatuses, value) {
+				http.Error(w, "invalid status: "+value, http.StatusBadRequest)
+				return
+			}
+		}
+		// records without stored status are unknown, so unknown is matched by excluding the other statuses
+		if contains(values, string(upstream.StatusUnknown)) {
+			excluded := []string{}
+			for _, status := range trackedStatuses {
+				if !contains(values, status) {
+					excluded = append(excluded, status)
+				}
+			}
+			if len(excluded) > 0 {
+				query = query.Where("NOT "+upstreamStatusExists, excluded)
+			}
+		} else {
+			query = query.Where(upstreamStatusExists, values)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.WithError(err).Error("failed to count records")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+	records := []models.Record{}
+	if err := page.apply(query).Find(&records).Error; err != nil {
+		logrus.WithError(err).Error("failed to list records")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	stored, err := s.upstreamStatuses(r, records)
+	if err != nil {
+		logrus.WithError(err).Error("failed to list upstream statuses")
+		http.Error(w, "failed to query upstream statuses", http.StatusInternalServerError)
+		return
+	}
+	statuses := make([]RecordStatus, len(records))
+	for i, record := range records {
+		statuses[i] = RecordStatus{Record: record, Status: upstream.StatusUnknown}
+		if status, ok := stored[record.Key()]; ok {
+			statuses[i].ChartName = status.ChartName
+			statuses[i].LatestVersion = status.LatestVersion
+			statuses[i].Status = upstream.Status(status.Status)
+			continue
+		}
+		statuses[i].ChartName = record.ChartName
+		if statuses[i].ChartName == "" {
+			statuses[i].ChartName = upstream.ChartName(s.config.UpstreamChartMapping, record.ApplicationName)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, Page{
+		Items:  statuses,
+		Total:  total,
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// upstreamStatuses returns the stored upstream statuses of records by their key
+func (s *Server) upstreamStatuses(r *http.Request, records []models.Record) (map[models.RecordKey]models.UpstreamStatus, error) {
+	stored := make(map[models.RecordKey]models.UpstreamStatus, len(records))
+	if len(records) == 0 {
+		return stored, nil
+	}
+	clusters := make([]string, len(records))
+	applications := make([]string, len(records))
+	for i, record := range records {
+		clusters[i] = record.ClusterName
+		applications[i] = record.ApplicationName
+	}
+	rows := []models.UpstreamStatus{}
+	err := s.database.WithContext(r.Context()).
+		Where("cluster_name IN ? AND application_name IN ?", clusters, applications).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		key := models.RecordKey{
+			ClusterName:         row.ClusterName,
+			InstanceId:          row.InstanceId,
+			ApplicationName:     row.ApplicationName,
+			ApplicationInstance: row.ApplicationInstance,
+		}
+		stored[key] = row
+	}
+	return stored, nil
+}
@@ -0,0 +1,138 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
+)
+
+func Test_HandleListOutdated(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleListOutdated")
+	for _, record := range []models.Record{
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.8.0"},
+		{ClusterName: "cluster-b", ApplicationName: "nginx", ApplicationVersion: "4.2.5"},
+		{ClusterName: "cluster-b", ApplicationName: "custom-app", ApplicationVersion: "0.1.0"},
+		{ClusterName: "cluster-c", ApplicationName: "edge-proxy", ChartName: "ingress-nginx", ApplicationVersion: "4.2.0"},
+	} {
+		record := record
+		if err := gdb.Create(&record).Error; err != nil {
+			t.Fatalf("failed to seed database: %v", err)
+		}
+	}
+	err := gdb.Create(&[]models.UpstreamRelease{
+		{ChartName: "cert-manager", LatestVersion: "v1.9.1"},
+		{ChartName: "ingress-nginx", LatestVersion: "4.2.5"},
+	}).Error
+	if err != nil {
+		t.Fatalf("failed to seed database: %v", err)
+	}
+	mapping := map[string]string{"nginx": "ingress-nginx"}
+	if err := upstream.NewTracker(gdb, nil, mapping).EvaluateAll(context.Background()); err != nil {
+		t.Fatalf("EvaluateAll() error = %v", err)
+	}
+	// records written without tracker have no stored status
+	if err := gdb.Create(&models.Record{ClusterName: "cluster-d", ApplicationName: "cert-manager", ApplicationVersion: "v1.7.0"}).Error; err != nil {
+		t.Fatalf("failed to seed database: %v", err)
+	}
+
+	s := mopsos.NewServer(&mopsos.Config{UpstreamChartMapping: mapping}).WithDatabase(gdb)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTotal  int64
+		want       []mopsos.RecordStatus
+	}{
+		{
+			name:       "all records",
+			query:      "?sort=cluster_name,application_name",
+			wantStatus: http.StatusOK,
+			wantTotal:  6,
+			want: []mopsos.RecordStatus{
+				{ChartName: "cert-manager", LatestVersion: "v1.9.1", Status: "current"},
+				{ChartName: "cert-manager", LatestVersion: "v1.9.1", Status: "outdated"},
+				{ChartName: "custom-app", Status: "unknown"},
+				{ChartName: "ingress-nginx", LatestVersion: "4.2.5", Status: "current"},
+				{ChartName: "ingress-nginx", LatestVersion: "4.2.5", Status: "outdated"},
+				{ChartName: "cert-manager", Status: "unknown"},
+			},
+		},
+		{
+			name:       "only outdated",
+			query:      "?status=outdated&sort=cluster_name",
+			wantStatus: http.StatusOK,
+			wantTotal:  2,
+			want: []mopsos.RecordStatus{
+				{ChartName: "cert-manager", LatestVersion: "v1.9.1", Status: "outdated"},
+				{ChartName: "ingress-nginx", LatestVersion: "4.2.5", Status: "outdated"},
+			},
+		},
+		{
+			name:       "unknown including records without status",
+			query:      "?status=unknown&sort=cluster_name",
+			wantStatus: http.StatusOK,
+			wantTotal:  2,
+			want: []mopsos.RecordStatus{
+				{ChartName: "custom-app", Status: "unknown"},
+				{ChartName: "cert-manager", Status: "unknown"},
+			},
+		},
+		{
+			name:       "paginated in the database",
+			query:      "?status=current&status=unknown&sort=cluster_name,application_name&limit=2&offset=1",
+			wantStatus: http.StatusOK,
+			wantTotal:  4,
+			want: []mopsos.RecordStatus{
+				{ChartName: "custom-app", Status: "unknown"},
+				{ChartName: "ingress-nginx", LatestVersion: "4.2.5", Status: "current"},
+			},
+		},
+		{
+			name:       "invalid status",
+			query:      "?status=stale",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/outdated"+tt.query, nil)
+			res := httptest.NewRecorder()
+
+			s.HandleListOutdated(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			page := struct {
+				Items []mopsos.RecordStatus `json:"items"`
+				Total int64                 `json:"total"`
+			}{}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if page.Total != tt.wantTotal {
+				t.Errorf("expected total %d, got %d", tt.wantTotal, page.Total)
+			}
+			if len(page.Items) != len(tt.want) {
+				t.Fatalf("expected %d items, got %d", len(tt.want), len(page.Items))
+			}
+			for i, want := range tt.want {
+				got := page.Items[i]
+				if got.ChartName != want.ChartName || got.LatestVersion != want.LatestVersion || got.Status != want.Status {
+					t.Errorf("item %d: expected %+v, got %+v", i, want, got)
+				}
+			}
+		})
+	}
+}
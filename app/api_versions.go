@@ -0,0 +1,147 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+// RecordGap is a record annotated with its gap to the newest version of the application in the fleet
+type RecordGap struct {
+	models.Record
+	NewestVersion string      `json:"newest_version"`
+	Gap           version.Gap `json:"gap"`
+}
+
+// HandleListGaps returns records classified by how far they are behind the newest version in the fleet
+//
+// Records can be filtered like in HandleListRecords. The level and behind
+// parameters limit the result to records at least behind versions behind on
+// level, i.e. level=minor&behind=2 returns records more than one minor version
+// or any major version behind. Records with unknown gaps are only returned
+// without these parameters or for level=unknown.
+func (s *Server) HandleListGaps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, recordColumns, strings.Join(models.RecordKeyColumns, ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	level := version.LevelNone
+	_, filterLevel := params["level"]
+	if filterLevel {
+		if level, err = version.ParseLevel(params.Get("level")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	behind := 0
+	_, filterBehind := params["behind"]
+	if filterBehind {
+		if behind, err = strconv.Atoi(params.Get("behind")); err != nil || behind < 0 {
+			http.Error(w, "behind must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	query, err := s.readScope(r, filterQuery(s.database.WithContext(r.Context()).Model(&models.Record{}), params, recordColumns))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+	for _, order := range page.order {
+		query = query.Order(order)
+	}
+	records := []models.Record{}
+	if err := query.Find(&records).Error; err != nil {
+		logrus.WithError(err).Error("failed to list records")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	// the newest version is determined across the whole fleet, regardless of the read scope and the other filters
+	newest, err := s.newestVersions(r, records)
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine newest versions")
+		http.Error(w, "failed to query records", http.StatusInternalServerError)
+		return
+	}
+
+	gaps := []RecordGap{}
+	for _, record := range records {
+		installed, _ := version.Parse(record.ApplicationVersion)
+		gap := RecordGap{
+			Record: record,
+			Gap:    version.GapBetween(installed, newest[record.ApplicationName]),
+		}
+		if v := newest[record.ApplicationName]; v != nil {
+			gap.NewestVersion = v.String()
+		}
+		if (filterLevel || filterBehind) && !gap.Gap.Matches(level, behind) {
+			continue
+		}
+		gaps = append(gaps, gap)
+	}
+
+	start, end := page.bounds(len(gaps))
+	writeJSON(w, http.StatusOK, Page{
+		Items:  gaps[start:end],
+		Total:  int64(len(gaps)),
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// newestVersions returns the newest version of every application in records on all clusters
+//
+// Scoped readers see how far their clusters are behind the fleet, which only
+// reveals the newest version string of the applications they run.
+func (s *Server) newestVersions(r *http.Request, records []models.Record) (map[string]*version.Version, error) {
+	names := map[string]bool{}
+	for _, record := range records {
+		names[record.ApplicationName] = true
+	}
+	if len(names) == 0 {
+		return map[string]*version.Version{}, nil
+	}
+	applications := make([]string, 0, len(names))
+	for name := range names {
+		applications = append(applications, name)
+	}
+
+	rows := []models.Record{}
+	err := s.database.WithContext(r.Context()).Model(&models.Record{}).
+		Distinct("application_name", "application_version").
+		Where("application_name IN ?", applications).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return newestByApplication(rows), nil
+}
+
+// newestByApplication returns the newest parseable version of each application
+func newestByApplication(records []models.Record) map[string]*version.Version {
+	versions := map[string][]*version.Version{}
+	for _, record := range records {
+		if v, err := version.Parse(record.ApplicationVersion); err == nil {
+			versions[record.ApplicationName] = append(versions[record.ApplicationName], v)
+		}
+	}
+	newest := make(map[string]*version.Version, len(versions))
+	for name, list := range versions {
+		newest[name] = version.Newest(list)
+	}
+	return newest
+}
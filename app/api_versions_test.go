@@ -0,0 +1,127 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/rbac"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+func Test_HandleListGaps(t *testing.T) {
+	s := newAPIServer(t, "Test_HandleListGaps",
+		models.Record{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.8.0"},
+		models.Record{ClusterName: "cluster-c", ApplicationName: "cert-manager", ApplicationVersion: "v1.7.2"},
+		models.Record{ClusterName: "cluster-d", ApplicationName: "cert-manager", ApplicationVersion: "v0.16.1"},
+		models.Record{ClusterName: "cluster-e", ApplicationName: "cert-manager", ApplicationVersion: "v1.10.0-alpha.0"},
+		models.Record{ClusterName: "cluster-f", ApplicationName: "cert-manager", ApplicationVersion: "latest"},
+		models.Record{ClusterName: "cluster-a", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.0"},
+	)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name:       "all cert-manager records",
+			query:      "?application_name=cert-manager",
+			wantStatus: http.StatusOK,
+			want: map[string]string{
+				"cluster-a": "none",
+				"cluster-b": "minor",
+				"cluster-c": "minor",
+				"cluster-d": "major",
+				"cluster-e": "none",
+				"cluster-f": "unknown",
+			},
+		},
+		{
+			name:       "more than one minor version behind",
+			query:      "?application_name=cert-manager&level=minor&behind=2",
+			wantStatus: http.StatusOK,
+			want: map[string]string{
+				"cluster-c": "minor",
+				"cluster-d": "major",
+			},
+		},
+		{
+			name:       "only unknown gaps",
+			query:      "?application_name=cert-manager&level=unknown",
+			wantStatus: http.StatusOK,
+			want: map[string]string{
+				"cluster-f": "unknown",
+			},
+		},
+		{
+			name:       "invalid level",
+			query:      "?level=huge",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty level",
+			query:      "?level=",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/gaps"+tt.query, nil)
+			res := httptest.NewRecorder()
+
+			s.HandleListGaps(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			page := struct {
+				Items []mopsos.RecordGap `json:"items"`
+			}{}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(page.Items) != len(tt.want) {
+				t.Fatalf("expected %d items, got %d", len(tt.want), len(page.Items))
+			}
+			for _, item := range page.Items {
+				if item.NewestVersion != "v1.9.1" {
+					t.Errorf("expected newest version v1.9.1, got %s", item.NewestVersion)
+				}
+				if string(item.Gap.Level) != tt.want[item.ClusterName] {
+					t.Errorf("expected %s gap for %s, got %s", tt.want[item.ClusterName], item.ClusterName, item.Gap.Level)
+				}
+			}
+		})
+	}
+
+	// scoped readers only get the records of their clusters but compare them with the whole fleet
+	identity := &rbac.Identity{Name: "team-b", Roles: []rbac.Role{rbac.RoleReader}, Clusters: []string{"cluster-b", "cluster-c"}}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/gaps?application_name=cert-manager", nil)
+	req = req.WithContext(context.WithValue(req.Context(), types.ContextIdentity, identity))
+	res := httptest.NewRecorder()
+	s.HandleListGaps(res, req)
+	page := struct {
+		Items []mopsos.RecordGap `json:"items"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("expected the records of cluster-b and cluster-c, got %+v", page.Items)
+	}
+	for _, item := range page.Items {
+		if item.NewestVersion != "v1.9.1" {
+			t.Errorf("expected newest fleet version v1.9.1 for %s, got %s", item.ClusterName, item.NewestVersion)
+		}
+	}
+}
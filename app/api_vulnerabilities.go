@@ -0,0 +1,85 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+// HandleListAffected returns the records affected by the imported vulnerabilities
+//
+// Records can be filtered like in HandleListRecords, the findings can be
+// limited to a vulnerability by its id or an alias like a CVE id with the
+// vulnerability parameter and to severities with the severity parameter.
+func (s *Server) HandleListAffected(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+
+	page, err := parsePagination(params, recordColumns, strings.Join(models.RecordKeyColumns, ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := s.readScope(r, filterQuery(s.database.WithContext(r.Context()).Model(&models.Record{}), params, recordColumns))
+	if err != nil {
+		logrus.WithError(err).Error("failed to determine readable clusters")
+		http.Error(w, "failed to query vulnerabilities", http.StatusInternalServerError)
+		return
+	}
+	for _, order := range page.order {
+		query = query.Order(order)
+	}
+	records := []models.Record{}
+	if err := query.Preload("Images").Find(&records).Error; err != nil {
+		logrus.WithError(err).Error("failed to list records")
+		http.Error(w, "failed to query vulnerabilities", http.StatusInternalServerError)
+		return
+	}
+
+	findings, err := osv.NewMatcher(s.database, s.config.OSVNameMapping, s.config.OSVDefaultEcosystem).Match(r.Context(), records)
+	if err != nil {
+		logrus.WithError(err).Error("failed to match vulnerabilities")
+		http.Error(w, "failed to query vulnerabilities", http.StatusInternalServerError)
+		return
+	}
+
+	vulnerabilities, severities := params["vulnerability"], params["severity"]
+	filtered := []osv.Finding{}
+	for _, finding := range findings {
+		if len(vulnerabilities) > 0 && !containsAny(vulnerabilities, append([]string{finding.VulnerabilityID}, finding.Aliases...)) {
+			continue
+		}
+		if len(severities) > 0 && !containsAny(severities, []string{finding.Severity}) {
+			continue
+		}
+		filtered = append(filtered, finding)
+	}
+
+	start, end := page.bounds(len(filtered))
+	writeJSON(w, http.StatusOK, Page{
+		Items:  filtered[start:end],
+		Total:  int64(len(filtered)),
+		Limit:  page.limit,
+		Offset: page.offset,
+	})
+}
+
+// containsAny reports whether any of values is wanted, ignoring case
+func containsAny(wanted []string, values []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if strings.EqualFold(w, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+func Test_HandleListAffected(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleListAffected")
+	for _, record := range []models.Record{
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.8.0"},
+		{ClusterName: "cluster-c", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.2"},
+		{ClusterName: "cluster-c", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.0", Images: []models.Image{
+			{Registry: "registry.k8s.io", Repository: "ingress-nginx/controller", Tag: "v1.2.1"},
+		}},
+	} {
+		record := record
+		if err := gdb.Create(&record).Error; err != nil {
+			t.Fatalf("failed to seed database: %v", err)
+		}
+	}
+	if err := osv.NewImporter(gdb, "osv/testdata/advisories").Import(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s := mopsos.NewServer(&mopsos.Config{
+		OSVNameMapping: map[string]string{
+			"cert-manager": "github.com/cert-manager/cert-manager",
+			"registry.k8s.io/ingress-nginx/controller": "k8s.io/ingress-nginx",
+		},
+	}).WithDatabase(gdb)
+
+	tests := []struct {
+		name         string
+		query        string
+		wantClusters []string
+	}{
+		{name: "all findings", query: "", wantClusters: []string{"cluster-a", "cluster-b", "cluster-c"}},
+		{name: "by alias", query: "?vulnerability=cve-2022-0001", wantClusters: []string{"cluster-a", "cluster-b"}},
+		{name: "by severity", query: "?severity=HIGH&cluster_name=cluster-b&cluster_name=cluster-c", wantClusters: []string{"cluster-b"}},
+		{name: "by application", query: "?application_name=ingress-nginx", wantClusters: []string{"cluster-c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/affected"+tt.query, nil)
+			res := httptest.NewRecorder()
+
+			s.HandleListAffected(res, req)
+
+			if res.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", res.Code)
+			}
+			page := struct {
+				Items []osv.Finding `json:"items"`
+			}{}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(page.Items) != len(tt.wantClusters) {
+				t.Fatalf("expected %d findings, got %+v", len(tt.wantClusters), page.Items)
+			}
+			for i, cluster := range tt.wantClusters {
+				if page.Items[i].ClusterName != cluster {
+					t.Errorf("finding %d: expected cluster %s, got %+v", i, cluster, page.Items[i])
+				}
+			}
+		})
+	}
+}
@@ -1,17 +1,30 @@
 package app
 
 import (
+	"context"
 	"errors"
+	"fmt"
 
-	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/clusters"
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+	"github.com/adfinis-sygroup/mopsos/app/queue"
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 // App is the application struct
 type App struct {
-	Server  *Server
-	Handler *Handler
+	Server    *Server
+	Handler   *Handler
+	Upstream  *upstream.Tracker
+	OSV       *osv.Importer
+	Lifecycle *lifecycle.Importer
+	Queue     queue.Queue
+
+	config *Config
 }
 
 // NewApp creates a new App
@@ -19,24 +32,125 @@ func NewApp(c *Config, db *gorm.DB) (*App, error) {
 	if db == nil {
 		return nil, errors.New("database is nil")
 	}
+	// the background jobs run on a ticker, which can't tick without a positive interval
+	if len(c.UpstreamSources) > 0 && c.UpstreamInterval <= 0 {
+		return nil, fmt.Errorf("invalid upstream interval %s", c.UpstreamInterval)
+	}
+	if c.OSVPath != "" && c.OSVInterval <= 0 {
+		return nil, fmt.Errorf("invalid OSV interval %s", c.OSVInterval)
+	}
+	if c.LifecyclePath != "" && c.LifecycleInterval <= 0 {
+		return nil, fmt.Errorf("invalid lifecycle interval %s", c.LifecycleInterval)
+	}
+	q, err := queue.New(c.QueueProvider, c.QueuePath, c.QueueSize)
+	if err != nil {
+		return nil, err
+	}
+	// rules from the file replace the rules of the previous file, rules added using the API are kept
+	engine := compliance.NewEngine(db)
+	if c.ComplianceRulesFile != "" {
+		rules, err := compliance.LoadFile(c.ComplianceRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := engine.SyncFile(context.Background(), rules); err != nil {
+			return nil, err
+		}
+	}
+	tracker := upstream.NewTracker(db, c.UpstreamSources, c.UpstreamChartMapping)
+	// cycles may also be imported using the CLI, so records are always matched to them
+	checker := lifecycle.NewChecker(db, c.LifecycleProductMapping)
+	handler := NewHandler(c.EnableTracing, db).
+		WithRetries(c.HandlerRetries, c.HandlerRetryBackoff).
+		WithWorkers(c.HandlerWorkers).
+		WithBatching(c.HandlerBatchSize, c.HandlerBatchWindow).
+		WithCompliance(engine).
+		WithLifecycle(checker)
+	if len(c.UpstreamSources) > 0 {
+		handler = handler.WithUpstream(tracker)
+	}
 	return &App{
-		Server:  NewServer(c),
-		Handler: NewHandler(c.EnableTracing, db),
+		Server: NewServer(c).
+			WithDatabase(db).
+			WithHandler(handler).
+			WithClusters(clusters.NewStore(db)).
+			WithCompliance(engine).
+			WithQueue(q),
+		Handler:   handler,
+		Upstream:  tracker,
+		OSV:       osv.NewImporter(db, c.OSVPath),
+		Lifecycle: lifecycle.NewImporter(db, c.LifecyclePath, checker),
+		Queue:     q,
+
+		config: c,
 	}, nil
 }
 
-func (a *App) Run() {
-	// eventChan is used to asynchronously pass events receiver from the Server to the Handler
-	eventChan := make(chan models.EventData)
-
-	// handle events in background goroutine
+// Run starts the server and handles events until ctx is cancelled or the server fails
+//
+// On shutdown the server stops accepting webhooks first, then the events left on
+// the queue are handled until the queue is empty or the shutdown timeout is reached.
+func (a *App) Run(ctx context.Context) error {
+	// handle events from the queue in background goroutine
+	handled := make(chan struct{})
 	go func() {
-		err := a.Handler.HandleEvents(eventChan)
+		defer close(handled)
+		err := a.Handler.HandleEvents(a.Queue)
 		if err != nil {
 			logrus.WithError(err).Error("error handling events")
 		}
 	}()
 
-	// start blocking server
-	a.Server.WithEventChannel(eventChan).Start()
+	// refresh upstream releases in background goroutine
+	if len(a.config.UpstreamSources) > 0 {
+		go a.Upstream.Run(ctx, a.config.UpstreamInterval)
+	}
+
+	// import vulnerabilities in background goroutine
+	if a.config.OSVPath != "" {
+		go a.OSV.Run(ctx, a.config.OSVInterval)
+	}
+
+	// import the lifecycle dataset in background goroutine
+	if a.config.LifecyclePath != "" {
+		go a.Lifecycle.Run(ctx, a.config.LifecycleInterval)
+	}
+
+	// start server in background goroutine
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- a.Server.Start()
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		logrus.Info("Shutting down")
+	case err = <-serverErr:
+		logrus.WithError(err).Error("server failed, shutting down")
+	}
+	a.shutdown(handled)
+	return err
+}
+
+// shutdown stops the server and waits for the handler to drain the queue
+func (a *App) shutdown(handled <-chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+
+	if err := a.Server.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Warn("failed to wait for running requests")
+	}
+	// events failing during shutdown are dead-lettered instead of retried
+	a.Handler.Stop()
+	if err := a.Queue.Close(); err != nil {
+		logrus.WithError(err).Error("failed to close queue")
+	}
+
+	select {
+	case <-handled:
+		logrus.Info("Handled all queued events")
+	case <-ctx.Done():
+		logrus.WithField("events", a.Queue.Len()).Warn("shutdown timeout reached before all queued events were handled")
+	}
 }
@@ -1,11 +1,15 @@
 package app_test
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"gorm.io/gorm"
 
 	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/models"
 )
 
 func Test_NewAppFailWhenNoDatabase(t *testing.T) {
@@ -17,6 +21,25 @@ func Test_NewAppFailWhenNoDatabase(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+func Test_NewAppFailsWithoutInterval(t *testing.T) {
+	tests := []struct {
+		name   string
+		config mopsos.Config
+	}{
+		{name: "upstream", config: mopsos.Config{UpstreamSources: []string{"index.yaml"}}},
+		{name: "osv", config: mopsos.Config{OSVPath: "advisories", OSVInterval: -time.Hour}},
+		{name: "lifecycle", config: mopsos.Config{LifecyclePath: "products"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := mopsos.NewApp(&tt.config, &gorm.DB{}); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
 func Test_NewApp(t *testing.T) {
 	dbMock := &gorm.DB{}
 	a, _ := mopsos.NewApp(&mopsos.Config{
@@ -27,3 +50,56 @@ func Test_NewApp(t *testing.T) {
 		t.Error("NewApp() returned nil")
 	}
 }
+
+func Test_AppRunDrainsQueueOnShutdown(t *testing.T) {
+	gdb := newTestDB(t, "Test_AppRunDrainsQueueOnShutdown")
+	a, err := mopsos.NewApp(&mopsos.Config{
+		HttpListener:    "127.0.0.1:0",
+		ShutdownTimeout: 10 * time.Second,
+	}, gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := a.Queue.Enqueue(eventStub(&models.Record{
+			ClusterName:        "cluster",
+			ApplicationName:    fmt.Sprintf("app-%d", i),
+			ApplicationVersion: "1.0.0",
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// shut down right away, the queued events must still be stored
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.Run(ctx); err != nil {
+		t.Fatalf("App.Run() error = %v", err)
+	}
+
+	var count int64
+	if err := gdb.Model(&models.Record{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 {
+		t.Errorf("expected 10 records, got %d", count)
+	}
+	if a.Queue.Len() != 0 {
+		t.Errorf("expected empty queue, %d events pending", a.Queue.Len())
+	}
+}
+
+func Test_AppRunFailsWhenServerFails(t *testing.T) {
+	gdb := newTestDB(t, "Test_AppRunFailsWhenServerFails")
+	a, err := mopsos.NewApp(&mopsos.Config{
+		HttpListener:    "invalid:listener",
+		ShutdownTimeout: time.Second,
+	}, gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Run(context.Background()); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
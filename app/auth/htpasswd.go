@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadHtpasswd reads users and their stored passwords from an htpasswd style file
+//
+// Every line holds a user and a bcrypt, argon2id or plaintext password
+// separated by a colon, i.e. as created by `htpasswd -B`. Empty lines and
+// lines starting with # are ignored.
+func LoadHtpasswd(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%s:%d: expected user:password", path, line)
+		}
+		users[parts[0]] = parts[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,48 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/auth"
+)
+
+func Test_LoadHtpasswd(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "users with comments and empty lines",
+			content: "# clusters\ncluster-a:$2y$05$abc\n\ncluster-b:" + argon2idHash + "\n",
+			want: map[string]string{
+				"cluster-a": "$2y$05$abc",
+				"cluster-b": argon2idHash,
+			},
+		},
+		{
+			name:    "missing password",
+			content: "cluster-a\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "htpasswd")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := auth.LoadHtpasswd(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadHtpasswd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadHtpasswd() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptPrefixes are the prefixes of bcrypt hashes as written by htpasswd and most libraries
+var bcryptPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+const argon2idPrefix = "$argon2id$"
+
+// sha256Prefix marks the hashes of generated tokens, see HashToken
+const sha256Prefix = "$sha256$"
+
+// VerifyPassword checks a password against its stored form
+//
+// The stored password is either a bcrypt hash ($2a$, $2b$ or $2y$), an argon2id
+// hash in the PHC string format ($argon2id$v=19$m=65536,t=3,p=4$salt$hash), a
+// token hash created by HashToken or the plaintext password. All comparisons
+// take constant time.
+func VerifyPassword(stored, given string) bool {
+	switch {
+	case isBcrypt(stored):
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(given)) == nil
+	case strings.HasPrefix(stored, argon2idPrefix):
+		ok, err := verifyArgon2id(stored, given)
+		return ok && err == nil
+	case strings.HasPrefix(stored, sha256Prefix):
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(HashToken(given))) == 1
+	default:
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+	}
+}
+
+// VerifyUser checks the password of a user in a list of users and their stored passwords
+func VerifyUser(users map[string]string, username, password string) bool {
+	stored, ok := users[username]
+	if !ok {
+		return false
+	}
+	return VerifyPassword(stored, password)
+}
+
+// HashPassword hashes a password with bcrypt
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// GenerateToken returns a random token with 256 bits of entropy
+func GenerateToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashToken hashes a generated token with SHA-256
+//
+// Unlike HashPassword this is fast enough to verify on every request. It is
+// only safe for random tokens like the ones from GenerateToken, passwords
+// chosen by people need a slow hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return sha256Prefix + hex.EncodeToString(sum[:])
+}
+
+// IsHashed reports whether a stored password is a hash rather than plaintext
+func IsHashed(stored string) bool {
+	return isBcrypt(stored) || strings.HasPrefix(stored, argon2idPrefix) || strings.HasPrefix(stored, sha256Prefix)
+}
+
+func isBcrypt(stored string) bool {
+	for _, prefix := range bcryptPrefixes {
+		if strings.HasPrefix(stored, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyArgon2id checks a password against an argon2id hash in the PHC string format
+func verifyArgon2id(stored, given string) (bool, error) {
+	// $argon2id$v=19$m=65536,t=3,p=4$salt$hash splits into 6 parts with an empty first part
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return false, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, err
+	}
+	if version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, err
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, err
+	}
+
+	computed := argon2.IDKey([]byte(given), salt, time, memory, threads, uint32(len(hash)))
+	return subtle.ConstantTimeCompare(hash, computed) == 1, nil
+}
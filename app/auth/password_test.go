@@ -0,0 +1,82 @@
+package auth_test
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/adfinis-sygroup/mopsos/app/auth"
+)
+
+// argon2idHash is the hash of "token" with a low cost to keep the tests fast
+const argon2idHash = "$argon2id$v=19$m=8192,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$aNGJVLUP02JHM6iZmwDvaTq/tqaWNMUfNWyA1LfyDeM"
+
+func Test_VerifyPassword(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("token"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		stored string
+		given  string
+		want   bool
+	}{
+		{name: "plaintext", stored: "token", given: "token", want: true},
+		{name: "wrong plaintext", stored: "token", given: "tokenx", want: false},
+		{name: "empty plaintext", stored: "", given: "", want: true},
+		{name: "bcrypt", stored: string(bcryptHash), given: "token", want: true},
+		{name: "wrong bcrypt", stored: string(bcryptHash), given: "other", want: false},
+		{name: "bcrypt hash as password", stored: string(bcryptHash), given: string(bcryptHash), want: false},
+		{name: "htpasswd bcrypt", stored: "$2y$05$xxA78O2m3XKDKe3QZoVMNelHivAsYmdFaoi6RzEMdaMG7Idlvz1Vm", given: "token", want: true},
+		{name: "argon2id", stored: argon2idHash, given: "token", want: true},
+		{name: "wrong argon2id", stored: argon2idHash, given: "other", want: false},
+		{name: "token hash", stored: auth.HashToken("token"), given: "token", want: true},
+		{name: "wrong token hash", stored: auth.HashToken("token"), given: "other", want: false},
+		{name: "invalid argon2id", stored: "$argon2id$v=19$m=8192$salt", given: "token", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auth.VerifyPassword(tt.stored, tt.given); got != tt.want {
+				t.Errorf("VerifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_VerifyUser(t *testing.T) {
+	users := map[string]string{"cluster": "token"}
+
+	if !auth.VerifyUser(users, "cluster", "token") {
+		t.Error("expected valid credentials")
+	}
+	// unknown users must not match an empty password
+	if auth.VerifyUser(users, "unknown", "") {
+		t.Error("expected unknown user to be rejected")
+	}
+}
+
+func Test_HashPassword(t *testing.T) {
+	hash, err := auth.HashPassword("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !auth.IsHashed(hash) || !auth.VerifyPassword(hash, "token") {
+		t.Errorf("expected %q to be a valid hash of the password", hash)
+	}
+}
+
+func Test_GenerateToken(t *testing.T) {
+	a, err := auth.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := auth.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || len(a) != 43 {
+		t.Errorf("expected two distinct tokens of 43 characters, got %q and %q", a, b)
+	}
+}
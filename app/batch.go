@@ -0,0 +1,131 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	otelObs "github.com/cloudevents/sdk-go/observability/opentelemetry/v2/client"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
+	"github.com/adfinis-sygroup/mopsos/app/middleware"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+// BatchResult is the outcome of a single event of a batch
+type BatchResult struct {
+	// Index is the position of the event in the batch
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchResponse is returned for batched events
+type BatchResponse struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Results  []BatchResult `json:"results"`
+}
+
+// isBatch returns whether a request uses the CloudEvents batch content mode
+func isBatch(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == event.ApplicationCloudEventsBatchJSON
+}
+
+// HandleWebhookBatch queues the valid events of a batch and reports the outcome of every event
+//
+// Each event is validated on its own, invalid events do not prevent the
+// others from being accepted. The response is 202 Accepted if all events
+// were accepted, 207 Multi-Status if some were and 400 Bad Request if none were.
+func (s *Server) HandleWebhookBatch(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value(types.ContextUsername).(string)
+
+	raw := []json.RawMessage{}
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+		http.Error(w, "failed to decode batch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(raw) == 0 {
+		http.Error(w, "batch contains no events", http.StatusBadRequest)
+		return
+	}
+	// the request was charged for one event already
+	if !middleware.LimitEvents(w, s.limiter, username, len(raw)-1) {
+		return
+	}
+
+	response := BatchResponse{Results: make([]BatchResult, 0, len(raw))}
+	for i, data := range raw {
+		result := s.acceptBatchEvent(r, username, data)
+		result.Index = i
+		if result.Status == http.StatusAccepted {
+			response.Accepted++
+		} else {
+			response.Rejected++
+		}
+		response.Results = append(response.Results, result)
+	}
+	metrics.QueueDepth.Set(float64(s.Queue.Len()))
+	logrus.WithFields(logrus.Fields{
+		"cluster":  username,
+		"accepted": response.Accepted,
+		"rejected": response.Rejected,
+	}).Debug("received batch")
+
+	status := http.StatusMultiStatus
+	switch response.Accepted {
+	case len(raw):
+		status = http.StatusAccepted
+	case 0:
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, response)
+}
+
+// acceptBatchEvent validates and queues a single event of a batch
+func (s *Server) acceptBatchEvent(r *http.Request, username string, data json.RawMessage) BatchResult {
+	e := event.New()
+	if err := json.Unmarshal(data, &e); err != nil {
+		metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+		return BatchResult{Status: http.StatusBadRequest, Error: "failed to decode event: " + err.Error()}
+	}
+	result := BatchResult{ID: e.ID()}
+	if err := e.Validate(); err != nil {
+		metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+		result.Status, result.Error = http.StatusBadRequest, err.Error()
+		return result
+	}
+
+	record, action, err := middleware.ValidateEvent(&e, username, s.eventTypes)
+	if err != nil {
+		metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+		result.Status, result.Error = http.StatusBadRequest, err.Error()
+		if errors.Is(err, middleware.ErrClusterMismatch) {
+			result.Status = http.StatusUnauthorized
+		}
+		return result
+	}
+	if action == models.ActionIgnore {
+		result.Status = http.StatusAccepted
+		return result
+	}
+
+	if s.config.EnableTracing {
+		otelObs.InjectDistributedTracingExtension(r.Context(), e)
+	}
+	if err := s.Queue.Enqueue(models.EventData{Event: e, Record: *record, Action: action}); err != nil {
+		logrus.WithError(err).Error("failed to enqueue event")
+		result.Status, result.Error = http.StatusServiceUnavailable, "failed to enqueue event"
+		return result
+	}
+	metrics.EventsAccepted.Inc()
+	result.Status = http.StatusAccepted
+	return result
+}
@@ -0,0 +1,145 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var errNoCertificates = errors.New("no certificates found")
+
+// Reloader serves a certificate and client CA that are reloaded when their files change
+//
+// The files are checked at most once per interval during TLS handshakes, so
+// certificates renewed by i.e. cert-manager are picked up without a restart.
+// If reloading fails the previous certificate is kept.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    map[string]time.Time
+	lastCheck   time.Time
+}
+
+// NewReloader loads a certificate and key and optionally a CA bundle for verifying client certificates
+func NewReloader(certFile, keyFile, caFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+		modTimes: map[string]time.Time{},
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a server config using the reloaded files
+//
+// Client certificates are verified against the CA bundle if one was given,
+// clientAuth decides whether clients must present one.
+func (r *Reloader) TLSConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, clientCAs := r.current()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certificate},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if clientCAs != nil {
+				config.ClientCAs = clientCAs
+				config.ClientAuth = clientAuth
+			}
+			return config, nil
+		},
+	}
+}
+
+// current returns the certificate and client CAs after reloading them if their files changed
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				logrus.WithError(err).Error("failed to reload TLS certificate, keeping the previous one")
+			} else {
+				logrus.WithField("certificate", r.certFile).Info("reloaded TLS certificate")
+			}
+		}
+	}
+	return r.certificate, r.clientCAs
+}
+
+// changed reports whether any of the files was modified since it was loaded
+func (r *Reloader) changed() bool {
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads all files, the caller must hold the lock unless the reloader is not shared yet
+func (r *Reloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: %w", r.caFile, errNoCertificates)
+		}
+	}
+
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
@@ -0,0 +1,164 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adfinis-sygroup/mopsos/app/certs"
+)
+
+// issue creates a certificate signed by parent, or a self-signed CA if parent is nil
+func issue(t *testing.T, name string, parent *tls.Certificate) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// write stores a certificate and its key as PEM files, the modification time is moved to make changes visible
+func write(t *testing.T, dir string, cert *tls.Certificate, modTime time.Time) (string, string) {
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func served(t *testing.T, reloader *certs.Reloader) string {
+	config, err := reloader.TLSConfig(tls.NoClientCert).GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func Test_ReloaderReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	now := time.Now()
+
+	certFile, keyFile := write(t, dir, issue(t, "first", ca), now.Add(-time.Minute))
+	reloader, err := certs.NewReloader(certFile, keyFile, "", 0)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	if name := served(t, reloader); name != "first" {
+		t.Errorf("expected first certificate, got %s", name)
+	}
+
+	write(t, dir, issue(t, "second", ca), now)
+	if name := served(t, reloader); name != "second" {
+		t.Errorf("expected renewed certificate, got %s", name)
+	}
+
+	// a broken certificate does not replace the working one
+	if err := os.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(certFile, now.Add(time.Minute), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if name := served(t, reloader); name != "second" {
+		t.Errorf("expected previous certificate to be kept, got %s", name)
+	}
+}
+
+func Test_ReloaderVerifiesClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	certFile, keyFile := write(t, dir, issue(t, "127.0.0.1", ca), time.Now())
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloader, err := certs.NewReloader(certFile, keyFile, caFile, time.Minute)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+	}))
+	server.TLS = reloader.TLSConfig(tls.RequireAndVerifyClientCert)
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	client := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certificates,
+		}}}
+	}
+
+	res, err := client(*issue(t, "cluster-a", ca)).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected client certificate to be accepted: %v", err)
+	}
+	res.Body.Close()
+
+	if _, err := client().Get(server.URL); err == nil {
+		t.Error("expected request without client certificate to fail")
+	}
+	if _, err := client(*issue(t, "cluster-a", issue(t, "other-ca", nil))).Get(server.URL); err == nil {
+		t.Error("expected client certificate of another CA to fail")
+	}
+}
@@ -0,0 +1,234 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/auth"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// MaxActiveTokens is the number of tokens a cluster may use at the same time, the current and the previous token
+const MaxActiveTokens = 2
+
+var (
+	// ErrNotFound is returned for clusters or tokens that do not exist
+	ErrNotFound = errors.New("cluster not found")
+	// ErrExists is returned when adding a cluster with a name that is taken
+	ErrExists = errors.New("cluster already exists")
+)
+
+// Store manages clusters and their tokens in the database
+type Store struct {
+	database *gorm.DB
+}
+
+// NewStore creates a store on top of a database
+func NewStore(db *gorm.DB) *Store {
+	return &Store{database: db}
+}
+
+// Update holds the changes to a cluster, nil fields are left as they are
+type Update struct {
+	Description *string            `json:"description"`
+	Labels      *map[string]string `json:"labels"`
+	Enabled     *bool              `json:"enabled"`
+}
+
+// Add registers a new enabled cluster and returns it together with its first token
+//
+// The token is only returned once, only its hash is stored.
+func (s *Store) Add(ctx context.Context, name, description string, labels map[string]string) (*models.Cluster, string, error) {
+	if name == "" {
+		return nil, "", errors.New("cluster name must not be empty")
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	cluster := &models.Cluster{
+		Name:        name,
+		Description: description,
+		Labels:      labels,
+		Enabled:     true,
+		Tokens:      []models.ClusterToken{{Hash: hash}},
+	}
+
+	err = s.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Cluster{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrExists
+		}
+		return tx.Create(cluster).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return cluster, token, nil
+}
+
+// List returns all clusters with their tokens ordered by name
+func (s *Store) List(ctx context.Context) ([]models.Cluster, error) {
+	clusters := []models.Cluster{}
+	err := s.database.WithContext(ctx).Preload("Tokens").Order("name").Find(&clusters).Error
+	return clusters, err
+}
+
+// Get returns a cluster with its tokens
+func (s *Store) Get(ctx context.Context, name string) (*models.Cluster, error) {
+	return s.get(s.database.WithContext(ctx), name)
+}
+
+// Update changes the description, labels or state of a cluster
+func (s *Store) Update(ctx context.Context, name string, update Update) (*models.Cluster, error) {
+	var cluster *models.Cluster
+	err := s.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		cluster, err = s.get(tx, name)
+		if err != nil {
+			return err
+		}
+		if update.Description != nil {
+			cluster.Description = *update.Description
+		}
+		if update.Labels != nil {
+			cluster.Labels = *update.Labels
+		}
+		if update.Enabled != nil {
+			cluster.Enabled = *update.Enabled
+		}
+		return tx.Omit("Tokens").Save(cluster).Error
+	})
+	return cluster, err
+}
+
+// Rotate adds a new token to a cluster and returns it
+//
+// The previous token stays valid for grace so the cluster can be switched
+// over, older tokens are revoked so no more than MaxActiveTokens are active.
+func (s *Store) Rotate(ctx context.Context, name string, grace time.Duration) (string, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		cluster, err := s.get(tx, name)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(grace)
+		active := activeTokens(cluster.Tokens, now)
+		for i, t := range active {
+			switch {
+			case i < len(active)-(MaxActiveTokens-1):
+				// make room for the new token
+				t.RevokedAt = &now
+			case t.ExpiresAt == nil || t.ExpiresAt.After(expiresAt):
+				t.ExpiresAt = &expiresAt
+			default:
+				continue
+			}
+			if err := tx.Save(t).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.ClusterToken{ClusterID: cluster.ID, Hash: hash}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revoke revokes a token of a cluster, or all of its tokens if tokenID is 0
+func (s *Store) Revoke(ctx context.Context, name string, tokenID uint) error {
+	return s.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		cluster, err := s.get(tx, name)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		found := false
+		for i := range cluster.Tokens {
+			t := &cluster.Tokens[i]
+			if tokenID != 0 && t.ID != tokenID {
+				continue
+			}
+			found = true
+			if t.RevokedAt != nil {
+				continue
+			}
+			t.RevokedAt = &now
+			if err := tx.Save(t).Error; err != nil {
+				return err
+			}
+		}
+		if tokenID != 0 && !found {
+			return fmt.Errorf("token %d of cluster %s: %w", tokenID, name, ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// Verify checks a token of a cluster, the cluster must be enabled and the token active
+func (s *Store) Verify(ctx context.Context, name, token string) bool {
+	cluster, err := s.Get(ctx, name)
+	if err != nil {
+		return false
+	}
+	if !cluster.Enabled {
+		return false
+	}
+	for _, t := range activeTokens(cluster.Tokens, time.Now()) {
+		if auth.VerifyPassword(t.Hash, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) get(tx *gorm.DB, name string) (*models.Cluster, error) {
+	cluster := &models.Cluster{}
+	err := tx.Preload("Tokens", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("name = ?", name).Take(cluster).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return cluster, err
+}
+
+// activeTokens returns the tokens that are active at now, oldest first
+func activeTokens(tokens []models.ClusterToken, now time.Time) []*models.ClusterToken {
+	active := []*models.ClusterToken{}
+	for i := range tokens {
+		if tokens[i].Active(now) {
+			active = append(active, &tokens[i])
+		}
+	}
+	return active
+}
+
+func newToken() (string, string, error) {
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	return token, auth.HashToken(token), nil
+}
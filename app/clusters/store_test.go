@@ -0,0 +1,145 @@
+package clusters_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/clusters"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func newStore(t *testing.T) *clusters.Store {
+	gdb, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Cluster{}, &models.ClusterToken{}); err != nil {
+		t.Fatal(err)
+	}
+	return clusters.NewStore(gdb)
+}
+
+func Test_StoreAdd(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	cluster, token, err := store.Add(ctx, "cluster-a", "production", map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if cluster.Name != "cluster-a" || !cluster.Enabled || cluster.Labels["env"] != "prod" {
+		t.Errorf("unexpected cluster %+v", cluster)
+	}
+	if cluster.Tokens[0].Hash == token {
+		t.Error("expected the token to be stored hashed")
+	}
+	if !store.Verify(ctx, "cluster-a", token) {
+		t.Error("expected the token to be valid")
+	}
+	if store.Verify(ctx, "cluster-a", "invalid") || store.Verify(ctx, "cluster-b", token) {
+		t.Error("expected invalid credentials to be rejected")
+	}
+	if _, _, err := store.Add(ctx, "cluster-a", "", nil); !errors.Is(err, clusters.ErrExists) {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+
+	list, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 1 || list[0].Labels["env"] != "prod" || len(list[0].Tokens) != 1 {
+		t.Errorf("unexpected clusters %+v", list)
+	}
+}
+
+func Test_StoreRotate(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	_, first, err := store.Add(ctx, "cluster-a", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// during the grace period both tokens are valid
+	second, err := store.Rotate(ctx, "cluster-a", time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if !store.Verify(ctx, "cluster-a", first) || !store.Verify(ctx, "cluster-a", second) {
+		t.Error("expected both tokens to be valid during rotation")
+	}
+
+	// rotating again revokes the oldest token
+	third, err := store.Rotate(ctx, "cluster-a", time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if store.Verify(ctx, "cluster-a", first) {
+		t.Error("expected the oldest token to be revoked")
+	}
+	if !store.Verify(ctx, "cluster-a", second) || !store.Verify(ctx, "cluster-a", third) {
+		t.Error("expected the two newest tokens to be valid")
+	}
+
+	// without grace period the previous token expires right away
+	fourth, err := store.Rotate(ctx, "cluster-a", 0)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if store.Verify(ctx, "cluster-a", third) || !store.Verify(ctx, "cluster-a", fourth) {
+		t.Error("expected only the new token to be valid")
+	}
+
+	if _, err := store.Rotate(ctx, "cluster-b", time.Hour); !errors.Is(err, clusters.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func Test_StoreRevokeAndDisable(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	cluster, first, err := store.Add(ctx, "cluster-a", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Rotate(ctx, "cluster-a", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Revoke(ctx, "cluster-a", cluster.Tokens[0].ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if store.Verify(ctx, "cluster-a", first) || !store.Verify(ctx, "cluster-a", second) {
+		t.Error("expected only the revoked token to be invalid")
+	}
+	if err := store.Revoke(ctx, "cluster-a", 42); !errors.Is(err, clusters.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown token, got %v", err)
+	}
+
+	disabled := false
+	if _, err := store.Update(ctx, "cluster-a", clusters.Update{Enabled: &disabled}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if store.Verify(ctx, "cluster-a", second) {
+		t.Error("expected disabled cluster to be rejected")
+	}
+	enabled := true
+	if _, err := store.Update(ctx, "cluster-a", clusters.Update{Enabled: &enabled}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if err := store.Revoke(ctx, "cluster-a", 0); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if store.Verify(ctx, "cluster-a", second) {
+		t.Error("expected all tokens to be revoked")
+	}
+}
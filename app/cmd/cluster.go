@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/adfinis-sygroup/mopsos/app/clusters"
+)
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Manage the clusters that may send events",
+}
+
+var clusterAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a cluster and print its token",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		description, err := cmd.Flags().GetString("description")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		labels, err := cmd.Flags().GetStringToString("label")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		store := clusters.NewStore(openDatabase(cmd))
+		_, token, err := store.Add(context.Background(), args[0], description, labels)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to add cluster")
+		}
+		fmt.Println(token)
+	},
+}
+
+var clusterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List clusters and their tokens",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		list, err := clusters.NewStore(openDatabase(cmd)).List(context.Background())
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to list clusters")
+		}
+
+		switch output {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(list)
+		case "table":
+			now := time.Now()
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tENABLED\tACTIVE TOKENS\tLABELS\tDESCRIPTION")
+			for _, cluster := range list {
+				active := []string{}
+				for _, token := range cluster.Tokens {
+					if token.Active(now) {
+						active = append(active, strconv.FormatUint(uint64(token.ID), 10))
+					}
+				}
+				fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\n",
+					cluster.Name, cluster.Enabled, strings.Join(active, ","),
+					formatLabels(cluster.Labels), cluster.Description)
+			}
+			err = w.Flush()
+		default:
+			logrus.Fatalf("invalid output format: %s", output)
+		}
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	},
+}
+
+var clusterRotateCmd = &cobra.Command{
+	Use:   "rotate <name>",
+	Short: "Add a new token to a cluster and print it, the previous token stays valid for the grace period",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		grace, err := cmd.Flags().GetDuration("grace")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		store := clusters.NewStore(openDatabase(cmd))
+		token, err := store.Rotate(context.Background(), args[0], grace)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to rotate token")
+		}
+		fmt.Println(token)
+	},
+}
+
+var clusterRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke a token of a cluster, or all of its tokens",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tokenID, err := cmd.Flags().GetUint("token-id")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		store := clusters.NewStore(openDatabase(cmd))
+		if err := store.Revoke(context.Background(), args[0], tokenID); err != nil {
+			logrus.WithError(err).Fatal("failed to revoke token")
+		}
+	},
+}
+
+var clusterEnableCmd = &cobra.Command{
+	Use:   "enable <name>",
+	Short: "Allow a disabled cluster to send events again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setClusterEnabled(cmd, args[0], true)
+	},
+}
+
+var clusterDisableCmd = &cobra.Command{
+	Use:   "disable <name>",
+	Short: "Reject events of a cluster without revoking its tokens",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setClusterEnabled(cmd, args[0], false)
+	},
+}
+
+func setClusterEnabled(cmd *cobra.Command, name string, enabled bool) {
+	store := clusters.NewStore(openDatabase(cmd))
+	if _, err := store.Update(context.Background(), name, clusters.Update{Enabled: &enabled}); err != nil {
+		logrus.WithError(err).Fatal("failed to update cluster")
+	}
+}
+
+// formatLabels formats labels as a sorted, comma-separated list of key=value pairs
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
+)
+
+const upstreamChartMappingUsage = "Comma-separated list of applications that are not named after their chart, e.g. 'app1=chart1,app2=chart2'"
+
+var deadLetterCmd = &cobra.Command{
+	Use:   "deadletter",
+	Short: "Inspect and replay events that could not be stored",
+}
+
+var deadLetterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List dead-lettered events",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		query := openDatabase(cmd).Order("id")
+		if !all {
+			query = query.Where("replayed_at IS NULL")
+		}
+		letters := []models.DeadLetter{}
+		if err := query.Find(&letters).Error; err != nil {
+			logrus.WithError(err).Fatal("failed to list dead letters")
+		}
+
+		switch output {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(letters)
+		case "table":
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tCREATED\tCLUSTER\tEVENT\tATTEMPTS\tREPLAYED\tERROR")
+			for _, letter := range letters {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%t\t%s\n",
+					letter.ID, letter.CreatedAt.Format("2006-01-02 15:04:05"), letter.ClusterName,
+					letter.EventID, letter.Attempts, letter.ReplayedAt != nil, letter.Error)
+			}
+			err = w.Flush()
+		default:
+			logrus.Fatalf("invalid output format: %s", output)
+		}
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	},
+}
+
+var deadLetterReplayCmd = &cobra.Command{
+	Use:   "replay [id...]",
+	Short: "Store dead-lettered events again",
+	Run: func(cmd *cobra.Command, args []string) {
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		if all == (len(args) > 0) {
+			logrus.Fatal("pass either dead letter ids or --all")
+		}
+		mapping, err := cmd.Flags().GetStringToString("upstream-chart-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		productMapping, err := cmd.Flags().GetStringToString("lifecycle-product-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		dbConn := openDatabase(cmd)
+		query := dbConn.Order("id").Where("replayed_at IS NULL")
+		if !all {
+			ids := make([]uint64, len(args))
+			for i, arg := range args {
+				if ids[i], err = strconv.ParseUint(arg, 10, 64); err != nil {
+					logrus.Fatalf("invalid dead letter id: %s", arg)
+				}
+			}
+			query = query.Where("id IN ?", ids)
+		}
+		letters := []models.DeadLetter{}
+		if err := query.Find(&letters).Error; err != nil {
+			logrus.WithError(err).Fatal("failed to list dead letters")
+		}
+
+		// replayed records are evaluated against the rules, upstream releases and release cycles the server stored
+		handler := mopsos.NewHandler(false, dbConn).
+			WithCompliance(compliance.NewEngine(dbConn)).
+			WithUpstream(upstream.NewTracker(dbConn, nil, mapping)).
+			WithLifecycle(lifecycle.NewChecker(dbConn, productMapping))
+		failed := 0
+		for i := range letters {
+			log := logrus.WithField("id", letters[i].ID)
+			if err := handler.Replay(&letters[i]); err != nil {
+				log.WithError(err).Error("failed to replay dead letter")
+				failed++
+				continue
+			}
+			log.Info("replayed dead letter")
+		}
+		fmt.Printf("replayed %d of %d dead letters\n", len(letters)-failed, len(letters))
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/adfinis-sygroup/mopsos/app/report"
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift [application...]",
+	Short: "Compare the installed versions of applications across clusters",
+	Long: "Compare the installed versions of applications across clusters. " +
+		"Prints the clusters grouped by version for each application and lists the clusters that are behind the newest version.",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		drifting, err := cmd.Flags().GetBool("drifting")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		drift, err := report.Drift(context.Background(), openDatabase(cmd), args)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to build drift report")
+		}
+		if drifting {
+			drift = report.OnlyDrifting(drift)
+		}
+
+		switch output {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(drift)
+		case "table":
+			err = printDrift(drift)
+		default:
+			logrus.Fatalf("invalid output format: %s", output)
+		}
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	},
+}
+
+// printDrift writes the report as a table with one row per application and version
+func printDrift(drift []report.ApplicationDrift) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLICATION\tVERSION\tGAP\tCLUSTERS")
+	for _, application := range drift {
+		for _, group := range application.Versions {
+			gap := "-"
+			for _, laggard := range application.Laggards {
+				if laggard.ApplicationVersion == group.Version {
+					gap = fmt.Sprintf("%d %s", laggard.Gap.Behind, laggard.Gap.Level)
+					break
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", application.ApplicationName, group.Version, gap, strings.Join(group.Clusters, ","))
+		}
+	}
+	return w.Flush()
+}
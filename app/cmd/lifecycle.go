@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/report"
+)
+
+const lifecycleProductMappingUsage = "Comma-separated list of applications that are not named after their product in the lifecycle dataset, e.g. 'redis-ha=redis,postgres=postgresql'"
+
+var lifecycleCmd = &cobra.Command{
+	Use:   "lifecycle",
+	Short: "Manage the release cycles of products used to check for end of life",
+}
+
+var lifecycleImportCmd = &cobra.Command{
+	Use:   "import path",
+	Short: "Import the release cycles of products from a file or directory",
+	Long: "Import the release cycles of products in the format of the endoflife.date API from a file mapping products " +
+		"to their cycles or a directory with a file per product. The imported cycles replace all previously imported ones " +
+		"and the cycles of all records are updated.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mapping, err := cmd.Flags().GetStringToString("lifecycle-product-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		products, err := lifecycle.Load(args[0])
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to load lifecycle dataset")
+		}
+		dbConn := openDatabase(cmd)
+		if err := lifecycle.Import(context.Background(), dbConn, products); err != nil {
+			logrus.WithError(err).Fatal("failed to import lifecycle dataset")
+		}
+		if err := lifecycle.NewChecker(dbConn, mapping).EvaluateAll(context.Background()); err != nil {
+			logrus.WithError(err).Fatal("failed to update the cycles of the records")
+		}
+		fmt.Printf("imported %d products\n", len(products))
+	},
+}
+
+var eolCmd = &cobra.Command{
+	Use:   "eol",
+	Short: "List the records that are out of support or soon will be",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		within, err := cmd.Flags().GetInt("within")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		mapping, err := cmd.Flags().GetStringToString("lifecycle-product-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		dbConn := openDatabase(cmd)
+		expiring, err := report.EndOfLife(context.Background(), dbConn, lifecycle.NewChecker(dbConn, mapping), within, time.Now())
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to build eol report")
+		}
+
+		switch output {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(expiring)
+		case "table":
+			err = printEOL(expiring)
+		default:
+			logrus.Fatalf("invalid output format: %s", output)
+		}
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	},
+}
+
+// printEOL writes the report as a table with one row per record
+func printEOL(expiring []report.Expiring) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tAPPLICATION\tVERSION\tPRODUCT\tCYCLE\tEOL\tDAYS")
+	for _, e := range expiring {
+		eol, days := "yes", "-"
+		if e.EOLDate != nil {
+			eol = e.EOLDate.Format("2006-01-02")
+		}
+		if e.DaysUntilEOL != nil {
+			days = fmt.Sprint(*e.DaysUntilEOL)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.ClusterName, e.ApplicationName, e.ApplicationVersion, e.Product, e.Cycle, eol, days)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+var osvCmd = &cobra.Command{
+	Use:   "osv",
+	Short: "Manage the vulnerabilities imported from OSV advisories",
+}
+
+var osvImportCmd = &cobra.Command{
+	Use:   "import path",
+	Short: "Import OSV advisories from a directory, JSON file or archive",
+	Long: "Import OSV advisories from a directory, JSON file or zip or tar.gz archive, " +
+		"e.g. the all.zip of an ecosystem from osv.dev. The imported advisories replace all previously imported ones.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		advisories, err := osv.Load(args[0])
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to load advisories")
+		}
+
+		if err := osv.Import(context.Background(), openDatabase(cmd), advisories); err != nil {
+			logrus.WithError(err).Fatal("failed to import advisories")
+		}
+		fmt.Printf("imported %d advisories\n", len(advisories))
+	},
+}
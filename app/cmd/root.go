@@ -1,87 +1,212 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 
 	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/auth"
 	"github.com/adfinis-sygroup/mopsos/app/db"
 	"github.com/adfinis-sygroup/mopsos/app/instrumentation"
 )
 
 const envPrefix = "MOPSOS"
 
+// redacted replaces the values of secrets in logs
+const redacted = "<redacted>"
+
+// secretFlags are flags whose values must never be logged
+var secretFlags = map[string]bool{
+	"db-dsn":                true,
+	"http-basic-auth-users": true,
+	"http-admin-users":      true,
+	"http-reader-users":     true,
+	"http-hmac-secrets":     true,
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "mopsos",
 	Short: "Mopsos receives events and stores them in a database",
 	Long:  "Mopsos receives events and stores them in a database for later analysis.",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		return initConfig(cmd)
+		if err := initConfig(cmd); err != nil {
+			return err
+		}
+		// check logging early so we can use it from here on out
+		initLogging(cmd)
+		return nil
 	},
 	// Run builds the applications object composition and starts the server
 	Run: func(cmd *cobra.Command, args []string) {
+		// read DB flags
+		provider, dsn, migrate := databaseFlags(cmd)
 
-		// check logging early so we can use it from here on out
-		logrus.SetLevel(logrus.WarnLevel)
-		verboseMode, err := cmd.Flags().GetBool("verbose")
+		// read http flags
+		listener := cmd.Flag("http-listener").Value.String()
+
+		// read otel flags
+		enableTracing, err := cmd.Flags().GetBool("otel")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		tracingTarget, err := cmd.Flags().GetString("otel-collector")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		// read basic auth flags
+		basicAuthUsers := basicAuthFlag(cmd, "http-basic-auth-users", "http-basic-auth-file")
+		adminUsers := basicAuthFlag(cmd, "http-admin-users", "http-admin-users-file")
+		readerUsers := basicAuthFlag(cmd, "http-reader-users", "http-reader-users-file")
+		rbacFile := cmd.Flag("rbac-file").Value.String()
+		webhookAuth, err := cmd.Flags().GetStringSlice("http-webhook-auth")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		hmacSecrets := credentialsFlag(cmd, "http-hmac-secrets", "http-hmac-secrets-file")
+		hmacMaxSkew, err := cmd.Flags().GetDuration("http-hmac-max-skew")
 		if err != nil {
-			logrus.WithError(err).Fatal("failed to get verbose flag")
+			logrus.Fatal(err)
+		}
+		// a skew of 0 or less would reject every signature
+		if hmacMaxSkew <= 0 {
+			logrus.Fatalf("--http-hmac-max-skew must be greater than 0, got %s", hmacMaxSkew)
 		}
-		if verboseMode {
-			logrus.SetLevel(logrus.InfoLevel)
+		webhookRateLimit := cmd.Flag("http-webhook-rate-limit").Value.String()
+		webhookRateLimits, err := cmd.Flags().GetStringToString("http-webhook-rate-limits")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		webhookMaxConcurrency, err := cmd.Flags().GetInt("http-webhook-max-concurrency")
+		if err != nil {
+			logrus.Fatal(err)
 		}
-		debugMode, err := cmd.Flags().GetBool("debug")
+		webhookMaxBodySize, err := cmd.Flags().GetInt64("http-webhook-max-body-size")
 		if err != nil {
-			logrus.WithError(err).Fatal("failed to get debug flag")
+			logrus.Fatal(err)
+		}
+		eventTypes, err := cmd.Flags().GetStringToString("event-types")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		jwtIssuers, err := cmd.Flags().GetStringToString("http-jwt-issuers")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		jwtAudiences, err := cmd.Flags().GetStringSlice("http-jwt-audiences")
+		if err != nil {
+			logrus.Fatal(err)
 		}
-		if debugMode {
-			logrus.SetLevel(logrus.DebugLevel)
-			logrus.Debug("Debug mode enabled")
-			cmd.Flags().VisitAll(func(f *pflag.Flag) {
-				logrus.Debugf("flag '%s': %s", f.Name, f.Value.String())
-			})
+		jwtClusterClaim := cmd.Flag("http-jwt-cluster-claim").Value.String()
+		jwtClusterMapping, err := cmd.Flags().GetStringToString("http-jwt-cluster-mapping")
+		if err != nil {
+			logrus.Fatal(err)
 		}
 
-		// read DB flags
-		provider := cmd.Flag("db-provider").Value.String()
-		dsn := cmd.Flag("db-dsn").Value.String()
-		migrate, err := cmd.Flags().GetBool("db-migrate")
+		// read tls flags
+		tlsCertFile := cmd.Flag("http-tls-cert").Value.String()
+		tlsKeyFile := cmd.Flag("http-tls-key").Value.String()
+		tlsClientCAFile := cmd.Flag("http-tls-client-ca").Value.String()
+		tlsClientAuth := cmd.Flag("http-tls-client-auth").Value.String()
+		tlsClientMapping, err := cmd.Flags().GetStringToString("http-tls-client-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		shutdownTimeout, err := cmd.Flags().GetDuration("shutdown-timeout")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		apiPublic, err := cmd.Flags().GetBool("http-api-public")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		metricsInventory, err := cmd.Flags().GetBool("http-metrics-inventory")
 		if err != nil {
 			logrus.Fatal(err)
 		}
 
-		// read http flags
-		listener := cmd.Flag("http-listener").Value.String()
+		// read queue flags
+		queueProvider := cmd.Flag("queue-provider").Value.String()
+		queuePath := cmd.Flag("queue-path").Value.String()
+		queueSize, err := cmd.Flags().GetInt("queue-size")
+		if err != nil {
+			logrus.Fatal(err)
+		}
 
-		// read otel flags
-		enableTracing, err := cmd.Flags().GetBool("otel")
+		// read handler flags
+		handlerRetries, err := cmd.Flags().GetInt("handler-retries")
 		if err != nil {
 			logrus.Fatal(err)
 		}
-		tracingTarget, err := cmd.Flags().GetString("otel-collector")
+		handlerRetryBackoff, err := cmd.Flags().GetDuration("handler-retry-backoff")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		handlerWorkers, err := cmd.Flags().GetInt("handler-workers")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		handlerBatchSize, err := cmd.Flags().GetInt("handler-batch-size")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		handlerBatchWindow, err := cmd.Flags().GetDuration("handler-batch-window")
 		if err != nil {
 			logrus.Fatal(err)
 		}
 
-		// read basic auth flags
-		basicAuthUsers := make(map[string]string)
-		authString, err := cmd.Flags().GetString("http-basic-auth-users")
+		// read upstream flags
+		upstreamSources, err := cmd.Flags().GetStringSlice("upstream-helm-index")
 		if err != nil {
 			logrus.Fatal(err)
 		}
-		if authString != "" {
-			for _, user := range strings.Split(authString, ",") {
-				userParts := strings.Split(user, ":")
-				if len(userParts) != 2 {
-					logrus.Fatalf("invalid basic auth user: %s", user)
-				}
-				basicAuthUsers[userParts[0]] = userParts[1]
-			}
+		upstreamInterval := intervalFlag(cmd, "upstream-interval")
+		upstreamChartMapping, err := cmd.Flags().GetStringToString("upstream-chart-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		// read osv flags
+		osvPath, err := cmd.Flags().GetString("osv-path")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		osvInterval := intervalFlag(cmd, "osv-interval")
+		osvNameMapping, err := cmd.Flags().GetStringToString("osv-name-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		osvDefaultEcosystem, err := cmd.Flags().GetString("osv-default-ecosystem")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		// read lifecycle flags
+		lifecyclePath, err := cmd.Flags().GetString("lifecycle-path")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		lifecycleInterval := intervalFlag(cmd, "lifecycle-interval")
+		lifecycleProductMapping, err := cmd.Flags().GetStringToString("lifecycle-product-mapping")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		// read compliance flags
+		complianceRulesFile, err := cmd.Flags().GetString("compliance-rules-file")
+		if err != nil {
+			logrus.Fatal(err)
 		}
 
 		// build config struct
@@ -92,15 +217,70 @@ var rootCmd = &cobra.Command{
 
 			HttpListener:   listener,
 			BasicAuthUsers: basicAuthUsers,
+			AdminUsers:     adminUsers,
+			ReaderUsers:    readerUsers,
+			RBACFile:       rbacFile,
+			APIPublic:      apiPublic,
+
+			MetricsInventory: metricsInventory,
+
+			WebhookAuth: webhookAuth,
+			HMACSecrets: hmacSecrets,
+			HMACMaxSkew: hmacMaxSkew,
+
+			WebhookRateLimit:      webhookRateLimit,
+			WebhookRateLimits:     webhookRateLimits,
+			WebhookMaxConcurrency: webhookMaxConcurrency,
+			WebhookMaxBodySize:    webhookMaxBodySize,
+
+			EventTypes: eventTypes,
+
+			JWTIssuers:        jwtIssuers,
+			JWTAudiences:      jwtAudiences,
+			JWTClusterClaim:   jwtClusterClaim,
+			JWTClusterMapping: jwtClusterMapping,
+
+			TLSCertFile:      tlsCertFile,
+			TLSKeyFile:       tlsKeyFile,
+			TLSClientCAFile:  tlsClientCAFile,
+			TLSClientAuth:    tlsClientAuth,
+			TLSClientMapping: tlsClientMapping,
+
+			ShutdownTimeout: shutdownTimeout,
+
+			QueueProvider: queueProvider,
+			QueuePath:     queuePath,
+			QueueSize:     queueSize,
+
+			HandlerRetries:      handlerRetries,
+			HandlerRetryBackoff: handlerRetryBackoff,
+			HandlerWorkers:      handlerWorkers,
+			HandlerBatchSize:    handlerBatchSize,
+			HandlerBatchWindow:  handlerBatchWindow,
 
 			EnableTracing: enableTracing,
 			TracingTarget: tracingTarget,
+
+			UpstreamSources:      upstreamSources,
+			UpstreamInterval:     upstreamInterval,
+			UpstreamChartMapping: upstreamChartMapping,
+
+			OSVPath:             osvPath,
+			OSVInterval:         osvInterval,
+			OSVNameMapping:      osvNameMapping,
+			OSVDefaultEcosystem: osvDefaultEcosystem,
+
+			LifecyclePath:           lifecyclePath,
+			LifecycleInterval:       lifecycleInterval,
+			LifecycleProductMapping: lifecycleProductMapping,
+
+			ComplianceRulesFile: complianceRulesFile,
 		}
 		log := logrus.WithField("config", fmt.Sprintf("%+v", cfg))
 
+		shutdownTracing := func() {}
 		if enableTracing {
-			shutdown := instrumentation.InitInstrumentation(cfg.TracingTarget)
-			defer shutdown()
+			shutdownTracing = instrumentation.InitInstrumentation(cfg.TracingTarget)
 		}
 
 		// prepare gorm.io database connection
@@ -117,20 +297,79 @@ var rootCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		// run main loop of the application (server and event receiver)
-		app.Run()
+		// run main loop of the application (server and event receiver) until we are asked to stop
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		err = app.Run(ctx)
+
+		// clean up after the queue is drained so no events are lost
+		closeDatabase(dbConn)
+		shutdownTracing()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Info("Shutdown complete")
 	},
 }
 
 func Execute() {
 	// database flags
-	rootCmd.Flags().String("db-provider", "sqlite", "Database provider, either 'sqlite' or 'postgres'")
-	rootCmd.Flags().String("db-dsn", "file::memory:?cache=shared", "Database DSN")
-	rootCmd.Flags().Bool("db-migrate", true, "Migrate database schema on startup")
+	rootCmd.PersistentFlags().String("db-provider", "sqlite", "Database provider, either 'sqlite' or 'postgres'")
+	rootCmd.PersistentFlags().String("db-dsn", "file::memory:?cache=shared", "Database DSN")
+	rootCmd.PersistentFlags().Bool("db-migrate", true, "Migrate database schema on startup")
 
 	// webserver flags
 	rootCmd.Flags().String("http-listener", ":8080", "HTTP listener")
 	rootCmd.Flags().String("http-basic-auth-users", "", "Comma-separated list of clusters and tokens, e.g. 'cluster1:token1,cluster2:token2'")
+	rootCmd.Flags().String("http-basic-auth-file", "", "htpasswd file with clusters and bcrypt or argon2id hashed tokens, e.g. created with 'htpasswd -B'. Merged with --http-basic-auth-users")
+	rootCmd.Flags().StringSlice("http-webhook-auth", []string{"basic"}, "Authenticators of the webhook in the order they are tried, 'basic', 'hmac', 'mtls' and/or 'jwt'")
+	rootCmd.Flags().String("http-hmac-secrets", "", "Comma-separated list of clusters and secrets for HMAC signed webhooks, e.g. 'cluster1:secret1'")
+	rootCmd.Flags().String("http-hmac-secrets-file", "", "File with a cluster:secret pair per line for HMAC signed webhooks. Merged with --http-hmac-secrets")
+	rootCmd.Flags().Duration("http-hmac-max-skew", 5*time.Minute, "Maximum age of an HMAC signature, signatures are also rejected if they are used twice within this time")
+	rootCmd.Flags().String("http-webhook-rate-limit", "0", "Events per second each cluster may send as 'rate' or 'rate:burst', e.g. '5:50'. 0 disables the limit")
+	rootCmd.Flags().StringToString("http-webhook-rate-limits", map[string]string{}, "Comma-separated list of clusters and their rate limits, overriding --http-webhook-rate-limit, e.g. 'cluster1=20:200'")
+	rootCmd.Flags().Int("http-webhook-max-concurrency", 0, "Maximum number of webhook requests handled at once, further requests are rejected. 0 disables the limit")
+	rootCmd.Flags().Int64("http-webhook-max-body-size", 1<<20, "Maximum size of a webhook request body in bytes. 0 disables the limit")
+	rootCmd.Flags().StringToString("event-types", map[string]string{}, "Comma-separated list of CloudEvent types and their action, 'upsert', 'delete' or 'ignore', e.g. 'app.deleted=delete'. "+
+		"Events of other types are rejected. Defaults to 'cloud.adfinis.mopsos.updateRecord=upsert,cloud.adfinis.mopsos.deleteRecord=delete'")
+	rootCmd.Flags().StringToString("http-jwt-issuers", map[string]string{}, "Comma-separated list of trusted JWT issuers and their JWKS URL or file for the 'jwt' webhook authenticator, "+
+		"e.g. 'https://kubernetes.default.svc=https://cluster1.example.com/openid/v1/jwks'")
+	rootCmd.Flags().StringSlice("http-jwt-audiences", []string{"mopsos"}, "Audiences accepted in JWTs, a token must be meant for at least one of them")
+	rootCmd.Flags().String("http-jwt-cluster-claim", "iss", "JWT claim holding the cluster name, e.g. 'iss' or 'sub'")
+	rootCmd.Flags().StringToString("http-jwt-cluster-mapping", map[string]string{}, "Comma-separated list of cluster claim values and their clusters, e.g. 'https://issuer1=cluster1'. "+
+		"Without mapping the claim is the cluster name")
+	rootCmd.Flags().String("http-admin-users", "", "Comma-separated list of admin users and tokens for the admin API, e.g. 'admin1:token1'. The admin API is disabled without admin users")
+	rootCmd.Flags().String("http-admin-users-file", "", "htpasswd file with admin users and hashed tokens. Merged with --http-admin-users")
+	rootCmd.Flags().String("http-reader-users", "", "Comma-separated list of users and tokens for the read API, e.g. 'reader1:token1'. "+
+		"Admin users may read as well")
+	rootCmd.Flags().String("http-reader-users-file", "", "htpasswd file with reader users and hashed tokens. Merged with --http-reader-users")
+	rootCmd.Flags().String("rbac-file", "", "YAML file with the roles (ingest, reader or admin) and the clusters or cluster labels users may read")
+	rootCmd.Flags().Bool("http-api-public", false, "Leave the read API open to everyone. Without it the read API requires a reader or admin user, "+
+		"mopsos refuses to start if there is none")
+	rootCmd.Flags().Bool("http-metrics-inventory", false, "Serve the versions, vulnerabilities and compliance results of all records on /metrics. "+
+		"Once enabled /metrics requires read access to all clusters unless the read API is public")
+	rootCmd.Flags().Duration("shutdown-timeout", 25*time.Second, "Time to wait for running requests and queued events on SIGTERM, should be shorter than the termination grace period of the pod")
+
+	// tls flags
+	rootCmd.Flags().String("http-tls-cert", "", "TLS certificate file, enables TLS together with --http-tls-key. The certificate is reloaded when the file changes")
+	rootCmd.Flags().String("http-tls-key", "", "TLS private key file")
+	rootCmd.Flags().String("http-tls-client-ca", "", "CA bundle for verifying client certificates, required by the 'mtls' webhook authenticator")
+	rootCmd.Flags().String("http-tls-client-auth", "optional", "Whether clients must present a certificate, either 'optional' or 'require'")
+	rootCmd.Flags().StringToString("http-tls-client-mapping", map[string]string{}, "Comma-separated list of client certificate names (CN or DNS SAN) and their clusters, e.g. 'cert1=cluster1'. "+
+		"Without mapping the CN is the cluster name")
+
+	// queue flags
+	rootCmd.Flags().String("queue-provider", "memory", "Queue between webhook and database, either 'memory' or 'file'. "+
+		"The file queue keeps accepted events on disk until they are stored so they survive restarts")
+	rootCmd.Flags().String("queue-path", "mopsos-queue.log", "Path of the write-ahead log of the file queue")
+	rootCmd.Flags().Int("queue-size", 1000, "Maximum number of events waiting in the queue, the webhook blocks while the queue is full")
+
+	// handler flags
+	rootCmd.Flags().Int("handler-retries", 5, "Number of retries when storing an event fails before it is moved to the dead letters")
+	rootCmd.Flags().Duration("handler-retry-backoff", time.Second, "Backoff before the first retry, doubles with every retry")
+	rootCmd.Flags().Int("handler-workers", 1, "Number of workers storing events concurrently, events of the same application are always stored in order")
+	rootCmd.Flags().Int("handler-batch-size", 0, "Maximum number of events each worker stores in one transaction, 0 disables batching")
+	rootCmd.Flags().Duration("handler-batch-window", 100*time.Millisecond, "Maximum time a worker waits for a batch to fill up")
 
 	// otel flags
 	rootCmd.Flags().Bool("otel", false, "Enable OpenTelemetry tracing")
@@ -138,15 +377,195 @@ func Execute() {
 		`On a local cluster the collector should be accessible through a NodePort service at the localhost:30078 `+
 		`endpoint. Otherwise replace localhost with the collector endpoint.`)
 
+	// upstream flags
+	rootCmd.Flags().StringSlice("upstream-helm-index", []string{}, "Comma-separated list of Helm repository URLs or local index.yaml files to check for new releases")
+	rootCmd.Flags().Duration("upstream-interval", time.Hour, "Interval between upstream release checks")
+	rootCmd.Flags().StringToString("upstream-chart-mapping", map[string]string{}, upstreamChartMappingUsage)
+
+	// osv flags
+	rootCmd.Flags().String("osv-path", "", "Directory, JSON file or zip or tar.gz archive with OSV advisories to import, e.g. the all.zip of an ecosystem from osv.dev")
+	rootCmd.Flags().Duration("osv-interval", 24*time.Hour, "Interval between imports of the OSV advisories")
+	rootCmd.Flags().StringToString("osv-name-mapping", map[string]string{}, "Comma-separated list of applications and images and the OSV package they are affected by, optionally prefixed with its ecosystem, "+
+		"e.g. 'cert-manager=Go:github.com/cert-manager/cert-manager,quay.io/jetstack/cert-manager-controller=Go:github.com/cert-manager/cert-manager'")
+	rootCmd.Flags().String("osv-default-ecosystem", "", "OSV ecosystem of the applications and mapped packages without ecosystem, e.g. 'Go'. "+
+		"Applications that are not mapped are only matched if it is set")
+
+	// lifecycle flags
+	rootCmd.Flags().String("lifecycle-path", "", "File or directory with the release cycles of products in the format of the endoflife.date API to import")
+	rootCmd.Flags().Duration("lifecycle-interval", 24*time.Hour, "Interval between imports of the lifecycle dataset")
+	rootCmd.Flags().StringToString("lifecycle-product-mapping", map[string]string{}, lifecycleProductMappingUsage)
+
+	// compliance flags
+	rootCmd.Flags().String("compliance-rules-file", "", "YAML file with compliance rules, rules from the file can't be changed using the admin API")
+
 	// logging flags
-	rootCmd.Flags().Bool("debug", false, "Enable debug mode")
-	rootCmd.Flags().Bool("verbose", false, "Enable verbose mode")
+	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug mode")
+	rootCmd.PersistentFlags().Bool("verbose", false, "Enable verbose mode")
+
+	// dead letter commands
+	deadLetterListCmd.Flags().StringP("output", "o", "table", "Output format, either 'table' or 'json'")
+	deadLetterListCmd.Flags().Bool("all", false, "Also list dead letters that have already been replayed")
+	deadLetterReplayCmd.Flags().Bool("all", false, "Replay all dead letters that have not been replayed yet")
+	deadLetterReplayCmd.Flags().StringToString("upstream-chart-mapping", map[string]string{}, upstreamChartMappingUsage)
+	deadLetterReplayCmd.Flags().StringToString("lifecycle-product-mapping", map[string]string{}, lifecycleProductMappingUsage)
+	deadLetterCmd.AddCommand(deadLetterListCmd, deadLetterReplayCmd)
+	rootCmd.AddCommand(deadLetterCmd)
+
+	// report commands
+	driftCmd.Flags().StringP("output", "o", "table", "Output format, either 'table' or 'json'")
+	driftCmd.Flags().Bool("drifting", false, "Only show applications that run in more than one version")
+	rootCmd.AddCommand(driftCmd)
+
+	// cluster commands
+	clusterAddCmd.Flags().String("description", "", "Description of the cluster")
+	clusterAddCmd.Flags().StringToString("label", map[string]string{}, "Labels of the cluster, e.g. 'env=prod,team=platform'")
+	clusterListCmd.Flags().StringP("output", "o", "table", "Output format, either 'table' or 'json'")
+	clusterRotateCmd.Flags().Duration("grace", 24*time.Hour, "Time the previous token stays valid")
+	clusterRevokeCmd.Flags().Uint("token-id", 0, "Token to revoke as shown by 'cluster list', all tokens are revoked if not set")
+	clusterCmd.AddCommand(clusterAddCmd, clusterListCmd, clusterRotateCmd, clusterRevokeCmd, clusterEnableCmd, clusterDisableCmd)
+	rootCmd.AddCommand(clusterCmd)
+
+	// lifecycle commands
+	eolCmd.Flags().StringP("output", "o", "table", "Output format, either 'table' or 'json'")
+	eolCmd.Flags().Int("within", 90, "Include records that reach their end of life within this number of days")
+	eolCmd.Flags().StringToString("lifecycle-product-mapping", map[string]string{}, lifecycleProductMappingUsage)
+	lifecycleImportCmd.Flags().StringToString("lifecycle-product-mapping", map[string]string{}, lifecycleProductMappingUsage)
+	lifecycleCmd.AddCommand(lifecycleImportCmd)
+	rootCmd.AddCommand(lifecycleCmd, eolCmd)
+
+	// osv commands
+	osvCmd.AddCommand(osvImportCmd)
+	rootCmd.AddCommand(osvCmd)
+
+	// token commands
+	rootCmd.AddCommand(hashTokenCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		logrus.Fatal(err)
 	}
 }
 
+// initLogging sets the log level from the verbose and debug flags
+func initLogging(cmd *cobra.Command) {
+	logrus.SetLevel(logrus.WarnLevel)
+	verboseMode, err := cmd.Flags().GetBool("verbose")
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to get verbose flag")
+	}
+	if verboseMode {
+		logrus.SetLevel(logrus.InfoLevel)
+	}
+	debugMode, err := cmd.Flags().GetBool("debug")
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to get debug flag")
+	}
+	if debugMode {
+		logrus.SetLevel(logrus.DebugLevel)
+		logrus.Debug("Debug mode enabled")
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			value := f.Value.String()
+			if secretFlags[f.Name] && value != "" {
+				value = redacted
+			}
+			logrus.Debugf("flag '%s': %s", f.Name, value)
+		})
+	}
+}
+
+// basicAuthFlag reads users and tokens from a comma-separated list of user:token pairs and an htpasswd file
+//
+// Tokens may be plaintext or bcrypt hashes, argon2id hashes contain commas
+// and are only supported in the file.
+func basicAuthFlag(cmd *cobra.Command, name string, fileName string) map[string]string {
+	users := credentialsFlag(cmd, name, fileName)
+	for user, token := range users {
+		if !auth.IsHashed(token) {
+			logrus.WithField("user", user).Warnf("plaintext token in --%s, consider a hashed token", name)
+		}
+	}
+	return users
+}
+
+// credentialsFlag reads a comma-separated list of user:secret pairs and merges it with a file of such pairs
+func credentialsFlag(cmd *cobra.Command, name string, fileName string) map[string]string {
+	users := make(map[string]string)
+	path, err := cmd.Flags().GetString(fileName)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if path != "" {
+		users, err = auth.LoadHtpasswd(path)
+		if err != nil {
+			logrus.WithError(err).Fatalf("failed to read --%s", fileName)
+		}
+	}
+
+	authString, err := cmd.Flags().GetString(name)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if authString != "" {
+		for _, user := range strings.Split(authString, ",") {
+			userParts := strings.SplitN(user, ":", 2)
+			if len(userParts) != 2 {
+				// do not log the secret
+				logrus.Fatalf("invalid user in --%s: %s", name, userParts[0])
+			}
+			users[userParts[0]] = userParts[1]
+		}
+	}
+	return users
+}
+
+// intervalFlag reads the interval of a background job, a ticker can't run with intervals of 0 or less
+func intervalFlag(cmd *cobra.Command, name string) time.Duration {
+	interval, err := cmd.Flags().GetDuration(name)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if interval <= 0 {
+		logrus.Fatalf("--%s must be greater than 0, got %s", name, interval)
+	}
+	return interval
+}
+
+// databaseFlags reads the database flags shared by all commands
+func databaseFlags(cmd *cobra.Command) (string, string, bool) {
+	provider := cmd.Flag("db-provider").Value.String()
+	dsn := cmd.Flag("db-dsn").Value.String()
+	migrate, err := cmd.Flags().GetBool("db-migrate")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return provider, dsn, migrate
+}
+
+// openDatabase connects to the database configured by the database flags
+func openDatabase(cmd *cobra.Command) *gorm.DB {
+	provider, dsn, migrate := databaseFlags(cmd)
+	dbConn, err := db.NewDBConnection(&mopsos.Config{
+		DBProvider: provider,
+		DBDSN:      dsn,
+		DBMigrate:  migrate,
+	})
+	if err != nil {
+		logrus.Fatal("Failed to connect to database: ", err)
+	}
+	return dbConn
+}
+
+// closeDatabase closes the connection pool of a database
+func closeDatabase(dbConn *gorm.DB) {
+	sqlDB, err := dbConn.DB()
+	if err != nil {
+		logrus.WithError(err).Error("failed to get database connection")
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		logrus.WithError(err).Error("failed to close database connection")
+	}
+}
+
 /**
  * initConfig reads in config file and ENV variables if set.
  *
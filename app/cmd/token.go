@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/adfinis-sygroup/mopsos/app/auth"
+)
+
+var hashTokenCmd = &cobra.Command{
+	Use:   "hash-token [user]",
+	Short: "Hash a token read from stdin for use in an htpasswd file",
+	Long: "Hash a token read from stdin with bcrypt. If a user is given the output is a " +
+		"line for the file passed to --http-basic-auth-file or --http-admin-users-file.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && token == "" {
+			logrus.WithError(err).Fatal("failed to read token from stdin")
+		}
+		token = strings.TrimRight(token, "\r\n")
+		if token == "" {
+			logrus.Fatal("token must not be empty")
+		}
+
+		hash, err := auth.HashPassword(token)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		if len(args) == 1 {
+			fmt.Printf("%s:%s\n", args[0], hash)
+			return
+		}
+		fmt.Println(hash)
+	},
+}
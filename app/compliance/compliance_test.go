@@ -0,0 +1,162 @@
+package compliance_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func newTestDB(t *testing.T, name string, records ...models.Record) *gorm.DB {
+	gdb, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Record{}, &models.Cluster{}, &models.ComplianceRule{}, &models.ComplianceResult{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if err := gdb.Create(&records[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gdb
+}
+
+// violations returns the reasons of the violations by rule and cluster/application
+func violations(t *testing.T, gdb *gorm.DB) map[string]string {
+	results := []models.ComplianceResult{}
+	if err := gdb.Find(&results).Error; err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, result := range results {
+		if !result.Compliant {
+			found[result.RuleName+" "+result.ClusterName+"/"+result.ApplicationName] = result.Reason
+		}
+	}
+	return found
+}
+
+func Test_LoadFile(t *testing.T) {
+	rules, err := compliance.LoadFile("testdata/rules.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Version != ">= 1.9.0" || rules[1].ClusterLabels["env"] != "production" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	if rules[1].AllowPrerelease == nil || *rules[1].AllowPrerelease {
+		t.Errorf("expected pre-releases to be forbidden, got %v", rules[1].AllowPrerelease)
+	}
+
+	if _, err := compliance.LoadFile("testdata/invalid.yaml"); !errors.Is(err, compliance.ErrInvalidRule) {
+		t.Errorf("expected invalid rule, got %v", err)
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.ComplianceRule
+		wantErr bool
+	}{
+		{name: "invalid version", rule: models.ComplianceRule{Name: "a", Version: ">=1.0, <2.0 || 3.x"}, wantErr: true},
+		{name: "range", rule: models.ComplianceRule{Name: "a", Version: ">=1.0, <2.0 || >=3.0"}},
+		{name: "no name", rule: models.ComplianceRule{Version: ">=1.0"}, wantErr: true},
+		{name: "no assertion", rule: models.ComplianceRule{Name: "a"}, wantErr: true},
+		{name: "bad pattern", rule: models.ComplianceRule{Name: "a", Version: ">=1.0", Clusters: []string{"["}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := compliance.Validate(&tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Engine(t *testing.T) {
+	gdb := newTestDB(t, "Test_Engine",
+		models.Record{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.8.0"},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "edge-proxy", ApplicationVersion: "2.0.0-rc.1"},
+	)
+	if err := gdb.Create(&models.Cluster{Name: "cluster-b", Labels: map[string]string{"env": "production"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	engine := compliance.NewEngine(gdb)
+	ctx := context.Background()
+
+	rules, err := compliance.LoadFile("testdata/rules.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SyncFile(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	found := violations(t, gdb)
+	if len(found) != 2 || found["cert-manager-minimum cluster-b/cert-manager"] != "v1.8.0 does not satisfy >= 1.9.0" ||
+		found["production-stable cluster-b/edge-proxy"] != "2.0.0-rc.1 is a pre-release" {
+		t.Fatalf("unexpected violations %v", found)
+	}
+
+	// rules from the file are read-only
+	if _, err := engine.SaveRule(ctx, models.ComplianceRule{Name: "cert-manager-minimum", Version: ">=1.0"}); !errors.Is(err, compliance.ErrReadOnly) {
+		t.Errorf("expected read-only rule, got %v", err)
+	}
+	if err := engine.DeleteRule(ctx, "production-stable"); !errors.Is(err, compliance.ErrReadOnly) {
+		t.Errorf("expected read-only rule, got %v", err)
+	}
+
+	// rules added using the API are evaluated immediately
+	rule, err := engine.SaveRule(ctx, models.ComplianceRule{Name: "edge-proxy-range", Clusters: []string{"cluster-*"}, Applications: []string{"edge-*"}, Version: ">=2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Source != models.RuleSourceAPI {
+		t.Errorf("expected source %q, got %q", models.RuleSourceAPI, rule.Source)
+	}
+	if _, ok := violations(t, gdb)["edge-proxy-range cluster-b/edge-proxy"]; !ok {
+		t.Errorf("expected edge-proxy to violate the new rule")
+	}
+
+	// records are re-evaluated when they are written
+	record := models.Record{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.2"}
+	if err := gdb.Model(&models.Record{}).Where(record.Key().Conditions()).Update("application_version", record.ApplicationVersion).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Evaluate(gdb, []models.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := violations(t, gdb)["cert-manager-minimum cluster-b/cert-manager"]; ok {
+		t.Errorf("expected cert-manager to comply after the upgrade")
+	}
+
+	// a new rules file replaces the rules of the previous one, API rules are kept
+	if err := engine.SyncFile(ctx, rules[:1]); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := engine.Rules(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 || stored[0].Name != "cert-manager-minimum" || stored[1].Name != "edge-proxy-range" {
+		t.Fatalf("unexpected rules %+v", stored)
+	}
+
+	if err := engine.DeleteRule(ctx, "edge-proxy-range"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.DeleteRule(ctx, "edge-proxy-range"); !errors.Is(err, compliance.ErrNotFound) {
+		t.Errorf("expected missing rule, got %v", err)
+	}
+	if found := violations(t, gdb); len(found) != 0 {
+		t.Errorf("expected no violations, got %v", found)
+	}
+}
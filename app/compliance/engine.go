@@ -0,0 +1,310 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+var (
+	// ErrInvalidRule is returned for rules that can't be evaluated
+	ErrInvalidRule = errors.New("invalid rule")
+	// ErrReadOnly is returned when changing a rule that was loaded from the rules file
+	ErrReadOnly = errors.New("rule is managed in the rules file")
+	// ErrNotFound is returned for unknown rules
+	ErrNotFound = errors.New("rule not found")
+)
+
+// evaluateBatchSize limits the rows per insert statement, sqlite allows only so many variables
+const evaluateBatchSize = 500
+
+// rulesFile is the format of the rules file
+type rulesFile struct {
+	Rules []models.ComplianceRule `yaml:"rules"`
+}
+
+// LoadFile reads the rules from a YAML file with a list of rules
+func LoadFile(path string) ([]models.ComplianceRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := rulesFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i := range file.Rules {
+		if err := Validate(&file.Rules[i]); err != nil {
+			return nil, err
+		}
+	}
+	return file.Rules, nil
+}
+
+// Validate checks that a rule has a name, valid patterns and asserts something
+func Validate(rule *models.ComplianceRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if rule.Version == "" && rule.AllowPrerelease == nil {
+		return fmt.Errorf("%w %q: version or allow_prerelease is required", ErrInvalidRule, rule.Name)
+	}
+	if rule.Version != "" {
+		if _, err := version.ParseConstraint(rule.Version); err != nil {
+			return fmt.Errorf("%w %q: %v", ErrInvalidRule, rule.Name, err)
+		}
+	}
+	for _, pattern := range append(append([]string{}, rule.Clusters...), rule.Applications...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w %q: invalid pattern %q", ErrInvalidRule, rule.Name, pattern)
+		}
+	}
+	return nil
+}
+
+// Engine evaluates the compliance rules against records and stores the results
+type Engine struct {
+	database *gorm.DB
+}
+
+// NewEngine creates an engine for the rules and records in a database
+func NewEngine(db *gorm.DB) *Engine {
+	return &Engine{database: db}
+}
+
+// SyncFile replaces the rules from the rules file and evaluates all records
+func (e *Engine) SyncFile(ctx context.Context, rules []models.ComplianceRule) error {
+	err := e.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("source = ?", models.RuleSourceFile).Delete(&models.ComplianceRule{}).Error; err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			rule.ID, rule.Source = 0, models.RuleSourceFile
+			if err := tx.Create(&rule).Error; err != nil {
+				return fmt.Errorf("adding rule %q: %w", rule.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.EvaluateAll(ctx)
+}
+
+// Rules returns all rules sorted by name
+func (e *Engine) Rules(ctx context.Context) ([]models.ComplianceRule, error) {
+	rules := []models.ComplianceRule{}
+	err := e.database.WithContext(ctx).Order("name").Find(&rules).Error
+	return rules, err
+}
+
+// Rule returns a single rule
+func (e *Engine) Rule(ctx context.Context, name string) (*models.ComplianceRule, error) {
+	rule := &models.ComplianceRule{}
+	err := e.database.WithContext(ctx).Where("name = ?", name).Take(rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return rule, err
+}
+
+// SaveRule adds or replaces a rule managed using the API and evaluates all records
+func (e *Engine) SaveRule(ctx context.Context, rule models.ComplianceRule) (*models.ComplianceRule, error) {
+	if err := Validate(&rule); err != nil {
+		return nil, err
+	}
+	rule.ID, rule.Source = 0, models.RuleSourceAPI
+
+	err := e.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing := &models.ComplianceRule{}
+		err := tx.Where("name = ?", rule.Name).Take(existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&rule).Error
+		}
+		if err != nil {
+			return err
+		}
+		if existing.Source == models.RuleSourceFile {
+			return ErrReadOnly
+		}
+		rule.ID, rule.CreatedAt = existing.ID, existing.CreatedAt
+		return tx.Save(&rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, e.EvaluateAll(ctx)
+}
+
+// DeleteRule removes a rule managed using the API together with its results
+func (e *Engine) DeleteRule(ctx context.Context, name string) error {
+	return e.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rule := &models.ComplianceRule{}
+		err := tx.Where("name = ?", name).Take(rule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if rule.Source == models.RuleSourceFile {
+			return ErrReadOnly
+		}
+		if err := tx.Where("rule_name = ?", name).Delete(&models.ComplianceResult{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(rule).Error
+	})
+}
+
+// EvaluateAll replaces the results of all records
+func (e *Engine) EvaluateAll(ctx context.Context) error {
+	return e.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.ComplianceResult{}).Error; err != nil {
+			return err
+		}
+		records := []models.Record{}
+		if err := tx.Find(&records).Error; err != nil {
+			return err
+		}
+		return e.Evaluate(tx, records)
+	})
+}
+
+// Evaluate replaces the results of records within a transaction
+func (e *Engine) Evaluate(tx *gorm.DB, records []models.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	keys := make([]models.RecordKey, len(records))
+	for i, record := range records {
+		keys[i] = record.Key()
+	}
+	if err := e.Remove(tx, keys); err != nil {
+		return err
+	}
+
+	rules := []models.ComplianceRule{}
+	if err := tx.Find(&rules).Error; err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	labels, err := clusterLabels(tx, rules)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	results := []models.ComplianceResult{}
+	for _, record := range records {
+		for _, rule := range rules {
+			if !selects(&rule, &record, labels[record.ClusterName]) {
+				continue
+			}
+			compliant, reason := check(&rule, record.ApplicationVersion)
+			results = append(results, models.ComplianceResult{
+				EvaluatedAt:         now,
+				RuleName:            rule.Name,
+				ClusterName:         record.ClusterName,
+				InstanceId:          record.InstanceId,
+				ApplicationName:     record.ApplicationName,
+				ApplicationInstance: record.ApplicationInstance,
+				ApplicationVersion:  record.ApplicationVersion,
+				Compliant:           compliant,
+				Reason:              reason,
+			})
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(results, evaluateBatchSize).Error
+}
+
+// Remove deletes the results of records within a transaction, i.e. because the records were deleted
+func (e *Engine) Remove(tx *gorm.DB, keys []models.RecordKey) error {
+	for _, key := range keys {
+		if err := tx.Where(key.Conditions()).Delete(&models.ComplianceResult{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clusterLabels returns the labels of the managed clusters if any rule selects clusters by label
+func clusterLabels(tx *gorm.DB, rules []models.ComplianceRule) (map[string]map[string]string, error) {
+	labels := map[string]map[string]string{}
+	for _, rule := range rules {
+		if len(rule.ClusterLabels) == 0 {
+			continue
+		}
+		clusters := []models.Cluster{}
+		if err := tx.Find(&clusters).Error; err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusters {
+			labels[cluster.Name] = cluster.Labels
+		}
+		break
+	}
+	return labels, nil
+}
+
+// selects reports whether a rule applies to a record
+func selects(rule *models.ComplianceRule, record *models.Record, labels map[string]string) bool {
+	if len(rule.Clusters) > 0 && !matchAny(rule.Clusters, record.ClusterName) {
+		return false
+	}
+	if len(rule.Applications) > 0 && !matchAny(rule.Applications, record.ApplicationName) {
+		return false
+	}
+	for key, value := range rule.ClusterLabels {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// check evaluates the assertions of a rule against a version
+func check(rule *models.ComplianceRule, installed string) (bool, string) {
+	v, err := version.Parse(installed)
+	if err != nil {
+		return false, fmt.Sprintf("version %q can't be compared", installed)
+	}
+	if rule.AllowPrerelease != nil && !*rule.AllowPrerelease && v.IsPrerelease() {
+		return false, fmt.Sprintf("%s is a pre-release", installed)
+	}
+	if rule.Version != "" {
+		// rules are validated before they are stored
+		constraint, err := version.ParseConstraint(rule.Version)
+		if err != nil {
+			return false, err.Error()
+		}
+		if !constraint.Check(v) {
+			return false, fmt.Sprintf("%s does not satisfy %s", installed, rule.Version)
+		}
+	}
+	return true, ""
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,12 @@
 package app
 
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+)
+
 // Config type for config
 type Config struct {
 	DBProvider string
@@ -8,7 +15,103 @@ type Config struct {
 
 	HttpListener   string
 	BasicAuthUsers map[string]string
+	AdminUsers     map[string]string
+	ReaderUsers    map[string]string
+	// RBACFile is a YAML file with the roles and read scopes of users and clusters
+	RBACFile string
+	// APIPublic leaves the read API open to everyone, otherwise it requires reader or admin users
+	APIPublic bool
+	// MetricsInventory serves the per-record metrics of all clusters on /metrics, only readers of all clusters may scrape them
+	MetricsInventory bool
+
+	// WebhookAuth lists the authenticators of the webhook in the order they are tried, 'basic', 'hmac', 'mtls' or 'jwt'
+	WebhookAuth []string
+	HMACSecrets map[string]string
+	HMACMaxSkew time.Duration
+
+	// WebhookRateLimit is the default limit of events per cluster as 'rate[:burst]', WebhookRateLimits overrides it per cluster
+	WebhookRateLimit      string
+	WebhookRateLimits     map[string]string
+	WebhookMaxConcurrency int
+	WebhookMaxBodySize    int64
+
+	// EventTypes maps CloudEvent types to 'upsert', 'delete' or 'ignore', the defaults are used if empty
+	EventTypes map[string]string
+
+	// JWTIssuers maps trusted issuers to their JWKS, given as http(s) URL or file
+	JWTIssuers   map[string]string
+	JWTAudiences []string
+	// JWTClusterClaim is the claim holding the cluster, its values are translated with JWTClusterMapping if set
+	JWTClusterClaim   string
+	JWTClusterMapping map[string]string
+
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	// TLSClientAuth is either 'optional' or 'require', it only applies with a client CA
+	TLSClientAuth    string
+	TLSClientMapping map[string]string
+
+	ShutdownTimeout time.Duration
+
+	QueueProvider string
+	QueuePath     string
+	QueueSize     int
+
+	HandlerRetries      int
+	HandlerRetryBackoff time.Duration
+	HandlerWorkers      int
+	HandlerBatchSize    int
+	HandlerBatchWindow  time.Duration
 
 	EnableTracing bool
 	TracingTarget string
+
+	UpstreamSources      []string
+	UpstreamInterval     time.Duration
+	UpstreamChartMapping map[string]string
+
+	OSVPath        string
+	OSVInterval    time.Duration
+	OSVNameMapping map[string]string
+	// OSVDefaultEcosystem is the ecosystem of unmapped applications, they are not matched without it
+	OSVDefaultEcosystem string
+
+	LifecyclePath           string
+	LifecycleInterval       time.Duration
+	LifecycleProductMapping map[string]string
+
+	ComplianceRulesFile string
+}
+
+// dsnPassword matches the password in key=value DSNs like "host=db password=secret"
+var dsnPassword = regexp.MustCompile(`(password=)\S+`)
+
+// String formats the config for logging, secrets are redacted
+func (c *Config) String() string {
+	type plain Config
+	redacted := plain(*c)
+	redacted.DBDSN = redactDSN(c.DBDSN)
+	redacted.BasicAuthUsers = redactUsers(c.BasicAuthUsers)
+	redacted.AdminUsers = redactUsers(c.AdminUsers)
+	redacted.ReaderUsers = redactUsers(c.ReaderUsers)
+	redacted.HMACSecrets = redactUsers(c.HMACSecrets)
+	return fmt.Sprintf("%+v", redacted)
+}
+
+// redactDSN hides the password in URL and key=value DSNs
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+// redactUsers keeps the user names but hides their tokens
+func redactUsers(users map[string]string) map[string]string {
+	redacted := make(map[string]string, len(users))
+	for user := range users {
+		redacted[user] = "xxxxx"
+	}
+	return redacted
 }
@@ -0,0 +1,37 @@
+package app_test
+
+import (
+	"strings"
+	"testing"
+
+	mopsos "github.com/adfinis-sygroup/mopsos/app"
+)
+
+func Test_ConfigStringRedactsSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{name: "key value dsn", dsn: "host=db user=mopsos password=dbsecret dbname=mopsos"},
+		{name: "url dsn", dsn: "postgres://mopsos:dbsecret@db:5432/mopsos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &mopsos.Config{
+				DBDSN:          tt.dsn,
+				BasicAuthUsers: map[string]string{"cluster": "clustersecret"},
+				AdminUsers:     map[string]string{"admin": "adminsecret"},
+				ReaderUsers:    map[string]string{"reader": "readersecret"},
+			}
+			got := cfg.String()
+			for _, secret := range []string{"dbsecret", "clustersecret", "adminsecret", "readersecret"} {
+				if strings.Contains(got, secret) {
+					t.Errorf("expected %q to be redacted from %s", secret, got)
+				}
+			}
+			if !strings.Contains(got, "cluster") || !strings.Contains(got, "admin") {
+				t.Errorf("expected user names to be kept in %s", got)
+			}
+		})
+	}
+}
@@ -30,13 +30,36 @@ func NewDBConnection(config *mopsos.Config) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.DBProvider == "sqlite" {
+		// sqlite only supports a single writer, concurrent handler workers would fail with locking errors
+		sqlDB, err := dbConn.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
 	if config.EnableTracing {
 		if err := dbConn.Use(otelgorm.NewPlugin()); err != nil {
 			return nil, err
 		}
 	}
 	if config.DBMigrate {
-		if err := dbConn.AutoMigrate(&models.Record{}); err != nil {
+		if err := dbConn.AutoMigrate(
+			&models.Record{},
+			&models.Image{},
+			&models.RecordHistory{},
+			&models.UpstreamRelease{},
+			&models.UpstreamStatus{},
+			&models.DeadLetter{},
+			&models.Cluster{},
+			&models.ClusterToken{},
+			&models.Vulnerability{},
+			&models.VulnerablePackage{},
+			&models.ProductCycle{},
+			&models.RecordCycle{},
+			&models.ComplianceRule{},
+			&models.ComplianceResult{},
+		); err != nil {
 			return nil, err
 		}
 	}
@@ -2,35 +2,448 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
 	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/queue"
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
+)
+
+// maxRetryBackoff caps the exponential backoff between retries
+const maxRetryBackoff = time.Minute
+
+// ErrStaleDeadLetter is returned when replaying an event whose record has been written by a newer event since
+var ErrStaleDeadLetter = errors.New("dead letter is stale")
+
+// permanentErrors are errors that will not go away by retrying
+var permanentErrors = []error{
+	gorm.ErrInvalidData,
+	gorm.ErrInvalidField,
+	gorm.ErrInvalidValue,
+	gorm.ErrInvalidValueOfLength,
+	gorm.ErrMissingWhereClause,
+	gorm.ErrPrimaryKeyRequired,
+	gorm.ErrModelValueRequired,
+}
+
+// sqlite result codes and postgres SQLSTATE classes of errors caused by the data itself
+var (
+	permanentSQLiteCodes = map[int]bool{
+		18: true, // SQLITE_TOOBIG
+		19: true, // SQLITE_CONSTRAINT
+		20: true, // SQLITE_MISMATCH
+		25: true, // SQLITE_RANGE
+	}
+	permanentPostgresClasses = map[string]bool{
+		"22": true, // data exception
+		"23": true, // integrity constraint violation
+	}
 )
 
 type Handler struct {
 	database *gorm.DB
 
 	enableTracing bool
+
+	retries      int
+	retryBackoff time.Duration
+
+	workers     int
+	batchSize   int
+	batchWindow time.Duration
+
+	compliance *compliance.Engine
+	upstream   *upstream.Tracker
+	lifecycle  *lifecycle.Checker
+
+	// quit is closed on shutdown to stop waiting for retries
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	// snapshots are the webhooks waiting for the outcome of a queued snapshot by event id
+	snapshotsMu sync.Mutex
+	snapshots   map[string]chan snapshotOutcome
+}
+
+// snapshotOutcome is the outcome of a queued snapshot
+type snapshotOutcome struct {
+	result *models.SnapshotResult
+	err    error
 }
 
 func NewHandler(enableTracing bool, db *gorm.DB) *Handler {
 	return &Handler{
 		database:      db,
 		enableTracing: enableTracing,
+		workers:       1,
+		quit:          make(chan struct{}),
+		snapshots:     map[string]chan snapshotOutcome{},
 	}
 }
 
-// HandleEvents blocks on the queue and handles events
-func (h *Handler) HandleEvents(eventChan chan models.EventData) error {
-	// block on the event channel while ranging over its contents
-	for data := range eventChan {
-		err := h.HandleEvent(data)
+// WithRetries sets how often storing an event is retried before it is dead-lettered
+//
+// The backoff doubles after each attempt.
+func (h *Handler) WithRetries(retries int, backoff time.Duration) *Handler {
+	h.retries = retries
+	h.retryBackoff = backoff
+	return h
+}
+
+// WithWorkers sets the number of workers storing events concurrently
+//
+// Events of the same record are always handled by the same worker so they
+// are stored in the order they were received.
+func (h *Handler) WithWorkers(workers int) *Handler {
+	if workers < 1 {
+		workers = 1
+	}
+	h.workers = workers
+	return h
+}
+
+// WithBatching makes each worker collect up to size events for at most window and store them in one transaction
+//
+// A size of 0 or 1 disables batching.
+func (h *Handler) WithBatching(size int, window time.Duration) *Handler {
+	h.batchSize = size
+	h.batchWindow = window
+	return h
+}
+
+// WithCompliance makes the handler evaluate the compliance rules whenever it writes a record
+func (h *Handler) WithCompliance(engine *compliance.Engine) *Handler {
+	h.compliance = engine
+	return h
+}
+
+// WithUpstream makes the handler compare written records with the latest upstream releases
+func (h *Handler) WithUpstream(tracker *upstream.Tracker) *Handler {
+	h.upstream = tracker
+	return h
+}
+
+// WithLifecycle makes the handler store the release cycle of written records
+func (h *Handler) WithLifecycle(checker *lifecycle.Checker) *Handler {
+	h.lifecycle = checker
+	return h
+}
+
+// Stop makes the handler give up retrying, events that fail from now on are dead-lettered right away
+//
+// It is called on shutdown so the queue can be drained within the shutdown
+// timeout, the dead-lettered events can be replayed once the cause is fixed.
+func (h *Handler) Stop() {
+	h.quitOnce.Do(func() {
+		close(h.quit)
+	})
+}
+
+// HandleEvents blocks on the queue and handles events until the queue is closed and empty
+//
+// Snapshots replace records of all workers, so they are applied once the
+// events queued before them are stored and before any later event.
+func (h *Handler) HandleEvents(q queue.Queue) error {
+	shards := make([]chan *queue.Item, h.workers)
+	wg := sync.WaitGroup{}
+	inflight := &sync.WaitGroup{}
+	for i := range shards {
+		shards[i] = make(chan *queue.Item, h.batchSize)
+		wg.Add(1)
+		go func(items <-chan *queue.Item) {
+			defer wg.Done()
+			if h.batchSize > 1 {
+				h.batchWorker(q, items, inflight)
+			} else {
+				h.worker(q, items, inflight)
+			}
+		}(shards[i])
+	}
+	defer wg.Wait()
+	defer func() {
+		for _, shard := range shards {
+			close(shard)
+		}
+	}()
+
+	for {
+		item, err := q.Dequeue()
+		if errors.Is(err, queue.ErrClosed) {
+			return nil
+		}
 		if err != nil {
-			logrus.WithField("event", data.Event).WithError(err).Error("failed to handle event")
+			return err
+		}
+		if item.Data.Action == models.ActionSnapshot {
+			inflight.Wait()
+			h.deliverSnapshot(item.Data)
+			h.ack(q, item)
+			continue
+		}
+		inflight.Add(1)
+		shards[shard(item.Data.Record.Key(), len(shards))] <- item
+	}
+}
+
+// shard assigns a record key to one of n workers
+func shard(key models.RecordKey, n int) int {
+	hash := fnv.New32a()
+	for _, part := range []string{key.ClusterName, key.InstanceId, key.ApplicationName, key.ApplicationInstance} {
+		_, _ = hash.Write([]byte(part))
+		_, _ = hash.Write([]byte{0})
+	}
+	return int(hash.Sum32() % uint32(n))
+}
+
+// worker stores events one by one
+func (h *Handler) worker(q queue.Queue, items <-chan *queue.Item, inflight *sync.WaitGroup) {
+	for item := range items {
+		h.deliver(item.Data)
+		h.ack(q, item)
+		inflight.Done()
+	}
+}
+
+// batchWorker collects events until the batch is full or the batch window has passed and stores them together
+func (h *Handler) batchWorker(q queue.Queue, items <-chan *queue.Item, inflight *sync.WaitGroup) {
+	batch := make([]*queue.Item, 0, h.batchSize)
+	timer := time.NewTimer(h.batchWindow)
+	stopTimer(timer)
+
+	flush := func() {
+		stopTimer(timer)
+		if len(batch) == 0 {
+			return
+		}
+		h.deliverBatch(batch)
+		for _, item := range batch {
+			h.ack(q, item)
+			inflight.Done()
 		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case item, ok := <-items:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) == 1 {
+				timer.Reset(h.batchWindow)
+			}
+			if len(batch) >= h.batchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// stopTimer stops a timer and drains its channel so it can be reset
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+// ack acknowledges a handled event
+func (h *Handler) ack(q queue.Queue, item *queue.Item) {
+	if err := q.Ack(item); err != nil {
+		logrus.WithError(err).WithField("event", item.Data.Event).Error("failed to acknowledge event")
+	}
+	metrics.QueueDepth.Set(float64(q.Len()))
+}
+
+// deliverBatch stores a batch of events, if that fails the events are delivered one by one
+//
+// Delivering them one by one retries the events and dead-letters only the
+// events that keep failing instead of the whole batch.
+func (h *Handler) deliverBatch(batch []*queue.Item) {
+	events := make([]models.EventData, len(batch))
+	for i, item := range batch {
+		events[i] = item.Data
+	}
+	err := h.HandleBatch(events)
+	if err == nil {
+		return
+	}
+
+	logrus.WithError(err).WithField("events", len(events)).Warn("failed to handle batch, handling events one by one")
+	for _, data := range events {
+		h.deliver(data)
+	}
+}
+
+// deliver handles an event, retrying transient failures and dead-lettering the event if all attempts fail
+func (h *Handler) deliver(data models.EventData) {
+	_ = h.retry(data, func() error {
+		return h.HandleEvent(data)
+	})
+}
+
+// deliverSnapshot applies a queued snapshot like deliver and passes the outcome to the webhook waiting for it
+func (h *Handler) deliverSnapshot(data models.EventData) {
+	var result *models.SnapshotResult
+	err := h.retry(data, func() error {
+		var err error
+		result, err = h.HandleSnapshot(context.Background(), *data.Snapshot)
+		return err
+	})
+
+	h.snapshotsMu.Lock()
+	waiter, ok := h.snapshots[data.Event.ID()]
+	h.snapshotsMu.Unlock()
+	if ok {
+		waiter <- snapshotOutcome{result: result, err: err}
+	}
+}
+
+// awaitSnapshot registers for the outcome of the queued snapshot with the given event id
+//
+// The returned function must be called once the outcome is no longer awaited.
+func (h *Handler) awaitSnapshot(id string) (<-chan snapshotOutcome, func()) {
+	waiter := make(chan snapshotOutcome, 1)
+	h.snapshotsMu.Lock()
+	h.snapshots[id] = waiter
+	h.snapshotsMu.Unlock()
+	return waiter, func() {
+		h.snapshotsMu.Lock()
+		delete(h.snapshots, id)
+		h.snapshotsMu.Unlock()
+	}
+}
+
+// retry runs store until it succeeds, the retries are used up or the error is permanent
+//
+// The event is dead-lettered if it could not be stored, the last error is returned then.
+func (h *Handler) retry(data models.EventData, store func() error) error {
+	log := logrus.WithField("event", data.Event)
+
+	backoff := h.retryBackoff
+	attempts := 0
+	for {
+		attempts++
+		err := store()
+		if err == nil {
+			return nil
+		}
+		if attempts > h.retries || isPermanent(err) || h.stopped() {
+			log.WithError(err).WithField("attempts", attempts).Error("failed to handle event, moving it to the dead letters")
+			h.deadLetter(data, err, attempts)
+			return err
+		}
+
+		log.WithError(err).WithField("attempts", attempts).Warn("failed to handle event, retrying")
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-h.quit:
+			timer.Stop()
+			log.WithError(err).WithField("attempts", attempts).Error("shutting down, moving the event to the dead letters")
+			h.deadLetter(data, err, attempts)
+			return err
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// stopped reports whether the handler was stopped
+func (h *Handler) stopped() bool {
+	select {
+	case <-h.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// deadLetter stores an event that could not be handled
+func (h *Handler) deadLetter(data models.EventData, cause error, attempts int) {
+	log := logrus.WithField("event", data.Event)
+
+	letter, err := models.NewDeadLetter(data, cause, attempts)
+	if err != nil {
+		log.WithError(err).Error("failed to encode dead letter")
+		return
+	}
+	if err := h.database.Create(letter).Error; err != nil {
+		log.WithError(err).Error("failed to store dead letter, the event is lost")
+		return
+	}
+	metrics.EventsDeadLettered.Inc()
+}
+
+// Replay handles a dead-lettered event again
+//
+// On success the dead letter is marked as replayed, otherwise the new error
+// and attempt are recorded on it. Replays bypass the ordering of the queue,
+// so events whose record has been written since they were dead-lettered are
+// rejected with ErrStaleDeadLetter instead of overwriting the newer state.
+func (h *Handler) Replay(letter *models.DeadLetter) error {
+	if letter.ReplayedAt != nil {
+		return fmt.Errorf("dead letter %d has already been replayed", letter.ID)
+	}
+	data, err := letter.EventData()
+	if err != nil {
+		return err
+	}
+	if err := h.checkStale(letter, data); err != nil {
+		return err
+	}
+
+	letter.Attempts++
+	if replayErr := h.HandleEvent(data); replayErr != nil {
+		letter.Error = replayErr.Error()
+		if err := h.database.Save(letter).Error; err != nil {
+			logrus.WithError(err).WithField("id", letter.ID).Error("failed to update dead letter")
+		}
+		return replayErr
+	}
+
+	now := time.Now()
+	letter.ReplayedAt = &now
+	return h.database.Save(letter).Error
+}
+
+// checkStale returns ErrStaleDeadLetter if the records of a dead-lettered event were written after it was dead-lettered
+//
+// Events of a record are handled in order and the worker is blocked while
+// retrying, so any later write stems from a newer event. Snapshots are stale
+// if any record of their cluster instance was written since.
+func (h *Handler) checkStale(letter *models.DeadLetter, data models.EventData) error {
+	query := h.database.Unscoped().Model(&models.Record{})
+	if data.Action == models.ActionSnapshot {
+		query = query.Where("cluster_name = ? AND instance_id = ?", data.Record.ClusterName, data.Record.InstanceId)
+	} else {
+		query = query.Where(data.Record.Key().Conditions())
+	}
+	var newer int64
+	if err := query.Where("updated_at > ? OR deleted_at > ?", letter.CreatedAt, letter.CreatedAt).Count(&newer).Error; err != nil {
+		return err
+	}
+	if newer > 0 {
+		return fmt.Errorf("%w: the record was written by a newer event since dead letter %d was created", ErrStaleDeadLetter, letter.ID)
 	}
 	return nil
 }
@@ -41,19 +454,438 @@ func (h *Handler) HandleEvent(data models.EventData) error {
 
 	ctx := context.Background()
 
-	log.WithField("record", data.Record).Debug("creating record")
+	// snapshots are only handled here when a dead-lettered snapshot is replayed
+	if data.Action == models.ActionSnapshot {
+		if data.Snapshot == nil {
+			return fmt.Errorf("%w: snapshot event without snapshot", gorm.ErrInvalidData)
+		}
+		_, err := h.HandleSnapshot(ctx, *data.Snapshot)
+		return err
+	}
+
+	err := h.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if data.Action == models.ActionDelete {
+			log.WithField("record", data.Record).Debug("deleting record")
+			if err := h.deleteRecord(tx, data); err != nil {
+				return err
+			}
+			return h.removeEvaluations(tx, []models.RecordKey{data.Record.Key()})
+		}
+
+		log.WithField("record", data.Record).Debug("creating record")
+		if err := h.recordHistory(tx, data); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Clauses(recordUpsertClause()).Create(&data.Record).Error; err != nil {
+			return err
+		}
+		if err := replaceImages(tx, []models.Record{data.Record}); err != nil {
+			return err
+		}
+		return h.evaluate(tx, []models.Record{data.Record})
+	})
+	if err != nil {
+		metrics.DatabaseWriteFailures.Inc()
+	}
+	return err
+}
+
+// HandleBatch stores a batch of events in one transaction
+//
+// Events for the same record are coalesced into a single upsert with the
+// last version, or a delete if the last event deletes the record, while each
+// version change and removal is still added to the history.
+func (h *Handler) HandleBatch(batch []models.EventData) error {
+	logrus.WithField("events", len(batch)).Debug("received batch")
 
-	h.database.WithContext(ctx).Clauses(
-		clause.OnConflict{
-			Columns: []clause.Column{
-				{Name: "cluster_name"},
-				{Name: "instance_id"},
-				{Name: "application_name"},
-				{Name: "application_instance"},
-			},
-			UpdateAll: true,
-		},
-	).Create(&data.Record)
+	ctx := context.Background()
+
+	err := h.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		versions, err := currentVersions(tx, batch)
+		if err != nil {
+			return err
+		}
+		stored := make(map[models.RecordKey]bool, len(versions))
+		for key := range versions {
+			stored[key] = true
+		}
+
+		history := []models.RecordHistory{}
+		latest := map[models.RecordKey]int{}
+		keys := []models.RecordKey{}
+		for i, data := range batch {
+			key := data.Record.Key()
+			previous, ok := versions[key]
+			if data.Action == models.ActionDelete {
+				if ok {
+					history = append(history, newRecordHistory(data, previous))
+					delete(versions, key)
+				}
+			} else {
+				if !ok || previous != data.Record.ApplicationVersion {
+					history = append(history, newRecordHistory(data, previous))
+				}
+				versions[key] = data.Record.ApplicationVersion
+			}
+
+			if _, ok := latest[key]; !ok {
+				keys = append(keys, key)
+			}
+			latest[key] = i
+		}
+
+		// a single statement must not update the same record twice, the last event of a record decides
+		records := make([]models.Record, 0, len(keys))
+		deleted := []models.RecordKey{}
+		for _, key := range keys {
+			data := batch[latest[key]]
+			if data.Action != models.ActionDelete {
+				records = append(records, data.Record)
+			} else if stored[key] {
+				deleted = append(deleted, key)
+			}
+		}
+
+		if len(history) > 0 {
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+		}
+		if len(records) > 0 {
+			if err := tx.Omit(clause.Associations).Clauses(recordUpsertClause()).Create(&records).Error; err != nil {
+				return err
+			}
+			if err := replaceImages(tx, records); err != nil {
+				return err
+			}
+			if err := h.evaluate(tx, records); err != nil {
+				return err
+			}
+		}
+		if err := h.removeEvaluations(tx, deleted); err != nil {
+			return err
+		}
+		for _, key := range deleted {
+			ids := tx.Model(&models.Record{}).Select("id").Where(key.Conditions())
+			if err := tx.Where("record_id IN (?)", ids).Delete(&models.Image{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where(key.Conditions()).Delete(&models.Record{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		metrics.DatabaseWriteFailures.Inc()
+	}
+	return err
+}
+
+// HandleSnapshot replaces the records of a cluster with the records of a snapshot in one transaction
+//
+// Listed records are upserted like events, records of the cluster missing
+// from the snapshot are soft-deleted. Both are added to the history.
+func (h *Handler) HandleSnapshot(ctx context.Context, snapshot models.Snapshot) (*models.SnapshotResult, error) {
+	log := logrus.WithFields(logrus.Fields{
+		"cluster":  snapshot.ClusterName,
+		"instance": snapshot.InstanceId,
+		"records":  len(snapshot.Records),
+	})
+	log.Debug("received snapshot")
+
+	result := &models.SnapshotResult{}
+	err := h.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("cluster_name = ?", snapshot.ClusterName)
+		if snapshot.InstanceId != "" {
+			query = query.Where("instance_id = ?", snapshot.InstanceId)
+		}
+		stored := []models.Record{}
+		if err := query.Find(&stored).Error; err != nil {
+			return err
+		}
+		versions := make(map[models.RecordKey]string, len(stored))
+		for _, record := range stored {
+			versions[record.Key()] = record.ApplicationVersion
+		}
+
+		// a single statement must not update the same record twice, the last entry wins
+		latest := map[models.RecordKey]int{}
+		keys := []models.RecordKey{}
+		for i, record := range snapshot.Records {
+			if _, ok := latest[record.Key()]; !ok {
+				keys = append(keys, record.Key())
+			}
+			latest[record.Key()] = i
+		}
+
+		now := time.Now()
+		history := []models.RecordHistory{}
+		records := make([]models.Record, 0, len(keys))
+		for _, key := range keys {
+			record := snapshot.Records[latest[key]]
+			records = append(records, record)
+
+			previous, ok := versions[key]
+			if ok && previous == record.ApplicationVersion {
+				result.Unchanged++
+				continue
+			}
+			result.Updated++
+			history = append(history, models.RecordHistory{
+				ClusterName:         record.ClusterName,
+				InstanceId:          record.InstanceId,
+				ApplicationName:     record.ApplicationName,
+				ApplicationInstance: record.ApplicationInstance,
+				Action:              models.HistoryUpdate,
+				PreviousVersion:     previous,
+				ApplicationVersion:  record.ApplicationVersion,
+				EventTime:           now,
+			})
+		}
+
+		removed := []uint{}
+		removedKeys := []models.RecordKey{}
+		for _, record := range stored {
+			if _, ok := latest[record.Key()]; ok {
+				continue
+			}
+			removed = append(removed, record.ID)
+			removedKeys = append(removedKeys, record.Key())
+			history = append(history, models.RecordHistory{
+				ClusterName:         record.ClusterName,
+				InstanceId:          record.InstanceId,
+				ApplicationName:     record.ApplicationName,
+				ApplicationInstance: record.ApplicationInstance,
+				Action:              models.HistoryRemove,
+				PreviousVersion:     record.ApplicationVersion,
+				EventTime:           now,
+			})
+		}
+		result.Removed = len(removed)
+
+		if len(history) > 0 {
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+		}
+		if len(records) > 0 {
+			if err := tx.Omit(clause.Associations).Clauses(recordUpsertClause()).Create(&records).Error; err != nil {
+				return err
+			}
+			if err := replaceImages(tx, records); err != nil {
+				return err
+			}
+			if err := h.evaluate(tx, records); err != nil {
+				return err
+			}
+		}
+		if len(removed) > 0 {
+			if err := h.removeEvaluations(tx, removedKeys); err != nil {
+				return err
+			}
+			if err := tx.Where("record_id IN ?", removed).Delete(&models.Image{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.Record{}, removed).Error
+		}
+		return nil
+	})
+	if err != nil {
+		metrics.DatabaseWriteFailures.Inc()
+		return nil, err
+	}
+	log.WithFields(logrus.Fields{
+		"updated": result.Updated,
+		"removed": result.Removed,
+	}).Info("applied snapshot")
+	return result, nil
+}
+
+// deleteRecord soft-deletes the record of an event and adds the removal to the history
+//
+// Deleting a record that does not exist is not an error, the event may have been delivered twice.
+func (h *Handler) deleteRecord(tx *gorm.DB, data models.EventData) error {
+	previous := &models.Record{}
+	err := tx.Where(data.Record.Key().Conditions()).Take(previous).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	history := newRecordHistory(data, previous.ApplicationVersion)
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("record_id = ?", previous.ID).Delete(&models.Image{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(previous).Error
+}
 
+// evaluate replaces the compliance results, upstream statuses and release cycles of written records if enabled
+func (h *Handler) evaluate(tx *gorm.DB, records []models.Record) error {
+	if h.compliance != nil {
+		if err := h.compliance.Evaluate(tx, records); err != nil {
+			return err
+		}
+	}
+	if h.upstream != nil {
+		if err := h.upstream.Evaluate(tx, records); err != nil {
+			return err
+		}
+	}
+	if h.lifecycle != nil {
+		return h.lifecycle.Evaluate(tx, records)
+	}
+	return nil
+}
+
+// removeEvaluations deletes the compliance results, upstream statuses and release cycles of removed records if enabled
+func (h *Handler) removeEvaluations(tx *gorm.DB, keys []models.RecordKey) error {
+	if h.compliance != nil {
+		if err := h.compliance.Remove(tx, keys); err != nil {
+			return err
+		}
+	}
+	if h.upstream != nil {
+		if err := h.upstream.Remove(tx, keys); err != nil {
+			return err
+		}
+	}
+	if h.lifecycle != nil {
+		return h.lifecycle.Remove(tx, keys)
+	}
 	return nil
 }
+
+// replaceImages replaces the stored images of the records that list images
+//
+// Records without images keep their stored images, not every event lists them.
+func replaceImages(tx *gorm.DB, records []models.Record) error {
+	for _, record := range records {
+		if len(record.Images) == 0 {
+			continue
+		}
+		stored := &models.Record{}
+		if err := tx.Select("id").Where(record.Key().Conditions()).Take(stored).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("record_id = ?", stored.ID).Delete(&models.Image{}).Error; err != nil {
+			return err
+		}
+
+		images := make([]models.Image, len(record.Images))
+		for i, image := range record.Images {
+			image.ID, image.RecordID = 0, stored.ID
+			images[i] = image
+		}
+		if err := tx.Create(&images).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordHistory appends a history entry if the event changes the version of a record
+func (h *Handler) recordHistory(tx *gorm.DB, data models.EventData) error {
+	previous := &models.Record{}
+	err := tx.Where(data.Record.Key().Conditions()).Take(previous).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && previous.ApplicationVersion == data.Record.ApplicationVersion {
+		return nil
+	}
+
+	history := newRecordHistory(data, previous.ApplicationVersion)
+	return tx.Create(&history).Error
+}
+
+// newRecordHistory creates the history entry for the version change or removal announced by an event
+func newRecordHistory(data models.EventData, previousVersion string) models.RecordHistory {
+	history := models.RecordHistory{
+		ClusterName:         data.Record.ClusterName,
+		InstanceId:          data.Record.InstanceId,
+		ApplicationName:     data.Record.ApplicationName,
+		ApplicationInstance: data.Record.ApplicationInstance,
+		Action:              models.HistoryUpdate,
+		PreviousVersion:     previousVersion,
+		ApplicationVersion:  data.Record.ApplicationVersion,
+		EventID:             data.Event.ID(),
+		EventTime:           data.Event.Time(),
+	}
+	if data.Action == models.ActionDelete {
+		history.Action = models.HistoryRemove
+		history.ApplicationVersion = ""
+	}
+	return history
+}
+
+// currentVersions loads the stored versions of the records in a batch
+func currentVersions(tx *gorm.DB, batch []models.EventData) (map[models.RecordKey]string, error) {
+	clusters := map[string]bool{}
+	applications := map[string]bool{}
+	for _, data := range batch {
+		clusters[data.Record.ClusterName] = true
+		applications[data.Record.ApplicationName] = true
+	}
+
+	// narrow the query down by cluster and application, the exact keys are matched below
+	records := []models.Record{}
+	err := tx.Where("cluster_name IN ? AND application_name IN ?", keysOf(clusters), keysOf(applications)).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[models.RecordKey]string, len(records))
+	for _, record := range records {
+		versions[record.Key()] = record.ApplicationVersion
+	}
+	return versions, nil
+}
+
+func keysOf(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// isPermanent reports whether an error will fail every retry
+//
+// Besides the errors of gorm, constraint violations and invalid data
+// reported by sqlite and postgres are permanent. The driver errors are
+// matched by their methods so the handler doesn't depend on the drivers.
+func isPermanent(err error) bool {
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return true
+		}
+	}
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) && permanentSQLiteCodes[sqliteErr.Code()&0xff] {
+		return true
+	}
+	var postgresErr interface{ SQLState() string }
+	if errors.As(err, &postgresErr) && len(postgresErr.SQLState()) == 5 && permanentPostgresClasses[postgresErr.SQLState()[:2]] {
+		return true
+	}
+	return false
+}
+
+// recordUpsertClause updates all columns of an existing record with the same unique key
+func recordUpsertClause() clause.OnConflict {
+	columns := make([]clause.Column, len(models.RecordKeyColumns))
+	for i, name := range models.RecordKeyColumns {
+		columns[i] = clause.Column{Name: name}
+	}
+	return clause.OnConflict{
+		Columns:   columns,
+		UpdateAll: true,
+	}
+}
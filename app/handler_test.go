@@ -2,9 +2,13 @@ package app_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	otelObs "github.com/cloudevents/sdk-go/observability/opentelemetry/v2/client"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
@@ -14,8 +18,11 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 
 	mopsos "github.com/adfinis-sygroup/mopsos/app"
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
 	"github.com/adfinis-sygroup/mopsos/app/db"
 	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/queue"
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
 )
 
 func eventStub(record *models.Record) models.EventData {
@@ -131,3 +138,658 @@ func Test_Handler_HandleEvent(t *testing.T) {
 	}
 
 }
+
+func Test_Handler_HandleEventHistory(t *testing.T) {
+	gdb := newTestDB(t, "Test_Handler_HandleEventHistory")
+	h := mopsos.NewHandler(false, gdb)
+
+	for _, version := range []string{"1.0.0", "1.0.0", "1.1.0", "2.0.0"} {
+		err := h.HandleEvent(eventStub(&models.Record{
+			ClusterName:        "cluster",
+			ApplicationName:    "app",
+			ApplicationVersion: version,
+		}))
+		if err != nil {
+			t.Fatalf("Handler.HandleEvent() error = %v", err)
+		}
+	}
+
+	history := []models.RecordHistory{}
+	if err := gdb.Order("id").Find(&history).Error; err != nil {
+		t.Fatalf("failed to query history: %v", err)
+	}
+	want := [][2]string{{"", "1.0.0"}, {"1.0.0", "1.1.0"}, {"1.1.0", "2.0.0"}}
+	if len(history) != len(want) {
+		t.Fatalf("expected %d history entries, got %d", len(want), len(history))
+	}
+	for i, entry := range history {
+		if entry.PreviousVersion != want[i][0] || entry.ApplicationVersion != want[i][1] {
+			t.Errorf("expected transition %v, got %s -> %s", want[i], entry.PreviousVersion, entry.ApplicationVersion)
+		}
+	}
+
+	var records int64
+	gdb.Model(&models.Record{}).Count(&records)
+	if records != 1 {
+		t.Errorf("expected a single record, got %d", records)
+	}
+}
+
+func Test_Handler_DeadLetter(t *testing.T) {
+	gdb, err := db.NewDBConnection(&mopsos.Config{
+		DBProvider: "sqlite",
+		DBDSN:      "file:Test_Handler_DeadLetter?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	// without the records table every write fails
+	if err := gdb.AutoMigrate(&models.DeadLetter{}); err != nil {
+		t.Fatal(err)
+	}
+	h := mopsos.NewHandler(false, gdb).WithRetries(2, time.Millisecond)
+
+	data := eventStub(&models.Record{
+		ClusterName:        "cluster",
+		ApplicationName:    "app",
+		ApplicationVersion: "1.0.0",
+	})
+	data.Event.SetID("1")
+	data.Event.SetSource("test")
+
+	q := queue.NewMemoryQueue(1)
+	if err := q.Enqueue(data); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if err := h.HandleEvents(q); err != nil {
+		t.Fatalf("Handler.HandleEvents() error = %v", err)
+	}
+
+	letter := &models.DeadLetter{}
+	if err := gdb.Take(letter).Error; err != nil {
+		t.Fatalf("expected a dead letter: %v", err)
+	}
+	if letter.Attempts != 3 || letter.ClusterName != "cluster" || letter.Error == "" {
+		t.Errorf("unexpected dead letter %+v", letter)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected dead-lettered event to be acknowledged, %d events pending", q.Len())
+	}
+
+	// once the database is fixed the event can be replayed
+	if err := gdb.AutoMigrate(&models.Record{}, &models.RecordHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Replay(letter); err != nil {
+		t.Fatalf("Handler.Replay() error = %v", err)
+	}
+	if letter.ReplayedAt == nil || letter.Attempts != 4 {
+		t.Errorf("expected dead letter to be marked as replayed, got %+v", letter)
+	}
+	record := &models.Record{}
+	if err := gdb.Take(record).Error; err != nil || record.ApplicationVersion != "1.0.0" {
+		t.Errorf("expected replayed record to be stored, got %+v (%v)", record, err)
+	}
+	if err := h.Replay(letter); err == nil {
+		t.Error("expected error when replaying twice, got nil")
+	}
+}
+
+func Test_Handler_DeadLetterPermanentErrors(t *testing.T) {
+	gdb, err := db.NewDBConnection(&mopsos.Config{
+		DBProvider: "sqlite",
+		DBDSN:      "file:Test_Handler_DeadLetterPermanentErrors?mode=memory&cache=shared",
+		DBMigrate:  true,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	// the trigger fails every write with a constraint violation
+	if err := gdb.Exec("CREATE TRIGGER reject_records BEFORE INSERT ON records BEGIN SELECT RAISE(ABORT, 'rejected'); END").Error; err != nil {
+		t.Fatal(err)
+	}
+	h := mopsos.NewHandler(false, gdb).WithRetries(5, time.Second)
+
+	q := queue.NewMemoryQueue(1)
+	if err := q.Enqueue(sequenceEvent("cluster", "app", 1)); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if err := h.HandleEvents(q); err != nil {
+		t.Fatalf("Handler.HandleEvents() error = %v", err)
+	}
+
+	letter := &models.DeadLetter{}
+	if err := gdb.Take(letter).Error; err != nil {
+		t.Fatalf("expected a dead letter: %v", err)
+	}
+	if letter.Attempts != 1 {
+		t.Errorf("expected constraint violations not to be retried, got %d attempts", letter.Attempts)
+	}
+}
+
+func Test_Handler_StopInterruptsRetries(t *testing.T) {
+	gdb, err := db.NewDBConnection(&mopsos.Config{
+		DBProvider: "sqlite",
+		DBDSN:      "file:Test_Handler_StopInterruptsRetries?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	// without the records table every write fails
+	if err := gdb.AutoMigrate(&models.DeadLetter{}); err != nil {
+		t.Fatal(err)
+	}
+	h := mopsos.NewHandler(false, gdb).WithRetries(5, time.Hour)
+
+	q := queue.NewMemoryQueue(1)
+	if err := q.Enqueue(sequenceEvent("cluster", "app", 1)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- h.HandleEvents(q)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	h.Stop()
+	q.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Handler.HandleEvents() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Stop to interrupt the backoff")
+	}
+
+	letter := &models.DeadLetter{}
+	if err := gdb.Take(letter).Error; err != nil {
+		t.Fatalf("expected the interrupted event to be dead-lettered: %v", err)
+	}
+	if letter.Attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", letter.Attempts)
+	}
+}
+
+func Test_Handler_ReplayStale(t *testing.T) {
+	gdb := newTestDB(t, "Test_Handler_ReplayStale")
+	h := mopsos.NewHandler(false, gdb)
+
+	letter, err := models.NewDeadLetter(sequenceEvent("cluster", "app", 1), errors.New("database is down"), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	letter.CreatedAt = time.Now().Add(-time.Hour)
+	if err := gdb.Create(letter).Error; err != nil {
+		t.Fatal(err)
+	}
+	// a newer event updated the record after the event was dead-lettered
+	if err := h.HandleEvent(sequenceEvent("cluster", "app", 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Replay(letter); !errors.Is(err, mopsos.ErrStaleDeadLetter) {
+		t.Fatalf("expected stale dead letter, got %v", err)
+	}
+	record := &models.Record{}
+	if err := gdb.Take(record).Error; err != nil || record.ApplicationVersion != "1.0.2" {
+		t.Errorf("expected the newer version to be kept, got %+v (%v)", record, err)
+	}
+	var history int64
+	if err := gdb.Model(&models.RecordHistory{}).Count(&history).Error; err != nil {
+		t.Fatal(err)
+	}
+	if history != 1 {
+		t.Errorf("expected only the history of the newer event, got %d entries", history)
+	}
+
+	// dead letters of records that were not written since are replayed
+	letter, err = models.NewDeadLetter(sequenceEvent("cluster", "other", 1), errors.New("database is down"), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(letter).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Replay(letter); err != nil {
+		t.Errorf("Handler.Replay() error = %v", err)
+	}
+}
+
+// sequenceEvent returns the i-th event of an application on a cluster
+func sequenceEvent(cluster, app string, i int) models.EventData {
+	evt := cloudevents.NewEvent(cloudevents.VersionV1)
+	evt.SetID(fmt.Sprintf("%s-%s-%d", cluster, app, i))
+	evt.SetSource("test")
+	evt.SetType("com.example.record")
+	return models.EventData{
+		Event: evt,
+		Record: models.Record{
+			ClusterName:        cluster,
+			ApplicationName:    app,
+			ApplicationVersion: fmt.Sprintf("1.0.%d", i),
+		},
+	}
+}
+
+func Test_Handler_HandleEventsPreservesOrderPerRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		workers     int
+		batchSize   int
+		batchWindow time.Duration
+	}{
+		{name: "workers", workers: 4},
+		{name: "workers with batching", workers: 4, batchSize: 16, batchWindow: 5 * time.Millisecond},
+		{name: "single worker with batching", workers: 1, batchSize: 7, batchWindow: time.Millisecond},
+	}
+	clusters := []string{"cluster-a", "cluster-b"}
+	apps := []string{"app-a", "app-b", "app-c"}
+	const versions = 20
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gdb := newTestDB(t, t.Name())
+			h := mopsos.NewHandler(false, gdb).
+				WithRetries(5, time.Millisecond).
+				WithWorkers(tt.workers).
+				WithBatching(tt.batchSize, tt.batchWindow)
+
+			// interleave the events of all records, every event raises the version
+			q := queue.NewMemoryQueue(0)
+			for i := 0; i < versions; i++ {
+				for _, cluster := range clusters {
+					for _, app := range apps {
+						if err := q.Enqueue(sequenceEvent(cluster, app, i)); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+			}
+			// repeating the last event must not add to the history
+			if err := q.Enqueue(sequenceEvent("cluster-a", "app-a", versions-1)); err != nil {
+				t.Fatal(err)
+			}
+			q.Close()
+			if err := h.HandleEvents(q); err != nil {
+				t.Fatalf("Handler.HandleEvents() error = %v", err)
+			}
+
+			for _, cluster := range clusters {
+				for _, app := range apps {
+					record := &models.Record{}
+					if err := gdb.Where("cluster_name = ? AND application_name = ?", cluster, app).Take(record).Error; err != nil {
+						t.Fatalf("expected record for %s/%s: %v", cluster, app, err)
+					}
+					if want := fmt.Sprintf("1.0.%d", versions-1); record.ApplicationVersion != want {
+						t.Errorf("%s/%s: expected version %s, got %s", cluster, app, want, record.ApplicationVersion)
+					}
+
+					history := []models.RecordHistory{}
+					if err := gdb.Where("cluster_name = ? AND application_name = ?", cluster, app).Order("id").Find(&history).Error; err != nil {
+						t.Fatal(err)
+					}
+					if len(history) != versions {
+						t.Fatalf("%s/%s: expected %d history entries, got %d", cluster, app, versions, len(history))
+					}
+					for i, entry := range history {
+						previous := ""
+						if i > 0 {
+							previous = fmt.Sprintf("1.0.%d", i-1)
+						}
+						if entry.PreviousVersion != previous || entry.ApplicationVersion != fmt.Sprintf("1.0.%d", i) {
+							t.Errorf("%s/%s: history entry %d out of order: %s -> %s", cluster, app, i, entry.PreviousVersion, entry.ApplicationVersion)
+						}
+					}
+				}
+			}
+			if q.Len() != 0 {
+				t.Errorf("expected all events to be acknowledged, %d events pending", q.Len())
+			}
+		})
+	}
+}
+
+func Test_Handler_BatchFallsBackToSingleEvents(t *testing.T) {
+	gdb, err := db.NewDBConnection(&mopsos.Config{
+		DBProvider: "sqlite",
+		DBDSN:      "file:Test_Handler_BatchFallsBackToSingleEvents?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	// without the records table every write fails
+	if err := gdb.AutoMigrate(&models.DeadLetter{}); err != nil {
+		t.Fatal(err)
+	}
+	h := mopsos.NewHandler(false, gdb).WithBatching(10, time.Millisecond)
+
+	q := queue.NewMemoryQueue(0)
+	for i := 0; i < 3; i++ {
+		if err := q.Enqueue(sequenceEvent("cluster", "app", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+	if err := h.HandleEvents(q); err != nil {
+		t.Fatalf("Handler.HandleEvents() error = %v", err)
+	}
+
+	// each event is dead-lettered on its own
+	var count int64
+	if err := gdb.Model(&models.DeadLetter{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 dead letters, got %d", count)
+	}
+}
+
+func Test_Handler_HandleSnapshot(t *testing.T) {
+	gdb := newTestDB(t, "Test_Handler_HandleSnapshot")
+	h := mopsos.NewHandler(false, gdb)
+
+	for _, record := range []models.Record{
+		{ClusterName: "cluster-a", InstanceId: "argocd-1", ApplicationName: "cert-manager", ApplicationVersion: "1.8.0"},
+		{ClusterName: "cluster-a", InstanceId: "argocd-1", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.0"},
+		{ClusterName: "cluster-a", InstanceId: "argocd-1", ApplicationName: "old-app", ApplicationVersion: "0.1.0"},
+		{ClusterName: "cluster-a", InstanceId: "argocd-2", ApplicationName: "other-instance", ApplicationVersion: "1.0.0"},
+		{ClusterName: "cluster-b", InstanceId: "argocd-1", ApplicationName: "old-app", ApplicationVersion: "0.1.0"},
+	} {
+		record := record
+		if err := h.HandleEvent(eventStub(&record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := h.HandleSnapshot(context.Background(), models.Snapshot{
+		ClusterName: "cluster-a",
+		InstanceId:  "argocd-1",
+		Records: []models.Record{
+			{ClusterName: "cluster-a", InstanceId: "argocd-1", ApplicationName: "cert-manager", ApplicationVersion: "1.9.1"},
+			{ClusterName: "cluster-a", InstanceId: "argocd-1", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.0"},
+			{ClusterName: "cluster-a", InstanceId: "argocd-1", ApplicationName: "new-app", ApplicationVersion: "1.0.0"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("HandleSnapshot() error = %v", err)
+	}
+	want := &models.SnapshotResult{Updated: 2, Unchanged: 1, Removed: 1}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("HandleSnapshot() = %+v, want %+v", result, want)
+	}
+
+	names := []string{}
+	if err := gdb.Model(&models.Record{}).Order("cluster_name, application_name").Pluck("cluster_name || '/' || application_name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"cluster-a/cert-manager", "cluster-a/ingress-nginx", "cluster-a/new-app", "cluster-a/other-instance", "cluster-b/old-app"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("expected records %v, got %v", wantNames, names)
+	}
+
+	removal := models.RecordHistory{}
+	if err := gdb.Where("action = ?", models.HistoryRemove).Take(&removal).Error; err != nil {
+		t.Fatalf("expected removal in history: %v", err)
+	}
+	if removal.ClusterName != "cluster-a" || removal.ApplicationName != "old-app" || removal.PreviousVersion != "0.1.0" {
+		t.Errorf("unexpected removal %+v", removal)
+	}
+
+	// a removed application that comes back is restored
+	if err := h.HandleEvent(eventStub(&models.Record{
+		ClusterName: "cluster-a", InstanceId: "argocd-1", ApplicationName: "old-app", ApplicationVersion: "0.2.0",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	restored := models.Record{}
+	if err := gdb.Where("cluster_name = ? AND application_name = ?", "cluster-a", "old-app").Take(&restored).Error; err != nil {
+		t.Fatalf("expected restored record: %v", err)
+	}
+	if restored.ApplicationVersion != "0.2.0" {
+		t.Errorf("expected restored version 0.2.0, got %s", restored.ApplicationVersion)
+	}
+	readded := models.RecordHistory{}
+	if err := gdb.Where("application_name = ? AND application_version = ?", "old-app", "0.2.0").Take(&readded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if readded.PreviousVersion != "" || readded.Action != models.HistoryUpdate {
+		t.Errorf("expected the restored record to be added anew, got %+v", readded)
+	}
+}
+
+func Test_Handler_DeleteEvents(t *testing.T) {
+	deleteStub := func(record *models.Record) models.EventData {
+		data := eventStub(record)
+		data.Action = models.ActionDelete
+		return data
+	}
+	app := func(name string, version string) *models.Record {
+		return &models.Record{ClusterName: "cluster", ApplicationName: name, ApplicationVersion: version}
+	}
+
+	tests := []struct {
+		name  string
+		batch bool
+	}{
+		{name: "single events"},
+		{name: "batch", batch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gdb := newTestDB(t, "Test_Handler_DeleteEvents_"+strings.ReplaceAll(tt.name, " ", "_"))
+			h := mopsos.NewHandler(false, gdb)
+			for _, name := range []string{"kept", "deleted", "recreated"} {
+				if err := h.HandleEvent(eventStub(app(name, "1.0.0"))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			events := []models.EventData{
+				deleteStub(app("deleted", "")),
+				deleteStub(app("recreated", "")),
+				eventStub(app("recreated", "2.0.0")),
+				eventStub(app("short-lived", "1.0.0")),
+				deleteStub(app("short-lived", "")),
+				deleteStub(app("unknown", "")),
+			}
+			if tt.batch {
+				if err := h.HandleBatch(events); err != nil {
+					t.Fatalf("HandleBatch() error = %v", err)
+				}
+			} else {
+				for _, data := range events {
+					if err := h.HandleEvent(data); err != nil {
+						t.Fatalf("HandleEvent() error = %v", err)
+					}
+				}
+			}
+
+			names := []string{}
+			if err := gdb.Model(&models.Record{}).Order("application_name").Pluck("application_name", &names).Error; err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"kept", "recreated"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("expected records %v, got %v", want, names)
+			}
+
+			removals := []string{}
+			if err := gdb.Model(&models.RecordHistory{}).Where("action = ?", models.HistoryRemove).
+				Order("application_name").Pluck("application_name", &removals).Error; err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"deleted", "recreated", "short-lived"}; !reflect.DeepEqual(removals, want) {
+				t.Errorf("expected removals of %v, got %v", want, removals)
+			}
+		})
+	}
+}
+
+func Test_Handler_Images(t *testing.T) {
+	gdb := newTestDB(t, "Test_Handler_Images")
+	h := mopsos.NewHandler(false, gdb)
+
+	event := func(version string, images string) models.EventData {
+		data := eventStub(&models.Record{ClusterName: "cluster", ApplicationName: "cert-manager", ApplicationVersion: version})
+		if images != "" {
+			if err := json.Unmarshal([]byte(images), &data.Record.Images); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return data
+	}
+	repositories := func() []string {
+		repositories := []string{}
+		if err := gdb.Model(&models.Image{}).Order("repository").Pluck("repository", &repositories).Error; err != nil {
+			t.Fatal(err)
+		}
+		return repositories
+	}
+
+	steps := []struct {
+		name  string
+		data  models.EventData
+		batch bool
+		want  []string
+	}{
+		{
+			name: "images are stored",
+			data: event("1.8.0", `["quay.io/jetstack/cert-manager-controller:v1.8.0","quay.io/jetstack/cert-manager-webhook:v1.8.0"]`),
+			want: []string{"jetstack/cert-manager-controller", "jetstack/cert-manager-webhook"},
+		},
+		{
+			name: "events without images keep them",
+			data: event("1.8.0", ""),
+			want: []string{"jetstack/cert-manager-controller", "jetstack/cert-manager-webhook"},
+		},
+		{
+			name:  "images are replaced",
+			data:  event("1.9.1", `[{"registry":"quay.io","repository":"jetstack/cert-manager-controller","tag":"v1.9.1"}]`),
+			batch: true,
+			want:  []string{"jetstack/cert-manager-controller"},
+		},
+		{
+			name: "images are removed with the record",
+			data: func() models.EventData {
+				data := event("", "")
+				data.Action = models.ActionDelete
+				return data
+			}(),
+			want: []string{},
+		},
+	}
+	for _, step := range steps {
+		var err error
+		if step.batch {
+			err = h.HandleBatch([]models.EventData{step.data})
+		} else {
+			err = h.HandleEvent(step.data)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := repositories(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: expected images %v, got %v", step.name, step.want, got)
+		}
+	}
+}
+
+func Test_Handler_Compliance(t *testing.T) {
+	gdb := newTestDB(t, "Test_Handler_Compliance")
+	engine := compliance.NewEngine(gdb)
+	if _, err := engine.SaveRule(context.Background(), models.ComplianceRule{Name: "minimum", Version: ">=1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	h := mopsos.NewHandler(false, gdb).WithCompliance(engine)
+
+	app := func(name string, version string) *models.Record {
+		return &models.Record{ClusterName: "cluster", ApplicationName: name, ApplicationVersion: version}
+	}
+	results := func() map[string]bool {
+		stored := []models.ComplianceResult{}
+		if err := gdb.Find(&stored).Error; err != nil {
+			t.Fatal(err)
+		}
+		compliant := map[string]bool{}
+		for _, result := range stored {
+			compliant[result.ApplicationName] = result.Compliant
+		}
+		return compliant
+	}
+
+	if err := h.HandleEvent(eventStub(app("old", "0.9.0"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.HandleEvent(eventStub(app("removed", "1.0.0"))); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"old": false, "removed": true}; !reflect.DeepEqual(results(), want) {
+		t.Fatalf("expected results %v, got %v", want, results())
+	}
+
+	// upgrades and removals replace the results
+	deleted := eventStub(app("removed", ""))
+	deleted.Action = models.ActionDelete
+	if err := h.HandleBatch([]models.EventData{eventStub(app("old", "1.1.0")), deleted}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"old": true}; !reflect.DeepEqual(results(), want) {
+		t.Fatalf("expected results %v, got %v", want, results())
+	}
+
+	_, err := h.HandleSnapshot(context.Background(), models.Snapshot{
+		ClusterName: "cluster",
+		Records:     []models.Record{*app("new", "0.1.0")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"new": false}; !reflect.DeepEqual(results(), want) {
+		t.Errorf("expected results %v, got %v", want, results())
+	}
+}
+
+func Test_Handler_Upstream(t *testing.T) {
+	gdb := newTestDB(t, "Test_Handler_Upstream")
+	if err := gdb.Create(&models.UpstreamRelease{ChartName: "cert-manager", LatestVersion: "v1.9.1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := mopsos.NewHandler(false, gdb).WithUpstream(upstream.NewTracker(gdb, nil, nil))
+
+	app := func(name string, version string) *models.Record {
+		return &models.Record{ClusterName: "cluster", ApplicationName: name, ApplicationVersion: version}
+	}
+	statuses := func() map[string]string {
+		stored := []models.UpstreamStatus{}
+		if err := gdb.Find(&stored).Error; err != nil {
+			t.Fatal(err)
+		}
+		status := map[string]string{}
+		for _, row := range stored {
+			status[row.ApplicationName] = row.Status
+		}
+		return status
+	}
+
+	if err := h.HandleEvent(eventStub(app("cert-manager", "v1.8.0"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.HandleEvent(eventStub(app("custom-app", "0.1.0"))); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"cert-manager": "outdated", "custom-app": "unknown"}; !reflect.DeepEqual(statuses(), want) {
+		t.Fatalf("expected statuses %v, got %v", want, statuses())
+	}
+
+	// upgrades and removals replace the statuses
+	deleted := eventStub(app("custom-app", ""))
+	deleted.Action = models.ActionDelete
+	if err := h.HandleBatch([]models.EventData{eventStub(app("cert-manager", "v1.9.1")), deleted}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"cert-manager": "current"}; !reflect.DeepEqual(statuses(), want) {
+		t.Errorf("expected statuses %v, got %v", want, statuses())
+	}
+}
@@ -0,0 +1,79 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultRegistry is the registry of references without a registry
+	DefaultRegistry = "docker.io"
+	// DefaultTag is the tag of references without a tag or digest
+	DefaultTag = "latest"
+)
+
+// ErrInvalidReference is returned by Parse for malformed image references
+var ErrInvalidReference = errors.New("invalid image reference")
+
+// Reference is a parsed container image reference
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Parse parses an image reference like quay.io/jetstack/cert-manager-controller:v1.9.1
+//
+// References are normalized like docker does, nginx:1.23 is parsed as
+// docker.io/library/nginx:1.23. References without a tag or digest get the
+// latest tag, references with a digest keep the tag if they have one.
+func Parse(s string) (*Reference, error) {
+	ref := &Reference{}
+	name := strings.TrimSpace(s)
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !strings.Contains(ref.Digest, ":") {
+			return nil, fmt.Errorf("%w %q: digest must be algorithm:hex", ErrInvalidReference, s)
+		}
+	}
+	// a colon after the last slash separates the tag, others belong to the registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+
+	ref.Registry, ref.Repository = DefaultRegistry, name
+	if i := strings.Index(name, "/"); i >= 0 && isRegistry(name[:i]) {
+		ref.Registry, ref.Repository = name[:i], name[i+1:]
+	}
+	if ref.Registry == DefaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+
+	if ref.Repository == "" || ref.Repository == "library/" || strings.HasSuffix(ref.Repository, "/") {
+		return nil, fmt.Errorf("%w %q: missing repository", ErrInvalidReference, s)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = DefaultTag
+	}
+	return ref, nil
+}
+
+// String returns the normalized reference
+func (r *Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// isRegistry reports whether the first component of a reference is a registry host
+func isRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
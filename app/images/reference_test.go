@@ -0,0 +1,42 @@
+package images_test
+
+import (
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/images"
+)
+
+func Test_Parse(t *testing.T) {
+	digest := "sha256:4f6d9a1c"
+	tests := []struct {
+		name    string
+		input   string
+		want    images.Reference
+		wantErr bool
+	}{
+		{name: "official image", input: "nginx:1.23", want: images.Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.23"}},
+		{name: "docker hub image", input: "bitnami/redis:7.0.5", want: images.Reference{Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.0.5"}},
+		{name: "registry", input: "quay.io/jetstack/cert-manager-controller:v1.9.1", want: images.Reference{Registry: "quay.io", Repository: "jetstack/cert-manager-controller", Tag: "v1.9.1"}},
+		{name: "registry with port", input: "registry.local:5000/team/app", want: images.Reference{Registry: "registry.local:5000", Repository: "team/app", Tag: "latest"}},
+		{name: "localhost", input: "localhost/app:1", want: images.Reference{Registry: "localhost", Repository: "app", Tag: "1"}},
+		{name: "digest", input: "ghcr.io/org/app@" + digest, want: images.Reference{Registry: "ghcr.io", Repository: "org/app", Digest: digest}},
+		{name: "tag and digest", input: "ghcr.io/org/app:1.0@" + digest, want: images.Reference{Registry: "ghcr.io", Repository: "org/app", Tag: "1.0", Digest: digest}},
+		{name: "invalid digest", input: "app@4f6d", wantErr: true},
+		{name: "missing repository", input: "quay.io/", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := images.Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
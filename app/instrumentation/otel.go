@@ -53,6 +53,9 @@ func InitInstrumentation(collectorTarget string) func() {
 		if tracerProvider == nil {
 			return
 		}
+		// the context used for connecting is cancelled by now, flushing gets its own timeout
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 		if err := tracerProvider.Shutdown(ctx); err != nil {
 			log.Printf("Error shutting down the tracer provider: %v", err)
 		}
@@ -0,0 +1,105 @@
+package lifecycle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// dateLayout is the format of dates in endoflife.date datasets
+const dateLayout = "2006-01-02"
+
+// Cycle is a release cycle of a product in the format of the endoflife.date API
+type Cycle struct {
+	// Cycle is the version prefix of the releases in the cycle, i.e. 1.9
+	Cycle       string `json:"cycle" yaml:"cycle"`
+	ReleaseDate Date   `json:"releaseDate" yaml:"releaseDate"`
+	EOL         EOL    `json:"eol" yaml:"eol"`
+	Latest      string `json:"latest" yaml:"latest"`
+}
+
+// Date is a date without time, it is zero if not set
+type Date struct {
+	time.Time
+}
+
+// UnmarshalYAML parses dates in the YYYY-MM-DD format
+func (d *Date) UnmarshalYAML(node *yaml.Node) error {
+	t, err := time.Parse(dateLayout, node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	d.Time = t
+	return nil
+}
+
+// EOL is the end of life of a cycle, either a date or whether it has ended if the date is unknown
+type EOL struct {
+	Date  *time.Time
+	Ended bool
+}
+
+// UnmarshalYAML parses the eol field, which is a date or a boolean
+func (e *EOL) UnmarshalYAML(node *yaml.Node) error {
+	if node.Tag == "!!bool" {
+		return node.Decode(&e.Ended)
+	}
+	t, err := time.Parse(dateLayout, node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	e.Date = &t
+	return nil
+}
+
+// Load reads the release cycles of products from a file or directory
+//
+// A directory contains a file per product named after the product, like
+// the responses of https://endoflife.date/api/{product}.json. A file maps
+// products to their cycles. JSON and YAML are both accepted. Files of a
+// directory that fail to decode are logged and skipped.
+func Load(path string) (map[string][]Cycle, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	products := map[string][]Cycle{}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &products); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", path, err)
+		}
+		return products, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		cycles := []Cycle{}
+		if err := yaml.Unmarshal(data, &cycles); err != nil {
+			logrus.WithError(err).WithField("file", entry.Name()).Error("failed to decode lifecycle file")
+			continue
+		}
+		products[strings.TrimSuffix(entry.Name(), ext)] = cycles
+	}
+	return products, nil
+}
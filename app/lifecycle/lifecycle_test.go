@@ -0,0 +1,178 @@
+package lifecycle_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func Test_Load(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    map[string]int
+		wantErr bool
+	}{
+		{name: "directory", path: "testdata/products", want: map[string]int{"cert-manager": 3, "postgresql": 2}},
+		{name: "file", path: "testdata/products.yaml", want: map[string]int{"kubernetes": 2}},
+		{name: "malformed file skipped", path: "testdata/malformed", want: map[string]int{"redis": 1}},
+		{name: "missing", path: "testdata/missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := lifecycle.Load(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(products) != len(tt.want) {
+				t.Fatalf("expected products %v, got %v", tt.want, products)
+			}
+			for product, cycles := range tt.want {
+				if len(products[product]) != cycles {
+					t.Errorf("expected %d cycles of %s, got %v", cycles, product, products[product])
+				}
+			}
+		})
+	}
+
+	products, _ := lifecycle.Load("testdata/products")
+	if cycle := products["cert-manager"][2]; !cycle.EOL.Ended || cycle.EOL.Date != nil {
+		t.Errorf("expected eol: true to end the cycle without a date, got %+v", cycle.EOL)
+	}
+	if cycle := products["postgresql"][0]; cycle.Cycle != "15" || cycle.EOL.Date == nil || cycle.EOL.Date.Year() != 2027 {
+		t.Errorf("expected the eol date of postgresql 15, got %+v", cycle)
+	}
+}
+
+func Test_MatchCycle(t *testing.T) {
+	cycles := []models.ProductCycle{{Cycle: "1"}, {Cycle: "1.9"}, {Cycle: "1.10"}, {Cycle: "focal"}}
+
+	tests := []struct {
+		installed string
+		want      string
+	}{
+		{installed: "v1.9.1", want: "1.9"},
+		{installed: "1.10.0", want: "1.10"},
+		{installed: "1.1.0", want: "1"},
+		{installed: "2.0.0"},
+		{installed: "latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.installed, func(t *testing.T) {
+			got := lifecycle.MatchCycle(cycles, tt.installed)
+			if (got == nil) != (tt.want == "") || (got != nil && got.Cycle != tt.want) {
+				t.Errorf("MatchCycle(%q) = %+v, want %q", tt.installed, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Checker(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:Test_Checker?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Record{}, &models.ProductCycle{}, &models.RecordCycle{}); err != nil {
+		t.Fatal(err)
+	}
+	checker := lifecycle.NewChecker(gdb, map[string]string{"postgres": "postgresql"})
+	// importing twice replaces the first import
+	for i := 0; i < 2; i++ {
+		if err := lifecycle.NewImporter(gdb, "testdata/products", checker).Import(context.Background()); err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+	}
+
+	now := time.Date(2023, 2, 1, 12, 0, 0, 0, time.UTC)
+	supports, err := checker.Check(context.Background(), []models.Record{
+		{ApplicationName: "cert-manager", ApplicationVersion: "v1.10.0"},
+		{ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		{ApplicationName: "cert-manager", ApplicationVersion: "v1.8.0"},
+		{ApplicationName: "postgres", ApplicationVersion: "14.5"},
+		{ApplicationName: "custom-app", ApplicationVersion: "1.0.0"},
+	}, now)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	days := func(d int) *int { return &d }
+	want := []struct {
+		product string
+		cycle   string
+		status  lifecycle.Status
+		days    *int
+	}{
+		{product: "cert-manager", cycle: "1.10", status: lifecycle.StatusSupported, days: days(133)},
+		{product: "cert-manager", cycle: "1.9", status: lifecycle.StatusSupported, days: days(8)},
+		{product: "cert-manager", cycle: "1.8", status: lifecycle.StatusEOL},
+		{product: "postgresql", cycle: "14", status: lifecycle.StatusSupported, days: days(1380)},
+		{status: lifecycle.StatusUnknown},
+	}
+	for i, w := range want {
+		got := supports[i]
+		if got.Product != w.product || got.Cycle != w.cycle || got.Status != w.status ||
+			(got.DaysUntilEOL == nil) != (w.days == nil) || (w.days != nil && *got.DaysUntilEOL != *w.days) {
+			t.Errorf("record %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+}
+
+func Test_CheckerEvaluate(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:Test_CheckerEvaluate?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Record{}, &models.ProductCycle{}, &models.RecordCycle{}); err != nil {
+		t.Fatal(err)
+	}
+	records := []models.Record{
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.8.0"},
+		{ClusterName: "cluster-a", ApplicationName: "custom-app", ApplicationVersion: "1.0.0"},
+	}
+	if err := gdb.Create(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// records written before the import get their cycle with the import
+	checker := lifecycle.NewChecker(gdb, nil)
+	if err := lifecycle.NewImporter(gdb, "testdata/products", checker).Import(context.Background()); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	cycles := []models.RecordCycle{}
+	if err := gdb.Find(&cycles).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != 1 || cycles[0].ApplicationName != "cert-manager" || cycles[0].Cycle != "1.8" || !cycles[0].EOLReached {
+		t.Fatalf("expected only the ended cycle 1.8 of cert-manager, got %+v", cycles)
+	}
+
+	// records written later are evaluated by themselves
+	records[0].ApplicationVersion = "v1.9.1"
+	if err := checker.Evaluate(gdb, records[:1]); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	cycles = []models.RecordCycle{}
+	if err := gdb.Find(&cycles).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != 1 || cycles[0].Cycle != "1.9" || cycles[0].EOLDate == nil {
+		t.Fatalf("expected the cycle to be replaced by 1.9, got %+v", cycles)
+	}
+
+	if err := checker.Remove(gdb, []models.RecordKey{records[0].Key()}); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	var count int64
+	if err := gdb.Model(&models.RecordCycle{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected the cycle to be removed, got %d", count)
+	}
+}
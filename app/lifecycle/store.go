@@ -0,0 +1,106 @@
+package lifecycle
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// Import replaces the stored release cycles with the cycles of products in one transaction
+func Import(ctx context.Context, db *gorm.DB, products map[string][]Cycle) error {
+	names := make([]string, 0, len(products))
+	for product := range products {
+		names = append(names, product)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	rows := []models.ProductCycle{}
+	for _, product := range names {
+		for _, cycle := range products[product] {
+			row := models.ProductCycle{
+				ImportedAt: now,
+				Product:    product,
+				Cycle:      cycle.Cycle,
+				EOLDate:    cycle.EOL.Date,
+				EOLReached: cycle.EOL.Ended,
+				Latest:     cycle.Latest,
+			}
+			if !cycle.ReleaseDate.IsZero() {
+				releaseDate := cycle.ReleaseDate.Time
+				row.ReleaseDate = &releaseDate
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.ProductCycle{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+// Importer periodically imports a lifecycle dataset from the local filesystem
+//
+// After each import the checker replaces the cycles of all records.
+type Importer struct {
+	database *gorm.DB
+	checker  *Checker
+
+	path string
+}
+
+// NewImporter creates an importer for the dataset at path
+func NewImporter(db *gorm.DB, path string, checker *Checker) *Importer {
+	return &Importer{
+		database: db,
+		checker:  checker,
+		path:     path,
+	}
+}
+
+// Run imports the dataset every interval until the context is done
+func (i *Importer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := i.Import(ctx); err != nil {
+			logrus.WithError(err).WithField("path", i.path).Error("failed to import lifecycle dataset")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Import loads the dataset, replaces the stored release cycles and updates the cycles of all records
+func (i *Importer) Import(ctx context.Context) error {
+	products, err := Load(i.path)
+	if err != nil {
+		return err
+	}
+	if err := Import(ctx, i.database, products); err != nil {
+		return err
+	}
+	if err := i.checker.EvaluateAll(ctx); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"path":     i.path,
+		"products": len(products),
+	}).Info("imported lifecycle dataset")
+	return nil
+}
@@ -0,0 +1,236 @@
+package lifecycle
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+// Status describes whether the version of a record is still supported
+type Status string
+
+const (
+	StatusSupported Status = "supported"
+	StatusEOL       Status = "eol"
+	// StatusUnknown is used for records without a product or a matching cycle
+	StatusUnknown Status = "unknown"
+)
+
+// Support is the support status of a record
+type Support struct {
+	Product string     `json:"product,omitempty"`
+	Cycle   string     `json:"cycle,omitempty"`
+	EOLDate *time.Time `json:"eol_date,omitempty"`
+	Status  Status     `json:"support_status"`
+	// DaysUntilEOL is negative after the end of life and unset if the date is unknown
+	DaysUntilEOL *int `json:"days_until_eol,omitempty"`
+}
+
+// evaluateBatchSize limits the rows per insert statement, sqlite allows only so many variables
+const evaluateBatchSize = 500
+
+// Checker determines the support status of records from the stored release cycles
+//
+// Records are matched to products by their application name, the mapping
+// translates application names that differ from the product name. The cycle
+// of each record is stored with the record key, so records can be filtered
+// by their support status.
+type Checker struct {
+	database *gorm.DB
+
+	mapping map[string]string
+}
+
+// NewChecker creates a checker with a mapping from application names to products
+func NewChecker(db *gorm.DB, mapping map[string]string) *Checker {
+	return &Checker{
+		database: db,
+		mapping:  mapping,
+	}
+}
+
+// Product returns the product an application is tracked under
+func (c *Checker) Product(applicationName string) string {
+	if product, ok := c.mapping[applicationName]; ok {
+		return product
+	}
+	return applicationName
+}
+
+// Check returns the support status of each record as of now
+func (c *Checker) Check(ctx context.Context, records []models.Record, now time.Time) ([]Support, error) {
+	cycles, err := c.match(c.database.WithContext(ctx), records)
+	if err != nil {
+		return nil, err
+	}
+	supports := make([]Support, len(records))
+	for i := range records {
+		supports[i] = SupportOf(cycles[i], now)
+	}
+	return supports, nil
+}
+
+// EvaluateAll replaces the cycles of all records
+func (c *Checker) EvaluateAll(ctx context.Context) error {
+	return c.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.RecordCycle{}).Error; err != nil {
+			return err
+		}
+		records := []models.Record{}
+		if err := tx.Find(&records).Error; err != nil {
+			return err
+		}
+		return c.Evaluate(tx, records)
+	})
+}
+
+// Evaluate replaces the cycles of records within a transaction
+func (c *Checker) Evaluate(tx *gorm.DB, records []models.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	keys := make([]models.RecordKey, len(records))
+	for i, record := range records {
+		keys[i] = record.Key()
+	}
+	if err := c.Remove(tx, keys); err != nil {
+		return err
+	}
+
+	cycles, err := c.match(tx, records)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	rows := []models.RecordCycle{}
+	for i, record := range records {
+		if cycles[i] == nil {
+			continue
+		}
+		rows = append(rows, models.RecordCycle{
+			EvaluatedAt:         now,
+			ClusterName:         record.ClusterName,
+			InstanceId:          record.InstanceId,
+			ApplicationName:     record.ApplicationName,
+			ApplicationInstance: record.ApplicationInstance,
+			ApplicationVersion:  record.ApplicationVersion,
+			Product:             cycles[i].Product,
+			Cycle:               cycles[i].Cycle,
+			EOLDate:             cycles[i].EOLDate,
+			EOLReached:          cycles[i].EOLReached,
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(rows, evaluateBatchSize).Error
+}
+
+// Remove deletes the cycles of records within a transaction, i.e. because the records were deleted
+func (c *Checker) Remove(tx *gorm.DB, keys []models.RecordKey) error {
+	for _, key := range keys {
+		if err := tx.Where(key.Conditions()).Delete(&models.RecordCycle{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match returns the cycle of each record, or nil if its version belongs to none
+func (c *Checker) match(db *gorm.DB, records []models.Record) ([]*models.ProductCycle, error) {
+	products := map[string]bool{}
+	for _, record := range records {
+		products[c.Product(record.ApplicationName)] = true
+	}
+	names := make([]string, 0, len(products))
+	for product := range products {
+		names = append(names, product)
+	}
+
+	cyclesByProduct := map[string][]models.ProductCycle{}
+	if len(names) > 0 {
+		rows := []models.ProductCycle{}
+		if err := db.Where("product IN ?", names).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			cyclesByProduct[row.Product] = append(cyclesByProduct[row.Product], row)
+		}
+	}
+
+	cycles := make([]*models.ProductCycle, len(records))
+	for i, record := range records {
+		cycles[i] = MatchCycle(cyclesByProduct[c.Product(record.ApplicationName)], record.ApplicationVersion)
+	}
+	return cycles, nil
+}
+
+// Today returns the day of now that end of life dates are compared with
+func Today(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// SupportOf returns the support status of a cycle as of now, cycle may be nil
+//
+// A cycle is supported until the end of its EOL date.
+func SupportOf(cycle *models.ProductCycle, now time.Time) Support {
+	if cycle == nil {
+		return Support{Status: StatusUnknown}
+	}
+	support := Support{
+		Product: cycle.Product,
+		Cycle:   cycle.Cycle,
+		EOLDate: cycle.EOLDate,
+		Status:  StatusSupported,
+	}
+	if cycle.EOLReached {
+		support.Status = StatusEOL
+	}
+	if cycle.EOLDate != nil {
+		days := int(cycle.EOLDate.Sub(Today(now)).Hours() / 24)
+		support.DaysUntilEOL = &days
+		if days < 0 {
+			support.Status = StatusEOL
+		}
+	}
+	return support
+}
+
+// MatchCycle returns the most specific cycle whose version is a prefix of installed, or nil
+//
+// A cycle 1.9 matches all 1.9.x versions, a cycle 3 all 3.x.y versions.
+// Cycles that are no version, like code names, never match.
+func MatchCycle(cycles []models.ProductCycle, installed string) *models.ProductCycle {
+	v, err := version.Parse(installed)
+	if err != nil {
+		return nil
+	}
+	segments := []int{v.Major, v.Minor, v.Patch}
+
+	var match *models.ProductCycle
+	matched := 0
+	for i := range cycles {
+		parts := strings.Split(strings.TrimPrefix(cycles[i].Cycle, "v"), ".")
+		if len(parts) > len(segments) || len(parts) <= matched {
+			continue
+		}
+		prefix := true
+		for j, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n != segments[j] {
+				prefix = false
+				break
+			}
+		}
+		if prefix {
+			match, matched = &cycles[i], len(parts)
+		}
+	}
+	return match
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// ComplianceCollector exposes the stored results of the compliance rules
+//
+// Results are written whenever a record changes, so a scrape only reads them.
+type ComplianceCollector struct {
+	database *gorm.DB
+
+	evaluated   *prometheus.Desc
+	violations  *prometheus.Desc
+	scrapeError *prometheus.Desc
+}
+
+// complianceCount is a row of the aggregated compliance results
+type complianceCount struct {
+	RuleName    string
+	ClusterName string
+	Evaluated   int
+	Violations  int
+}
+
+// NewComplianceCollector creates a collector for the compliance results in a database
+func NewComplianceCollector(db *gorm.DB) *ComplianceCollector {
+	return &ComplianceCollector{
+		database: db,
+		evaluated: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "compliance", "evaluated"),
+			"Number of applications a compliance rule applies to.",
+			[]string{"rule", "cluster"},
+			nil,
+		),
+		violations: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "compliance", "violations"),
+			"Number of applications violating a compliance rule.",
+			[]string{"rule", "cluster"},
+			nil,
+		),
+		scrapeError: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "compliance", "scrape_error"),
+			"1 if reading the compliance results failed, 0 otherwise.",
+			nil,
+			nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *ComplianceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.evaluated
+	ch <- c.violations
+	ch <- c.scrapeError
+}
+
+// Collect implements prometheus.Collector
+func (c *ComplianceCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := []complianceCount{}
+	err := c.database.WithContext(context.Background()).
+		Model(&models.ComplianceResult{}).
+		Select("rule_name, cluster_name, COUNT(*) AS evaluated, SUM(CASE WHEN compliant THEN 0 ELSE 1 END) AS violations").
+		Group("rule_name").Group("cluster_name").
+		Order("rule_name").Order("cluster_name").
+		Scan(&counts).Error
+	if err != nil {
+		logrus.WithError(err).Error("failed to read compliance results for metrics")
+		ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 0)
+
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.evaluated, prometheus.GaugeValue, float64(count.Evaluated), count.RuleName, count.ClusterName)
+		ch <- prometheus.MustNewConstMetric(c.violations, prometheus.GaugeValue, float64(count.Violations), count.RuleName, count.ClusterName)
+	}
+}
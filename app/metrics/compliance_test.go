@@ -0,0 +1,44 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func Test_ComplianceCollector(t *testing.T) {
+	gdb := newTestDB(t, "Test_ComplianceCollector")
+	if err := gdb.AutoMigrate(&models.ComplianceResult{}); err != nil {
+		t.Fatal(err)
+	}
+	err := gdb.Create(&[]models.ComplianceResult{
+		{RuleName: "minimum", ClusterName: "cluster-a", ApplicationName: "cert-manager", Compliant: true},
+		{RuleName: "minimum", ClusterName: "cluster-b", ApplicationName: "cert-manager", Compliant: false},
+		{RuleName: "minimum", ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationInstance: "second", Compliant: true},
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := metrics.NewComplianceCollector(gdb)
+
+	expected := `
+# HELP mopsos_compliance_evaluated Number of applications a compliance rule applies to.
+# TYPE mopsos_compliance_evaluated gauge
+mopsos_compliance_evaluated{cluster="cluster-a",rule="minimum"} 1
+mopsos_compliance_evaluated{cluster="cluster-b",rule="minimum"} 2
+# HELP mopsos_compliance_scrape_error 1 if reading the compliance results failed, 0 otherwise.
+# TYPE mopsos_compliance_scrape_error gauge
+mopsos_compliance_scrape_error 0
+# HELP mopsos_compliance_violations Number of applications violating a compliance rule.
+# TYPE mopsos_compliance_violations gauge
+mopsos_compliance_violations{cluster="cluster-a",rule="minimum"} 0
+mopsos_compliance_violations{cluster="cluster-b",rule="minimum"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
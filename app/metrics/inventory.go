@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// InventoryCollector exposes the stored records as info metrics
+//
+// The records are read from the database on every scrape so the metrics
+// always reflect the current inventory.
+type InventoryCollector struct {
+	database *gorm.DB
+
+	versionInfo *prometheus.Desc
+	scrapeError *prometheus.Desc
+}
+
+// NewInventoryCollector creates a collector for the records in a database
+func NewInventoryCollector(db *gorm.DB) *InventoryCollector {
+	return &InventoryCollector{
+		database: db,
+		versionInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "application", "version_info"),
+			"Installed version of an application, the value is always 1.",
+			[]string{"cluster", "instance_id", "app", "app_instance", "version"},
+			nil,
+		),
+		scrapeError: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "inventory", "scrape_error"),
+			"1 if reading the inventory from the database failed, 0 otherwise.",
+			nil,
+			nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *InventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.versionInfo
+	ch <- c.scrapeError
+}
+
+// Collect implements prometheus.Collector
+func (c *InventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	records := []models.Record{}
+	if err := c.database.Find(&records).Error; err != nil {
+		logrus.WithError(err).Error("failed to read inventory for metrics")
+		ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 0)
+
+	for _, record := range records {
+		ch <- prometheus.MustNewConstMetric(
+			c.versionInfo,
+			prometheus.GaugeValue,
+			1,
+			record.ClusterName,
+			record.InstanceId,
+			record.ApplicationName,
+			record.ApplicationInstance,
+			record.ApplicationVersion,
+		)
+	}
+}
+
+// errorLogger passes errors from promhttp to logrus
+type errorLogger struct{}
+
+func (errorLogger) Println(v ...interface{}) {
+	logrus.Error(v...)
+}
@@ -0,0 +1,77 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+func newTestDB(t *testing.T, name string, records ...models.Record) *gorm.DB {
+	gdb, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Record{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if err := gdb.Create(&records[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gdb
+}
+
+var inventoryRecords = []models.Record{
+	{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+	{ClusterName: "cluster-b", InstanceId: "b1", ApplicationName: "cert-manager", ApplicationInstance: "second", ApplicationVersion: "v1.8.0"},
+}
+
+func Test_InventoryCollector(t *testing.T) {
+	collector := metrics.NewInventoryCollector(newTestDB(t, "Test_InventoryCollector", inventoryRecords...))
+
+	expected := `
+# HELP mopsos_application_version_info Installed version of an application, the value is always 1.
+# TYPE mopsos_application_version_info gauge
+mopsos_application_version_info{app="cert-manager",app_instance="",cluster="cluster-a",instance_id="",version="v1.9.1"} 1
+mopsos_application_version_info{app="cert-manager",app_instance="second",cluster="cluster-b",instance_id="b1",version="v1.8.0"} 1
+# HELP mopsos_inventory_scrape_error 1 if reading the inventory from the database failed, 0 otherwise.
+# TYPE mopsos_inventory_scrape_error gauge
+mopsos_inventory_scrape_error 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	handler := metrics.Handler(metrics.NewInventoryCollector(newTestDB(t, "Test_Handler", inventoryRecords...)))
+
+	metrics.EventsAccepted.Inc()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/metrics", nil)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.Code)
+	}
+	for _, want := range []string{
+		"mopsos_events_accepted_total 1",
+		`mopsos_events_rejected_total{reason="authenticate"} 0`,
+		"mopsos_event_queue_depth 0",
+		`mopsos_application_version_info{app="cert-manager"`,
+	} {
+		if !strings.Contains(res.Body.String(), want) {
+			t.Errorf("expected metrics to contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mopsos"
+
+var (
+	// EventsAccepted counts the events that were put on the queue
+	EventsAccepted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_accepted_total",
+		Help:      "Number of events accepted by the webhook.",
+	})
+	// EventsRejected counts the requests rejected by the middlewares, labeled by the rejecting middleware
+	EventsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_rejected_total",
+		Help:      "Number of events rejected by the webhook.",
+	}, []string{"reason"})
+	// DatabaseWriteFailures counts the events the handler failed to store
+	DatabaseWriteFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "database_write_failures_total",
+		Help:      "Number of failed database writes while handling events.",
+	})
+	// EventsDeadLettered counts the events that were moved to the dead letters after failing all retries
+	EventsDeadLettered = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dead_lettered_total",
+		Help:      "Number of events moved to the dead letters.",
+	})
+	// EventsRateLimited counts the events rejected by the rate limit, labeled by cluster
+	EventsRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_rate_limited_total",
+		Help:      "Number of events rejected because the cluster exceeded its rate limit.",
+	}, []string{"cluster"})
+	// WebhookRequestsInFlight is the number of webhook requests being handled
+	WebhookRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "webhook_requests_in_flight",
+		Help:      "Number of webhook requests currently being handled.",
+	})
+	// QueueDepth is the number of accepted events that have not been handled yet
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_queue_depth",
+		Help:      "Number of accepted events waiting to be handled.",
+	})
+)
+
+// Rejection reasons used as label values of EventsRejected
+const (
+	ReasonAuthenticate = "authenticate"
+	ReasonAuthorize    = "authorize"
+	ReasonValidate     = "validate"
+	ReasonRateLimit    = "rate_limit"
+	ReasonConcurrency  = "concurrency"
+	ReasonBodySize     = "body_size"
+)
+
+// Registry holds the process wide metrics of mopsos
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+		EventsAccepted,
+		EventsRejected,
+		DatabaseWriteFailures,
+		EventsDeadLettered,
+		QueueDepth,
+		EventsRateLimited,
+		WebhookRequestsInFlight,
+	)
+	// initialize the label values so the series exist before the first rejection
+	EventsRejected.WithLabelValues(ReasonAuthenticate)
+	EventsRejected.WithLabelValues(ReasonAuthorize)
+	EventsRejected.WithLabelValues(ReasonValidate)
+	EventsRejected.WithLabelValues(ReasonRateLimit)
+	EventsRejected.WithLabelValues(ReasonConcurrency)
+	EventsRejected.WithLabelValues(ReasonBodySize)
+}
+
+// Handler serves the process wide metrics together with collectors reading the inventory from the database
+func Handler(collectors ...prometheus.Collector) http.Handler {
+	inventory := prometheus.NewRegistry()
+	inventory.MustRegister(collectors...)
+
+	return promhttp.HandlerFor(
+		prometheus.Gatherers{Registry, inventory},
+		promhttp.HandlerOpts{ErrorLog: errorLogger{}},
+	)
+}
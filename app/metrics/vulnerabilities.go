@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+// VulnerabilityCollector exposes the number of vulnerabilities affecting each record
+//
+// Like the inventory, the records are matched against the imported
+// vulnerabilities on every scrape. Nothing is matched until vulnerabilities
+// have been imported.
+type VulnerabilityCollector struct {
+	database *gorm.DB
+	matcher  *osv.Matcher
+
+	imported        *prometheus.Desc
+	vulnerabilities *prometheus.Desc
+	scrapeError     *prometheus.Desc
+}
+
+// NewVulnerabilityCollector creates a collector for the records in a database
+func NewVulnerabilityCollector(db *gorm.DB, matcher *osv.Matcher) *VulnerabilityCollector {
+	return &VulnerabilityCollector{
+		database: db,
+		matcher:  matcher,
+		imported: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "vulnerabilities", "imported"),
+			"Number of imported vulnerabilities.",
+			nil,
+			nil,
+		),
+		vulnerabilities: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "application", "vulnerabilities"),
+			"Number of known vulnerabilities affecting an application or its images.",
+			[]string{"cluster", "instance_id", "app", "app_instance", "severity"},
+			nil,
+		),
+		scrapeError: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "vulnerabilities", "scrape_error"),
+			"1 if matching the inventory against the vulnerabilities failed, 0 otherwise.",
+			nil,
+			nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *VulnerabilityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.imported
+	ch <- c.vulnerabilities
+	ch <- c.scrapeError
+}
+
+// Collect implements prometheus.Collector
+func (c *VulnerabilityCollector) Collect(ch chan<- prometheus.Metric) {
+	findings, imported, err := c.findings()
+	if err != nil {
+		logrus.WithError(err).Error("failed to match vulnerabilities for metrics")
+		ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 0)
+	ch <- prometheus.MustNewConstMetric(c.imported, prometheus.GaugeValue, float64(imported))
+
+	type series struct {
+		cluster, instance, app, appInstance, severity string
+	}
+	counts := map[series]int{}
+	order := []series{}
+	for _, finding := range findings {
+		s := series{finding.ClusterName, finding.InstanceId, finding.ApplicationName, finding.ApplicationInstance, finding.Severity}
+		if _, ok := counts[s]; !ok {
+			order = append(order, s)
+		}
+		counts[s]++
+	}
+	for _, s := range order {
+		ch <- prometheus.MustNewConstMetric(
+			c.vulnerabilities,
+			prometheus.GaugeValue,
+			float64(counts[s]),
+			s.cluster,
+			s.instance,
+			s.app,
+			s.appInstance,
+			s.severity,
+		)
+	}
+}
+
+// findings matches all records if any vulnerabilities were imported
+func (c *VulnerabilityCollector) findings() ([]osv.Finding, int64, error) {
+	ctx := context.Background()
+
+	var imported int64
+	if err := c.database.WithContext(ctx).Model(&models.Vulnerability{}).Count(&imported).Error; err != nil {
+		return nil, 0, err
+	}
+	if imported == 0 {
+		return nil, 0, nil
+	}
+
+	records := []models.Record{}
+	if err := c.database.WithContext(ctx).Preload("Images").Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	findings, err := c.matcher.Match(ctx, records)
+	return findings, imported, err
+}
@@ -0,0 +1,51 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+func Test_VulnerabilityCollector(t *testing.T) {
+	gdb := newTestDB(t, "Test_VulnerabilityCollector", inventoryRecords...)
+	if err := gdb.AutoMigrate(&models.Image{}, &models.Vulnerability{}, &models.VulnerablePackage{}); err != nil {
+		t.Fatal(err)
+	}
+	matcher := osv.NewMatcher(gdb, map[string]string{"cert-manager": "github.com/cert-manager/cert-manager"}, "")
+	collector := metrics.NewVulnerabilityCollector(gdb, matcher)
+
+	// nothing is matched before the first import
+	expected := `
+# HELP mopsos_vulnerabilities_imported Number of imported vulnerabilities.
+# TYPE mopsos_vulnerabilities_imported gauge
+mopsos_vulnerabilities_imported 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "mopsos_vulnerabilities_imported", "mopsos_application_vulnerabilities"); err != nil {
+		t.Error(err)
+	}
+
+	if err := osv.NewImporter(gdb, "../osv/testdata/advisories").Import(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected = `
+# HELP mopsos_application_vulnerabilities Number of known vulnerabilities affecting an application or its images.
+# TYPE mopsos_application_vulnerabilities gauge
+mopsos_application_vulnerabilities{app="cert-manager",app_instance="",cluster="cluster-a",instance_id="",severity="HIGH"} 1
+mopsos_application_vulnerabilities{app="cert-manager",app_instance="second",cluster="cluster-b",instance_id="b1",severity="HIGH"} 1
+# HELP mopsos_vulnerabilities_imported Number of imported vulnerabilities.
+# TYPE mopsos_vulnerabilities_imported gauge
+mopsos_vulnerabilities_imported 2
+# HELP mopsos_vulnerabilities_scrape_error 1 if matching the inventory against the vulnerabilities failed, 0 otherwise.
+# TYPE mopsos_vulnerabilities_scrape_error gauge
+mopsos_vulnerabilities_scrape_error 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
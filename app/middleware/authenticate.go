@@ -2,32 +2,116 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/adfinis-sygroup/mopsos/app/auth"
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
 	"github.com/adfinis-sygroup/mopsos/app/types"
 )
 
-// Authenticate middleware handles checking credentials
+var (
+	// ErrNoCredentials is returned by an Authenticator if a request carries none of the credentials it understands
+	ErrNoCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials is returned by an Authenticator if the credentials of a request are wrong
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator identifies the cluster or user that sent a request
+type Authenticator interface {
+	// Authenticate returns the name of the cluster or user that sent the request
+	Authenticate(r *http.Request) (string, error)
+}
+
+// Verifier checks the credentials of a user
+type Verifier func(ctx context.Context, username, password string) bool
+
+// StaticUsers verifies credentials against a fixed list of users
+//
+// The passwords may be plaintext or hashed, see auth.VerifyPassword.
+func StaticUsers(users map[string]string) Verifier {
+	return func(ctx context.Context, username, password string) bool {
+		return auth.VerifyUser(users, username, password)
+	}
+}
+
+// BasicAuth authenticates requests using basic auth
+type BasicAuth struct {
+	verifiers []Verifier
+}
+
+// NewBasicAuth creates a basic auth authenticator, the first verifier accepting the credentials wins
+func NewBasicAuth(verifiers ...Verifier) *BasicAuth {
+	return &BasicAuth{verifiers: verifiers}
+}
+
+// Authenticate implements Authenticator
+func (b *BasicAuth) Authenticate(r *http.Request) (string, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", ErrNoCredentials
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"username": username,
+	}).Debug("checking credentials")
+	for _, verifier := range b.verifiers {
+		if verifier(r.Context(), username, password) {
+			return username, nil
+		}
+	}
+	return "", ErrInvalidCredentials
+}
+
+// Authenticate middleware handles checking the credentials of clusters sending events
+//
+// The passwords of basicAuthUsers may be plaintext or hashed, see auth.VerifyPassword.
 func Authenticate(next http.Handler, basicAuthUsers map[string]string) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// get basic auth credentials
-		username, password, ok := r.BasicAuth()
-		if !ok {
-			http.Error(w, "missing Authorization header", http.StatusUnauthorized)
-			return
+	return AuthenticateEvents(next, NewBasicAuth(StaticUsers(basicAuthUsers)))
+}
+
+// AuthenticateWith checks credentials using the authenticators in order
+//
+// Authenticators that find none of their credentials on a request are
+// skipped, the first authenticator that finds credentials decides.
+func AuthenticateWith(next http.Handler, authenticators ...Authenticator) http.Handler {
+	return authenticate(next, false, authenticators)
+}
+
+// AuthenticateEvents checks credentials like AuthenticateWith and counts rejections as rejected events
+//
+// It protects the endpoints clusters send events to, rejected logins of the
+// API are not counted.
+func AuthenticateEvents(next http.Handler, authenticators ...Authenticator) http.Handler {
+	return authenticate(next, true, authenticators)
+}
+
+func authenticate(next http.Handler, countRejected bool, authenticators []Authenticator) http.Handler {
+	reject := func(w http.ResponseWriter, err error) {
+		if countRejected {
+			metrics.EventsRejected.WithLabelValues(metrics.ReasonAuthenticate).Inc()
 		}
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, authenticator := range authenticators {
+			username, err := authenticator.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				logrus.WithError(err).Debug("rejecting credentials")
+				reject(w, err)
+				return
+			}
 
-		logrus.WithFields(logrus.Fields{
-			"username": username,
-		}).Debug("checking credentials")
-		if basicAuthUsers[username] != password {
-			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			ctx := context.WithValue(r.Context(), types.ContextUsername, username)
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
-		ctx := context.WithValue(r.Context(), types.ContextUsername, username)
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+		reject(w, ErrNoCredentials)
 	})
 }
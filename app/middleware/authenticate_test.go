@@ -1,10 +1,15 @@
 package middleware_test
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/adfinis-sygroup/mopsos/app/auth"
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
 	"github.com/adfinis-sygroup/mopsos/app/middleware"
 	"github.com/adfinis-sygroup/mopsos/app/types"
 )
@@ -50,6 +55,7 @@ func Test_AuthenticateNoHeader(t *testing.T) {
 }
 
 func Test_AuthenticateInvalidUser(t *testing.T) {
+	rejected := testutil.ToFloat64(metrics.EventsRejected.WithLabelValues(metrics.ReasonAuthenticate))
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("handler should not be called")
@@ -66,4 +72,77 @@ func Test_AuthenticateInvalidUser(t *testing.T) {
 	if res.Result().StatusCode != http.StatusUnauthorized {
 		t.Errorf("status code should be 401")
 	}
+	if testutil.ToFloat64(metrics.EventsRejected.WithLabelValues(metrics.ReasonAuthenticate)) != rejected+1 {
+		t.Errorf("rejection should be counted")
+	}
+}
+
+func Test_AuthenticateHashedPassword(t *testing.T) {
+	hash, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := map[string]string{"username": hash}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{name: "valid password", username: "username", password: "password", want: http.StatusOK},
+		{name: "hash as password", username: "username", password: hash, want: http.StatusUnauthorized},
+		{name: "unknown user without password", username: "unknown", password: "", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.SetBasicAuth(tt.username, tt.password)
+			res := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			middleware.Authenticate(handler, users).ServeHTTP(res, req)
+
+			if res.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, res.Code)
+			}
+		})
+	}
+}
+
+func Test_AuthenticateWith(t *testing.T) {
+	store := func(ctx context.Context, username, password string) bool {
+		return username == "stored" && password == "token"
+	}
+	auth := middleware.AuthenticateWith(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		middleware.NewBasicAuth(middleware.StaticUsers(map[string]string{"static": "password"}), store),
+	)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{name: "static user", username: "static", password: "password", want: http.StatusOK},
+		{name: "stored user", username: "stored", password: "token", want: http.StatusOK},
+		{name: "invalid credentials", username: "stored", password: "password", want: http.StatusUnauthorized},
+	}
+	rejected := testutil.ToFloat64(metrics.EventsRejected.WithLabelValues(metrics.ReasonAuthenticate))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.SetBasicAuth(tt.username, tt.password)
+			res := httptest.NewRecorder()
+			auth.ServeHTTP(res, req)
+			if res.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, res.Code)
+			}
+		})
+	}
+	// only the endpoints receiving events count rejections
+	if testutil.ToFloat64(metrics.EventsRejected.WithLabelValues(metrics.ReasonAuthenticate)) != rejected {
+		t.Error("rejected API logins should not be counted as rejected events")
+	}
 }
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
+	"github.com/adfinis-sygroup/mopsos/app/rbac"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+// Authorize middleware checks that the authenticated user has a role
+//
+// It must be wrapped by one of the authenticating middlewares. The identity of
+// the user is added to the request context so handlers can limit what it sees.
+func Authorize(next http.Handler, policy *rbac.Policy, role rbac.Role) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, ok := r.Context().Value(types.ContextUsername).(string)
+		if !ok {
+			http.Error(w, ErrNoCredentials.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		identity := policy.Identity(username, role)
+		if !identity.Has(role) {
+			logrus.WithFields(logrus.Fields{
+				"username": username,
+				"role":     role,
+			}).Debug("denying access")
+			if role == rbac.RoleIngest {
+				metrics.EventsRejected.WithLabelValues(metrics.ReasonAuthorize).Inc()
+			}
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), types.ContextIdentity, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
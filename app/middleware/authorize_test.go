@@ -0,0 +1,58 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/middleware"
+	"github.com/adfinis-sygroup/mopsos/app/rbac"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+func Test_Authorize(t *testing.T) {
+	policy, err := rbac.NewPolicy([]rbac.Identity{
+		{Name: "team-a", Roles: []rbac.Role{rbac.RoleReader}, Clusters: []string{"cluster-a"}},
+		{Name: "ops", Roles: []rbac.Role{rbac.RoleAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		username   string
+		role       rbac.Role
+		wantStatus int
+	}{
+		{name: "reader with role", username: "team-a", role: rbac.RoleReader, wantStatus: http.StatusOK},
+		{name: "reader without admin role", username: "team-a", role: rbac.RoleAdmin, wantStatus: http.StatusForbidden},
+		{name: "admin may read", username: "ops", role: rbac.RoleReader, wantStatus: http.StatusOK},
+		{name: "reader may not ingest", username: "team-a", role: rbac.RoleIngest, wantStatus: http.StatusForbidden},
+		{name: "user without identity", username: "cluster-a", role: rbac.RoleIngest, wantStatus: http.StatusOK},
+		{name: "unauthenticated", role: rbac.RoleReader, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity *rbac.Identity
+			handler := middleware.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity = r.Context().Value(types.ContextIdentity).(*rbac.Identity)
+			}), policy, tt.role)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/records", nil)
+			if tt.username != "" {
+				req = req.WithContext(context.WithValue(req.Context(), types.ContextUsername, tt.username))
+			}
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+			if tt.wantStatus == http.StatusOK && (identity == nil || identity.Name != tt.username) {
+				t.Errorf("expected identity of %s in context, got %+v", tt.username, identity)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TokenVerifier verifies a bearer token and returns the cluster it was issued for
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (string, error)
+}
+
+// Bearer authenticates clusters by a token in the Authorization header, e.g. a Kubernetes service account token
+type Bearer struct {
+	verifier TokenVerifier
+}
+
+// NewBearer creates a bearer token authenticator
+func NewBearer(verifier TokenVerifier) *Bearer {
+	return &Bearer{verifier: verifier}
+}
+
+// Authenticate implements Authenticator
+func (b *Bearer) Authenticate(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", ErrNoCredentials
+	}
+	token := strings.TrimSpace(header[len("Bearer "):])
+	if token == "" {
+		return "", ErrNoCredentials
+	}
+
+	cluster, err := b.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return cluster, nil
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// ClientCert authenticates clusters by their verified TLS client certificate
+//
+// The cluster name is the common name of the certificate, or its first DNS
+// name if the common name is empty. With a mapping the common name and DNS
+// names are looked up in order and the first match names the cluster, so
+// certificates whose names differ from the cluster names can be used.
+type ClientCert struct {
+	mapping map[string]string
+}
+
+// NewClientCert creates a client certificate authenticator, mapping may be empty
+func NewClientCert(mapping map[string]string) *ClientCert {
+	return &ClientCert{mapping: mapping}
+}
+
+// Authenticate implements Authenticator
+func (c *ClientCert) Authenticate(r *http.Request) (string, error) {
+	// the TLS server verified the chain, unverified certificates never end up here
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", ErrNoCredentials
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+
+	names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	if len(c.mapping) > 0 {
+		for _, name := range names {
+			if cluster, ok := c.mapping[name]; ok {
+				return cluster, nil
+			}
+		}
+		return "", ErrInvalidCredentials
+	}
+
+	for _, name := range names {
+		if name != "" {
+			return name, nil
+		}
+	}
+	return "", ErrInvalidCredentials
+}
@@ -0,0 +1,65 @@
+package middleware_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/middleware"
+)
+
+func Test_ClientCert(t *testing.T) {
+	tests := []struct {
+		name        string
+		mapping     map[string]string
+		cert        *x509.Certificate
+		wantCluster string
+		wantErr     error
+	}{
+		{
+			name:        "common name",
+			cert:        &x509.Certificate{Subject: pkix.Name{CommonName: "cluster-a"}, DNSNames: []string{"a.example.com"}},
+			wantCluster: "cluster-a",
+		},
+		{
+			name:        "dns name without common name",
+			cert:        &x509.Certificate{DNSNames: []string{"a.example.com"}},
+			wantCluster: "a.example.com",
+		},
+		{
+			name:        "mapped dns name",
+			mapping:     map[string]string{"a.example.com": "cluster-a"},
+			cert:        &x509.Certificate{Subject: pkix.Name{CommonName: "ingress"}, DNSNames: []string{"a.example.com"}},
+			wantCluster: "cluster-a",
+		},
+		{
+			name:    "unmapped certificate",
+			mapping: map[string]string{"a.example.com": "cluster-a"},
+			cert:    &x509.Certificate{Subject: pkix.Name{CommonName: "b.example.com"}},
+			wantErr: middleware.ErrInvalidCredentials,
+		},
+		{
+			name:    "without certificate",
+			wantErr: middleware.ErrNoCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com/webhook", nil)
+			if tt.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.cert}}}
+			}
+
+			cluster, err := middleware.NewClientCert(tt.mapping).Authenticate(req)
+			if err != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if cluster != tt.wantCluster {
+				t.Errorf("Authenticate() = %s, want %s", cluster, tt.wantCluster)
+			}
+		})
+	}
+}
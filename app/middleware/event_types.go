@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// ErrUnknownEventType is returned for events of a type without action
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// EventTypes maps CloudEvent types to the action taken for their events
+type EventTypes map[string]models.EventAction
+
+// DefaultEventTypes are the event types known without configuration
+var DefaultEventTypes = EventTypes{
+	"cloud.adfinis.mopsos.updateRecord": models.ActionUpsert,
+	"cloud.adfinis.mopsos.deleteRecord": models.ActionDelete,
+}
+
+// ParseEventTypes reads a mapping of event types to 'upsert', 'delete' or 'ignore'
+//
+// Without types the DefaultEventTypes are used.
+func ParseEventTypes(mapping map[string]string) (EventTypes, error) {
+	if len(mapping) == 0 {
+		return DefaultEventTypes, nil
+	}
+	eventTypes := make(EventTypes, len(mapping))
+	for eventType, value := range mapping {
+		action := models.EventAction(value)
+		switch action {
+		case models.ActionUpsert, models.ActionDelete, models.ActionIgnore:
+		default:
+			return nil, fmt.Errorf("unknown action %q for event type %s, expected 'upsert', 'delete' or 'ignore'", value, eventType)
+		}
+		eventTypes[eventType] = action
+	}
+	return eventTypes, nil
+}
+
+// Action returns the action for an event type, an empty mapping uses the DefaultEventTypes
+func (t EventTypes) Action(eventType string) (models.EventAction, error) {
+	if len(t) == 0 {
+		t = DefaultEventTypes
+	}
+	action, ok := t[eventType]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownEventType, eventType)
+	}
+	return action, nil
+}
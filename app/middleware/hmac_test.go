@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adfinis-sygroup/mopsos/app/middleware"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+func signedRequest(cluster, secret string, timestamp time.Time, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", strings.NewReader(body))
+	req.Header.Set(middleware.HeaderCluster, cluster)
+	req.Header.Set(middleware.HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(middleware.HeaderSignature, middleware.Sign(secret, timestamp, []byte(body)))
+	return req
+}
+
+func Test_HMAC(t *testing.T) {
+	now := time.Now()
+	body := `{"cluster_name":"cluster"}`
+
+	tampered := signedRequest("cluster", "secret", now, body)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"cluster_name":"other"}`))
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{name: "valid signature", req: signedRequest("cluster", "secret", now, body), want: http.StatusOK},
+		{name: "wrong secret", req: signedRequest("cluster", "other", now, body), want: http.StatusUnauthorized},
+		{name: "unknown cluster", req: signedRequest("other", "secret", now, body), want: http.StatusUnauthorized},
+		{name: "tampered body", req: tampered, want: http.StatusUnauthorized},
+		{name: "expired", req: signedRequest("cluster", "secret", now.Add(-10*time.Minute), body), want: http.StatusUnauthorized},
+		{name: "from the future", req: signedRequest("cluster", "secret", now.Add(10*time.Minute), body), want: http.StatusUnauthorized},
+		{name: "without signature", req: httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil), want: http.StatusUnauthorized},
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(types.ContextUsername) != "cluster" {
+			t.Error("cluster not set")
+		}
+		// the body must still be readable after verifying the signature
+		if read, _ := io.ReadAll(r.Body); string(read) != body {
+			t.Errorf("expected body %s, got %s", body, read)
+		}
+	})
+	auth := middleware.AuthenticateWith(handler, middleware.NewHMAC(map[string]string{"cluster": "secret"}, 5*time.Minute))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			auth.ServeHTTP(res, tt.req)
+			if res.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, res.Code, res.Body.String())
+			}
+		})
+	}
+}
+
+func Test_HMACRejectsReplays(t *testing.T) {
+	auth := middleware.AuthenticateWith(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		middleware.NewHMAC(map[string]string{"cluster": "secret"}, 5*time.Minute),
+	)
+	now := time.Now()
+
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		res := httptest.NewRecorder()
+		auth.ServeHTTP(res, signedRequest("cluster", "secret", now, "{}"))
+		if res.Code != want {
+			t.Errorf("request %d: expected status %d, got %d", i, want, res.Code)
+		}
+	}
+}
+
+func Test_AuthenticateWithFallsBackToBasicAuth(t *testing.T) {
+	auth := middleware.AuthenticateWith(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		middleware.NewHMAC(map[string]string{"cluster": "secret"}, 5*time.Minute),
+		middleware.NewBasicAuth(middleware.StaticUsers(map[string]string{"cluster": "password"})),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil)
+	req.SetBasicAuth("cluster", "password")
+	res := httptest.NewRecorder()
+	auth.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", res.Code)
+	}
+
+	// an invalid signature is not rescued by valid basic auth credentials
+	req = signedRequest("cluster", "wrong", time.Now(), "{}")
+	req.SetBasicAuth("cluster", "password")
+	res = httptest.NewRecorder()
+	auth.ServeHTTP(res, req)
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", res.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
+	"github.com/adfinis-sygroup/mopsos/app/ratelimit"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+// LimitConcurrency middleware rejects requests while max requests are being handled
+//
+// Rejected requests are answered with 429 Too Many Requests right away
+// instead of piling up. A max of 0 disables the limit.
+func LimitConcurrency(next http.Handler, max int) http.Handler {
+	var slots chan struct{}
+	if max > 0 {
+		slots = make(chan struct{}, max)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				metrics.EventsRejected.WithLabelValues(metrics.ReasonConcurrency).Inc()
+				tooManyRequests(w, time.Second, "too many concurrent requests")
+				return
+			}
+		}
+
+		metrics.WebhookRequestsInFlight.Inc()
+		defer metrics.WebhookRequestsInFlight.Dec()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitBody middleware rejects requests with a body larger than max bytes
+//
+// The body is read before it reaches the authenticators, some of them read
+// it as well. A max of 0 disables the limit.
+func LimitBody(next http.Handler, max int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if max <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > max {
+			rejectBody(w, r.ContentLength, max)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > max {
+			rejectBody(w, int64(len(body)), max)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimit middleware limits how many events each authenticated cluster may send
+//
+// Every request is charged for one event, handlers of requests with more
+// events charge the others with LimitEvents once they know how many there are.
+func RateLimit(next http.Handler, limiter *ratelimit.Limiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cluster := r.Context().Value(types.ContextUsername).(string)
+		if !LimitEvents(w, limiter, cluster, 1) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitEvents charges a cluster for n events and answers with 429 if it exceeded its rate limit
+//
+// It returns whether the events are allowed, a nil limiter allows all events.
+func LimitEvents(w http.ResponseWriter, limiter *ratelimit.Limiter, cluster string, n int) bool {
+	if limiter == nil {
+		return true
+	}
+	ok, retry := limiter.AllowN(cluster, n)
+	if ok {
+		return true
+	}
+	logrus.WithFields(logrus.Fields{
+		"cluster": cluster,
+		"events":  n,
+	}).Debug("rate limiting events")
+	metrics.EventsRejected.WithLabelValues(metrics.ReasonRateLimit).Add(float64(n))
+	metrics.EventsRateLimited.WithLabelValues(cluster).Add(float64(n))
+	tooManyRequests(w, retry, "rate limit exceeded")
+	return false
+}
+
+func rejectBody(w http.ResponseWriter, size int64, max int64) {
+	logrus.WithFields(logrus.Fields{
+		"size": size,
+		"max":  max,
+	}).Debug("rejecting request body")
+	metrics.EventsRejected.WithLabelValues(metrics.ReasonBodySize).Inc()
+	http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+}
+
+// tooManyRequests answers with 429 and a Retry-After header in whole seconds
+func tooManyRequests(w http.ResponseWriter, retry time.Duration, message string) {
+	seconds := int(math.Ceil(retry.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
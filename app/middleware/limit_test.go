@@ -0,0 +1,135 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/middleware"
+	"github.com/adfinis-sygroup/mopsos/app/ratelimit"
+	"github.com/adfinis-sygroup/mopsos/app/types"
+)
+
+func Test_LimitBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		wantStatus    int
+	}{
+		{name: "small body", body: "0123456789", contentLength: 10, wantStatus: http.StatusOK},
+		{name: "declared too large", body: "0123456789a", contentLength: 11, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "too large without length", body: "0123456789a", contentLength: -1, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := middleware.LimitBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				got = string(body)
+			}), 10)
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", strings.NewReader(tt.body))
+			req.ContentLength = tt.contentLength
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.Code)
+			}
+			if tt.wantStatus == http.StatusOK && got != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, got)
+			}
+		})
+	}
+}
+
+func Test_RateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.1, Burst: 2}, nil)
+	handler := middleware.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), limiter)
+
+	send := func(cluster string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil)
+		req = req.WithContext(context.WithValue(req.Context(), types.ContextUsername, cluster))
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	for i := 0; i < 2; i++ {
+		if res := send("cluster-a"); res.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", res.Code)
+		}
+	}
+	res := send("cluster-a")
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", res.Code)
+	}
+	if retry := res.Header().Get("Retry-After"); retry != "10" {
+		t.Errorf("expected Retry-After 10, got %q", retry)
+	}
+	if res := send("cluster-b"); res.Code != http.StatusOK {
+		t.Errorf("expected other cluster to be allowed, got %d", res.Code)
+	}
+}
+
+func Test_LimitEvents(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Rate: 1, Burst: 10}, nil)
+
+	res := httptest.NewRecorder()
+	if !middleware.LimitEvents(res, limiter, "cluster-a", 8) {
+		t.Fatalf("expected 8 events to be allowed, got %d", res.Code)
+	}
+	res = httptest.NewRecorder()
+	if middleware.LimitEvents(res, limiter, "cluster-a", 5) {
+		t.Fatal("expected 5 more events to be limited")
+	}
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") != "3" {
+		t.Errorf("expected status 429 with Retry-After 3, got %d with %q", res.Code, res.Header().Get("Retry-After"))
+	}
+
+	if !middleware.LimitEvents(httptest.NewRecorder(), nil, "cluster-a", 100) {
+		t.Error("expected all events to be allowed without limiter")
+	}
+}
+
+func Test_LimitConcurrency(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := middleware.LimitConcurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}), 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil))
+	}()
+	<-started
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil))
+	if res.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", res.Code)
+	}
+	if res.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	close(release)
+	wg.Wait()
+
+	// the slot is free again once the first request is done
+	go func() { <-started }()
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil))
+	if res.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", res.Code)
+	}
+}
@@ -2,34 +2,78 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/sirupsen/logrus"
 
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
 	"github.com/adfinis-sygroup/mopsos/app/models"
 	"github.com/adfinis-sygroup/mopsos/app/types"
 )
 
+var (
+	// ErrInvalidData is returned by ValidateEvent if the event data is not a record
+	ErrInvalidData = errors.New("failed to unmarshal event data")
+	// ErrClusterMismatch is returned by ValidateEvent if a cluster sends the record of another cluster
+	ErrClusterMismatch = errors.New("event data does not match username")
+)
+
 // Validate middleware handles checking received events for validity
-func Validate(next http.Handler) http.Handler {
+//
+// The type of an event decides what happens with its record. Events of
+// ignored types are accepted right away, events of unknown types are rejected.
+func Validate(next http.Handler, eventTypes EventTypes) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		event := r.Context().Value(types.ContextEvent).(*event.Event)
-		record := &models.Record{}
 
-		if err := event.DataAs(record); err != nil {
+		record, action, err := ValidateEvent(event, r.Context().Value(types.ContextUsername).(string), eventTypes)
+		if errors.Is(err, ErrUnknownEventType) {
+			metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrInvalidData) {
 			logrus.WithError(err).Errorf("failed to unmarshal event data")
-			http.Error(w, "failed to unmarshal event data", http.StatusInternalServerError)
+			metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+			http.Error(w, ErrInvalidData.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		// reject record that have not been sent from the right auth
-		if record.ClusterName != r.Context().Value(types.ContextUsername).(string) {
-			http.Error(w, "event data does not match username", http.StatusUnauthorized)
+		if err != nil {
+			metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if action == models.ActionIgnore {
+			logrus.WithField("type", event.Type()).Debug("ignoring event")
+			w.WriteHeader(http.StatusAccepted)
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), types.ContextRecord, record)
+		ctx = context.WithValue(ctx, types.ContextAction, action)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// ValidateEvent returns the record of an event sent by a cluster and the action for its type
+//
+// Clusters may only send records of their own, so records of other clusters
+// are rejected. Events that are ignored have no record.
+func ValidateEvent(e *event.Event, username string, eventTypes EventTypes) (*models.Record, models.EventAction, error) {
+	action, err := eventTypes.Action(e.Type())
+	if err != nil || action == models.ActionIgnore {
+		return nil, action, err
+	}
+
+	record := &models.Record{}
+	if err := e.DataAs(record); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidData, err)
+	}
+	if record.ClusterName != username {
+		return nil, "", ErrClusterMismatch
+	}
+	return record, action, nil
+}
@@ -12,6 +12,7 @@ import (
 	httproto "github.com/cloudevents/sdk-go/v2/protocol/http"
 
 	"github.com/adfinis-sygroup/mopsos/app/middleware"
+	"github.com/adfinis-sygroup/mopsos/app/models"
 	"github.com/adfinis-sygroup/mopsos/app/types"
 )
 
@@ -23,11 +24,15 @@ func Test_Validate(t *testing.T) {
 		if event == nil {
 			t.Errorf("event should be stored in the req context")
 		}
+		record := r.Context().Value(types.ContextRecord).(*models.Record)
+		if record.HealthStatus != "Degraded" || record.SyncStatus != "OutOfSync" || record.ChartName != "cert-manager" {
+			t.Errorf("argo cd details should be stored in the record, got %+v", record)
+		}
 		handlerCalled = true
 
 	})
 
-	body := []byte(`{"specversion":"1.0","type":"test","datacontenttype": "application/json","data": {"cluster_name": "username"}}`)
+	body := []byte(`{"specversion":"1.0","type":"cloud.adfinis.mopsos.updateRecord","datacontenttype": "application/json","data": {"cluster_name": "username","sync_status":"OutOfSync","health_status":"Degraded","chart_name":"cert-manager"}}`)
 	req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", bytes.NewReader(body))
 	req.Header.Add("Content-Type", "application/cloudevents+json")
 
@@ -40,7 +45,7 @@ func Test_Validate(t *testing.T) {
 	ctx := context.WithValue(req.Context(), types.ContextEvent, event)
 	ctx = context.WithValue(ctx, types.ContextUsername, "username")
 
-	load := middleware.Validate(handler)
+	load := middleware.Validate(handler, nil)
 	load.ServeHTTP(res, req.WithContext(ctx))
 
 	req.Body.Close()
@@ -55,7 +60,7 @@ func Test_ValidateInvalid(t *testing.T) {
 		t.Error("handler should not have been called")
 	})
 
-	body := []byte(`{"specversion":"1.0","type":"test","datacontenttype": "application/json","data": {"cluster_name": "invalid"}}`)
+	body := []byte(`{"specversion":"1.0","type":"cloud.adfinis.mopsos.updateRecord","datacontenttype": "application/json","data": {"cluster_name": "invalid"}}`)
 	req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", bytes.NewReader(body))
 	req.Header.Add("Content-Type", "application/cloudevents+json")
 
@@ -68,7 +73,7 @@ func Test_ValidateInvalid(t *testing.T) {
 	ctx := context.WithValue(req.Context(), types.ContextEvent, event)
 	ctx = context.WithValue(ctx, types.ContextUsername, "username")
 
-	load := middleware.Validate(handler)
+	load := middleware.Validate(handler, nil)
 	load.ServeHTTP(res, req.WithContext(ctx))
 
 	req.Body.Close()
@@ -83,7 +88,7 @@ func Test_ValidateFailedMarshal(t *testing.T) {
 		t.Error("handler should not have been called")
 	})
 
-	body := []byte(`{"specversion":"1.0","type":"test","datacontenttype": "test/plain","data": "this is not json"}`)
+	body := []byte(`{"specversion":"1.0","type":"cloud.adfinis.mopsos.updateRecord","datacontenttype": "test/plain","data": "this is not json"}`)
 	req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", bytes.NewReader(body))
 	req.Header.Add("Content-Type", "application/cloudevents+json")
 
@@ -96,7 +101,7 @@ func Test_ValidateFailedMarshal(t *testing.T) {
 	ctx := context.WithValue(req.Context(), types.ContextEvent, event)
 	ctx = context.WithValue(ctx, types.ContextUsername, "username")
 
-	load := middleware.Validate(handler)
+	load := middleware.Validate(handler, nil)
 	load.ServeHTTP(res, req.WithContext(ctx))
 
 	req.Body.Close()
@@ -105,3 +110,72 @@ func Test_ValidateFailedMarshal(t *testing.T) {
 		t.Errorf("expected internal server error status code, got %d", res.Code)
 	}
 }
+
+func Test_ValidateEventTypes(t *testing.T) {
+	eventTypes, err := middleware.ParseEventTypes(map[string]string{
+		"app.synced":  "upsert",
+		"app.deleted": "delete",
+		"app.health":  "ignore",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		eventType   string
+		data        string
+		wantStatus  int
+		wantAction  models.EventAction
+		wantHandler bool
+	}{
+		{name: "upsert", eventType: "app.synced", data: `{"cluster_name":"username"}`, wantStatus: http.StatusOK, wantAction: models.ActionUpsert, wantHandler: true},
+		{name: "delete", eventType: "app.deleted", data: `{"cluster_name":"username"}`, wantStatus: http.StatusOK, wantAction: models.ActionDelete, wantHandler: true},
+		{name: "ignore without record", eventType: "app.health", data: `"healthy"`, wantStatus: http.StatusAccepted},
+		{name: "unknown type", eventType: "cloud.adfinis.mopsos.updateRecord", data: `{"cluster_name":"username"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var action models.EventAction
+			called := false
+			handler := middleware.Validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				action = r.Context().Value(types.ContextAction).(models.EventAction)
+			}), eventTypes)
+
+			evt := cloudevents.NewEvent()
+			evt.SetType(tt.eventType)
+			if err := evt.SetData("application/json", []byte(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil)
+			ctx := context.WithValue(req.Context(), types.ContextEvent, &evt)
+			ctx = context.WithValue(ctx, types.ContextUsername, "username")
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req.WithContext(ctx))
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+			if called != tt.wantHandler {
+				t.Fatalf("expected handler called = %v", tt.wantHandler)
+			}
+			if action != tt.wantAction {
+				t.Errorf("expected action %q, got %q", tt.wantAction, action)
+			}
+		})
+	}
+}
+
+func Test_ParseEventTypes(t *testing.T) {
+	eventTypes, err := middleware.ParseEventTypes(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action, err := eventTypes.Action("cloud.adfinis.mopsos.deleteRecord"); err != nil || action != models.ActionDelete {
+		t.Errorf("expected default delete type, got %q, %v", action, err)
+	}
+	if _, err := middleware.ParseEventTypes(map[string]string{"app.synced": "create"}); err == nil {
+		t.Error("expected error for unknown action, got nil")
+	}
+}
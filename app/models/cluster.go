@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+/**
+ * Cluster is the model for the clusters table
+ *
+ * Clusters registered here may send events to the webhook using one of
+ * their active tokens, they can be managed at runtime using the admin API.
+ */
+type Cluster struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name        string            `json:"name" gorm:"uniqueIndex;not null"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels" gorm:"serializer:json"`
+	Enabled     bool              `json:"enabled" gorm:"not null"`
+
+	Tokens []ClusterToken `json:"tokens,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+/**
+ * ClusterToken is the model for the cluster_tokens table
+ *
+ * Only the hash of a token is stored. A cluster can have several active
+ * tokens while they are being rotated.
+ */
+type ClusterToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ClusterID uint       `json:"-" gorm:"index;not null"`
+	Hash      string     `json:"-" gorm:"not null"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// Active reports whether the token may be used at the given time
+func (t *ClusterToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
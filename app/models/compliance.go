@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Sources of compliance rules
+const (
+	// RuleSourceFile is a rule loaded from the rules file, it can't be changed using the API
+	RuleSourceFile = "file"
+	// RuleSourceAPI is a rule managed using the admin API
+	RuleSourceAPI = "api"
+)
+
+/**
+ * ComplianceRule is the model for the compliance_rules table
+ *
+ * A rule selects records by cluster and application and asserts a version
+ * constraint on them. Lists of names may contain glob patterns like prod-*.
+ */
+type ComplianceRule struct {
+	ID        uint      `gorm:"primarykey" json:"-" yaml:"-"`
+	CreatedAt time.Time `json:"created_at" yaml:"-"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"-"`
+
+	Name        string `json:"name" yaml:"name" gorm:"uniqueIndex;not null"`
+	Description string `json:"description,omitempty" yaml:"description"`
+	Source      string `json:"source" yaml:"-" gorm:"not null;default:api"`
+
+	// selectors, empty selectors match everything
+	Clusters      []string          `json:"clusters,omitempty" yaml:"clusters" gorm:"serializer:json"`
+	ClusterLabels map[string]string `json:"cluster_labels,omitempty" yaml:"cluster_labels" gorm:"serializer:json"`
+	Applications  []string          `json:"applications,omitempty" yaml:"applications" gorm:"serializer:json"`
+
+	// Version is a constraint like ">= 1.9" the version of the selected records must satisfy
+	Version string `json:"version,omitempty" yaml:"version"`
+	// AllowPrerelease forbids pre-release versions if set to false
+	AllowPrerelease *bool `json:"allow_prerelease,omitempty" yaml:"allow_prerelease"`
+}
+
+/**
+ * ComplianceResult is the model for the compliance_results table
+ *
+ * Each row is the outcome of a rule for a record it selects. The results of
+ * a record are replaced whenever the record is written.
+ */
+type ComplianceResult struct {
+	ID          uint      `gorm:"primarykey" json:"-"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+
+	RuleName            string `json:"rule" gorm:"not null;index"`
+	ClusterName         string `json:"cluster_name" gorm:"index:idx_compliance_key"`
+	InstanceId          string `json:"instance_id" gorm:"index:idx_compliance_key"`
+	ApplicationName     string `json:"application_name" gorm:"index:idx_compliance_key"`
+	ApplicationInstance string `json:"application_instance" gorm:"index:idx_compliance_key"`
+	ApplicationVersion  string `json:"application_version"`
+	Compliant           bool   `json:"compliant" gorm:"not null;index"`
+	// Reason explains a violation
+	Reason string `json:"reason,omitempty"`
+}
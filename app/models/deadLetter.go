@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+/**
+ * DeadLetter is the model for the dead_letters table
+ *
+ * Events that could not be stored after all retries are kept here so they
+ * can be inspected and replayed once the cause has been fixed.
+ */
+type DeadLetter struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	EventID     string     `json:"event_id"`
+	EventType   string     `json:"event_type"`
+	ClusterName string     `json:"cluster_name" gorm:"index"`
+	Payload     string     `json:"payload" gorm:"not null"`
+	Error       string     `json:"error"`
+	Attempts    int        `json:"attempts"`
+	ReplayedAt  *time.Time `json:"replayed_at"`
+}
+
+// NewDeadLetter wraps an event that failed to be stored
+func NewDeadLetter(data EventData, cause error, attempts int) (*DeadLetter, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetter{
+		EventID:     data.Event.ID(),
+		EventType:   data.Event.Type(),
+		ClusterName: data.Record.ClusterName,
+		Payload:     string(payload),
+		Error:       cause.Error(),
+		Attempts:    attempts,
+	}, nil
+}
+
+// EventData decodes the event stored in the dead letter
+func (d *DeadLetter) EventData() (EventData, error) {
+	data := EventData{}
+	err := json.Unmarshal([]byte(d.Payload), &data)
+	return data, err
+}
@@ -2,8 +2,26 @@ package models
 
 import cloudevents "github.com/cloudevents/sdk-go/v2"
 
+// EventAction is what the handler does with the record of an event
+type EventAction string
+
+const (
+	// ActionUpsert creates or updates the record
+	ActionUpsert EventAction = "upsert"
+	// ActionDelete soft-deletes the record
+	ActionDelete EventAction = "delete"
+	// ActionIgnore accepts the event without storing it
+	ActionIgnore EventAction = "ignore"
+	// ActionSnapshot replaces the records of a cluster with the records of the snapshot
+	ActionSnapshot EventAction = "snapshot"
+)
+
 // EventData is the data structure for passing events between the server and the handler
 type EventData struct {
-	Event  cloudevents.Event
-	Record Record
+	Event  cloudevents.Event `json:"event"`
+	Record Record            `json:"record"`
+	// Action is determined by the type of the event, empty means ActionUpsert
+	Action EventAction `json:"action,omitempty"`
+	// Snapshot is set for ActionSnapshot, Record then only holds its cluster and instance
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/adfinis-sygroup/mopsos/app/images"
+)
+
+/**
+ * Image is the model for the images table
+ *
+ * Each row is a container image run by the application of a record, as
+ * listed in the status.summary.images of the Argo CD application.
+ */
+type Image struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"-"`
+	RecordID  uint      `gorm:"not null;index" json:"-"`
+
+	Registry   string `json:"registry" gorm:"index:idx_image_repository"`
+	Repository string `json:"repository" gorm:"index:idx_image_repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty" gorm:"index"`
+}
+
+// UnmarshalJSON accepts images as objects and as references like quay.io/jetstack/cert-manager-controller:v1.9.1
+func (i *Image) UnmarshalJSON(data []byte) error {
+	var reference string
+	if err := json.Unmarshal(data, &reference); err != nil {
+		// use a type without the method to decode objects
+		type image Image
+		return json.Unmarshal(data, (*image)(i))
+	}
+
+	ref, err := images.Parse(reference)
+	if err != nil {
+		return err
+	}
+	*i = Image{
+		Registry:   ref.Registry,
+		Repository: ref.Repository,
+		Tag:        ref.Tag,
+		Digest:     ref.Digest,
+	}
+	return nil
+}
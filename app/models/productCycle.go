@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+/**
+ * ProductCycle is the model for the product_cycles table
+ *
+ * It stores the release cycles of products imported from an endoflife.date
+ * style lifecycle dataset so records can be checked for support.
+ */
+type ProductCycle struct {
+	ID         uint      `gorm:"primarykey" json:"-"`
+	ImportedAt time.Time `json:"imported_at"`
+
+	Product     string     `json:"product" gorm:"not null;index"`
+	Cycle       string     `json:"cycle" gorm:"not null"`
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+	// EOLDate is the end of life of the cycle, if known
+	EOLDate *time.Time `json:"eol_date,omitempty"`
+	// EOLReached is set for cycles that are known to have ended without a date
+	EOLReached bool   `json:"eol_reached"`
+	Latest     string `json:"latest"`
+}
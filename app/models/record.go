@@ -25,4 +25,52 @@ type Record struct {
 	ApplicationName     string `json:"application_name" gorm:"uniqueIndex:idx_unique"`
 	ApplicationInstance string `json:"application_instance" gorm:"uniqueIndex:idx_unique"`
 	ApplicationVersion  string `json:"application_version" gorm:"not null"`
+
+	// optional Argo CD details of the application
+	SyncStatus           string `json:"sync_status,omitempty" gorm:"index"`
+	HealthStatus         string `json:"health_status,omitempty" gorm:"index"`
+	Revision             string `json:"revision,omitempty"`
+	TargetRevision       string `json:"target_revision,omitempty"`
+	RepoURL              string `json:"repo_url,omitempty"`
+	ChartName            string `json:"chart_name,omitempty"`
+	DestinationNamespace string `json:"destination_namespace,omitempty"`
+
+	// Images run by the application, only loaded where needed
+	Images []Image `json:"images,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// RecordKeyColumns are the columns making up the unique key of a record
+var RecordKeyColumns = []string{
+	"cluster_name",
+	"instance_id",
+	"application_name",
+	"application_instance",
+}
+
+// RecordKey identifies a record, it matches the idx_unique index
+type RecordKey struct {
+	ClusterName         string
+	InstanceId          string
+	ApplicationName     string
+	ApplicationInstance string
+}
+
+// Key returns the unique key of the record
+func (r *Record) Key() RecordKey {
+	return RecordKey{
+		ClusterName:         r.ClusterName,
+		InstanceId:          r.InstanceId,
+		ApplicationName:     r.ApplicationName,
+		ApplicationInstance: r.ApplicationInstance,
+	}
+}
+
+// Conditions returns the key as gorm conditions, unlike struct conditions these also match empty values
+func (k RecordKey) Conditions() map[string]interface{} {
+	return map[string]interface{}{
+		"cluster_name":         k.ClusterName,
+		"instance_id":          k.InstanceId,
+		"application_name":     k.ApplicationName,
+		"application_instance": k.ApplicationInstance,
+	}
 }
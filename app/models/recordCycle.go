@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+/**
+ * RecordCycle is the model for the record_cycles table
+ *
+ * Each row is the release cycle the version of a record belongs to, records
+ * without a matching cycle have no row. The cycle of a record is replaced
+ * whenever the record is written and the cycles of all records whenever the
+ * lifecycle dataset is imported. The support status is derived from the end
+ * of life when reading, as it changes with the day.
+ */
+type RecordCycle struct {
+	ID          uint      `gorm:"primarykey" json:"-"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+
+	ClusterName         string `json:"cluster_name" gorm:"index:idx_record_cycle_key"`
+	InstanceId          string `json:"instance_id" gorm:"index:idx_record_cycle_key"`
+	ApplicationName     string `json:"application_name" gorm:"index:idx_record_cycle_key"`
+	ApplicationInstance string `json:"application_instance" gorm:"index:idx_record_cycle_key"`
+	ApplicationVersion  string `json:"application_version"`
+
+	Product    string     `json:"product" gorm:"not null"`
+	Cycle      string     `json:"cycle" gorm:"not null"`
+	EOLDate    *time.Time `json:"eol_date,omitempty"`
+	EOLReached bool       `json:"eol_reached"`
+}
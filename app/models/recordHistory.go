@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Actions of a history entry
+const (
+	// HistoryUpdate is a record that was added or changed its version
+	HistoryUpdate = "update"
+	// HistoryRemove is a record that was removed, its application version is empty
+	HistoryRemove = "remove"
+)
+
+/**
+ * RecordHistory is the model for the record_history table
+ *
+ * The table is append-only, each row is a version transition or removal of
+ * a record as observed by mopsos together with the CloudEvent that announced it.
+ */
+type RecordHistory struct {
+	ID         uint      `gorm:"primarykey" json:"-"`
+	ObservedAt time.Time `json:"observed_at" gorm:"autoCreateTime;index"`
+
+	ClusterName         string    `json:"cluster_name" gorm:"index:idx_history_key"`
+	InstanceId          string    `json:"instance_id" gorm:"index:idx_history_key"`
+	ApplicationName     string    `json:"application_name" gorm:"index:idx_history_key"`
+	ApplicationInstance string    `json:"application_instance" gorm:"index:idx_history_key"`
+	Action              string    `json:"action" gorm:"not null;default:update;index"`
+	PreviousVersion     string    `json:"previous_version"`
+	ApplicationVersion  string    `json:"application_version" gorm:"not null"`
+	EventID             string    `json:"event_id"`
+	EventTime           time.Time `json:"event_time"`
+}
+
+// TableName overrides the pluralized default table name
+func (RecordHistory) TableName() string {
+	return "record_history"
+}
@@ -0,0 +1,19 @@
+package models
+
+// Snapshot is the complete list of applications of a cluster
+//
+// Records of the cluster that are missing from a snapshot have been removed.
+type Snapshot struct {
+	ClusterName string `json:"cluster_name"`
+	// InstanceId limits the snapshot to the records of one Argo CD instance
+	InstanceId string   `json:"instance_id"`
+	Records    []Record `json:"records"`
+}
+
+// SnapshotResult summarizes the changes made by a snapshot
+type SnapshotResult struct {
+	// Updated counts the records that were added or changed their version
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+}
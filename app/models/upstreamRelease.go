@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+/**
+ * UpstreamRelease is the model for the upstream_releases table
+ *
+ * It stores the latest chart version available in the configured upstream
+ * Helm repositories so records can be compared against it.
+ */
+type UpstreamRelease struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+
+	ChartName     string    `json:"chart_name" gorm:"uniqueIndex"`
+	LatestVersion string    `json:"latest_version" gorm:"not null"`
+	AppVersion    string    `json:"app_version"`
+	Source        string    `json:"source"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
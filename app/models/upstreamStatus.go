@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+/**
+ * UpstreamStatus is the model for the upstream_statuses table
+ *
+ * Each row compares a record with the latest upstream release of its chart.
+ * The status of a record is replaced whenever the record is written and the
+ * statuses of all records whenever the upstream releases are refreshed.
+ */
+type UpstreamStatus struct {
+	ID          uint      `gorm:"primarykey" json:"-"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+
+	ClusterName         string `json:"cluster_name" gorm:"index:idx_upstream_status_key"`
+	InstanceId          string `json:"instance_id" gorm:"index:idx_upstream_status_key"`
+	ApplicationName     string `json:"application_name" gorm:"index:idx_upstream_status_key"`
+	ApplicationInstance string `json:"application_instance" gorm:"index:idx_upstream_status_key"`
+	ApplicationVersion  string `json:"application_version"`
+
+	ChartName     string `json:"chart_name"`
+	LatestVersion string `json:"latest_version"`
+	// Status is current, outdated or unknown if the chart is not tracked
+	Status string `json:"status" gorm:"not null;index"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+/**
+ * Vulnerability is the model for the vulnerabilities table
+ *
+ * It stores the advisories imported from an OSV database dump, the affected
+ * packages are stored in the vulnerable_packages table.
+ */
+type Vulnerability struct {
+	ID         string    `gorm:"primarykey" json:"id"`
+	ImportedAt time.Time `json:"imported_at"`
+
+	Summary   string    `json:"summary"`
+	Aliases   string    `json:"aliases"`
+	Severity  string    `json:"severity"`
+	Published time.Time `json:"published"`
+	Modified  time.Time `json:"modified"`
+
+	Packages []VulnerablePackage `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+/**
+ * VulnerablePackage is the model for the vulnerable_packages table
+ *
+ * Each row is a package affected by a vulnerability, the affected ranges
+ * and versions are kept in the OSV format.
+ */
+type VulnerablePackage struct {
+	ID              uint   `gorm:"primarykey" json:"-"`
+	VulnerabilityID string `gorm:"not null;index" json:"vulnerability_id"`
+
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name" gorm:"index"`
+	// Affected is the OSV affected entry as JSON
+	Affected string `json:"affected" gorm:"not null"`
+}
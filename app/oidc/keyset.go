@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+)
+
+const (
+	// refreshInterval is how long keys are cached
+	refreshInterval = time.Hour
+	// minRefreshInterval limits reloads triggered by tokens with unknown key ids
+	minRefreshInterval = time.Minute
+	// maxKeySetSize limits the size of a fetched key set
+	maxKeySetSize = 1 << 20
+)
+
+// httpClient is used to fetch remote key sets
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// KeySet holds the public keys of an issuer loaded from a JWKS document
+//
+// The keys are cached for an hour and reloaded early when a token refers to
+// an unknown key, so rotated keys are picked up quickly.
+type KeySet struct {
+	source string
+
+	mu       sync.Mutex
+	keys     *jose.JSONWebKeySet
+	loadedAt time.Time
+}
+
+// NewKeySet creates a key set loaded from a http(s) URL or a file on first use
+func NewKeySet(source string) *KeySet {
+	return &KeySet{source: source}
+}
+
+// Keys returns the signing keys with the given key id, or all signing keys if the id is empty
+func (k *KeySet) Keys(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	age := time.Since(k.loadedAt)
+	if k.keys == nil || age > refreshInterval {
+		if err := k.load(ctx); err != nil {
+			return nil, err
+		}
+		age = 0
+	}
+
+	keys := k.find(kid)
+	if len(keys) == 0 && age > minRefreshInterval {
+		if err := k.load(ctx); err != nil {
+			return nil, err
+		}
+		keys = k.find(kid)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key with id %q in %s", kid, k.source)
+	}
+	return keys, nil
+}
+
+func (k *KeySet) find(kid string) []jose.JSONWebKey {
+	keys := []jose.JSONWebKey{}
+	for _, key := range k.keys.Keys {
+		if key.Use == "enc" || !key.IsPublic() {
+			continue
+		}
+		if kid == "" || key.KeyID == kid {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// load reads the key set, the caller must hold the lock
+func (k *KeySet) load(ctx context.Context) error {
+	reader, err := open(ctx, k.source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	keys := &jose.JSONWebKeySet{}
+	if err := json.NewDecoder(io.LimitReader(reader, maxKeySetSize)).Decode(keys); err != nil {
+		return fmt.Errorf("decoding key set %s: %w", k.source, err)
+	}
+	k.keys = keys
+	k.loadedAt = time.Now()
+	return nil
+}
+
+// open returns the JWKS document behind a http(s) URL or a path on the local filesystem
+func open(ctx context.Context, source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", source, res.Status)
+	}
+	return res.Body, nil
+}
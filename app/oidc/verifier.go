@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// leeway is the clock skew tolerated when checking the validity of a token
+const leeway = time.Minute
+
+// Verifier verifies JWTs like Kubernetes service account tokens and maps them to clusters
+type Verifier struct {
+	issuers      map[string]*KeySet
+	audiences    []string
+	clusterClaim string
+	mapping      map[string]string
+}
+
+// NewVerifier creates a verifier for tokens of the given issuers
+//
+// issuers maps the issuer URLs to their JWKS, given as a http(s) URL or file.
+// A token must be meant for one of the audiences. The cluster is read from the
+// clusterClaim and mapped using mapping, without mapping the claim itself is
+// the cluster name.
+func NewVerifier(issuers map[string]string, audiences []string, clusterClaim string, mapping map[string]string) *Verifier {
+	keySets := make(map[string]*KeySet, len(issuers))
+	for issuer, source := range issuers {
+		keySets[issuer] = NewKeySet(source)
+	}
+	return &Verifier{
+		issuers:      keySets,
+		audiences:    audiences,
+		clusterClaim: clusterClaim,
+		mapping:      mapping,
+	}
+}
+
+// Verify checks the signature and claims of a token and returns the cluster it belongs to
+func (v *Verifier) Verify(ctx context.Context, raw string) (string, error) {
+	token, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return "", err
+	}
+	if len(token.Headers) != 1 {
+		return "", errors.New("token must have exactly one signature")
+	}
+
+	// the issuer decides which keys the signature is checked with
+	unverified := jwt.Claims{}
+	if err := token.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return "", err
+	}
+	keySet, ok := v.issuers[unverified.Issuer]
+	if !ok {
+		return "", fmt.Errorf("untrusted issuer %q", unverified.Issuer)
+	}
+	keys, err := keySet.Keys(ctx, token.Headers[0].KeyID)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.Claims{}
+	custom := map[string]interface{}{}
+	verified := false
+	for _, key := range keys {
+		if token.Claims(key.Key, &claims, &custom) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return "", errors.New("invalid token signature")
+	}
+
+	if claims.Expiry == nil {
+		return "", errors.New("token does not expire")
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{Issuer: unverified.Issuer, Time: time.Now()}, leeway); err != nil {
+		return "", err
+	}
+	if !v.hasAudience(claims.Audience) {
+		return "", errors.New("token is not meant for mopsos")
+	}
+
+	return v.cluster(custom)
+}
+
+func (v *Verifier) hasAudience(audience jwt.Audience) bool {
+	for _, expected := range v.audiences {
+		if audience.Contains(expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// cluster reads the cluster from the claims
+func (v *Verifier) cluster(claims map[string]interface{}) (string, error) {
+	value, ok := claims[v.clusterClaim].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("token has no %s claim", v.clusterClaim)
+	}
+	if len(v.mapping) == 0 {
+		return value, nil
+	}
+	cluster, ok := v.mapping[value]
+	if !ok {
+		return "", fmt.Errorf("no cluster for %s %q", v.clusterClaim, value)
+	}
+	return cluster, nil
+}
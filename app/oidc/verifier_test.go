@@ -0,0 +1,213 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/adfinis-sygroup/mopsos/app/oidc"
+)
+
+const issuer = "https://cluster-a.example.com"
+
+// serviceAccountClaims are the claims of a projected Kubernetes service account token
+type serviceAccountClaims struct {
+	jwt.Claims
+	Kubernetes map[string]interface{} `json:"kubernetes.io,omitempty"`
+}
+
+func newKey(t *testing.T, kid string) jose.JSONWebKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jose.JSONWebKey{Key: key, KeyID: kid, Algorithm: string(jose.ES256), Use: "sig"}
+}
+
+func writeKeySet(t *testing.T, keys ...jose.JSONWebKey) []byte {
+	set := jose.JSONWebKeySet{}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, key.Public())
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func sign(t *testing.T, key jose.JSONWebKey, claims interface{}) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", key.KeyID),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func claims(iss string, aud string, expiry time.Time) serviceAccountClaims {
+	return serviceAccountClaims{
+		Claims: jwt.Claims{
+			Issuer:   iss,
+			Subject:  "system:serviceaccount:argocd:mopsos",
+			Audience: jwt.Audience{aud},
+			IssuedAt: jwt.NewNumericDate(expiry.Add(-time.Hour)),
+			Expiry:   jwt.NewNumericDate(expiry),
+		},
+		Kubernetes: map[string]interface{}{"namespace": "argocd"},
+	}
+}
+
+func Test_Verifier(t *testing.T) {
+	key := newKey(t, "key-1")
+	other := newKey(t, "key-1")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, writeKeySet(t, key), 0600); err != nil {
+		t.Fatal(err)
+	}
+	valid := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		token       string
+		claim       string
+		mapping     map[string]string
+		wantCluster string
+		wantErr     bool
+	}{
+		{
+			name:        "issuer as cluster",
+			token:       sign(t, key, claims(issuer, "mopsos", valid)),
+			claim:       "iss",
+			wantCluster: issuer,
+		},
+		{
+			name:        "mapped issuer",
+			token:       sign(t, key, claims(issuer, "mopsos", valid)),
+			claim:       "iss",
+			mapping:     map[string]string{issuer: "cluster-a"},
+			wantCluster: "cluster-a",
+		},
+		{
+			name:        "subject as cluster",
+			token:       sign(t, key, claims(issuer, "mopsos", valid)),
+			claim:       "sub",
+			wantCluster: "system:serviceaccount:argocd:mopsos",
+		},
+		{
+			name:    "unmapped issuer",
+			token:   sign(t, key, claims(issuer, "mopsos", valid)),
+			claim:   "iss",
+			mapping: map[string]string{"https://cluster-b.example.com": "cluster-b"},
+			wantErr: true,
+		},
+		{
+			name:    "claim is not a string",
+			token:   sign(t, key, claims(issuer, "mopsos", valid)),
+			claim:   "kubernetes.io",
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   sign(t, key, claims(issuer, "mopsos", time.Now().Add(-time.Hour))),
+			claim:   "iss",
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			token:   sign(t, key, claims(issuer, "kubernetes", valid)),
+			claim:   "iss",
+			wantErr: true,
+		},
+		{
+			name:    "untrusted issuer",
+			token:   sign(t, key, claims("https://cluster-b.example.com", "mopsos", valid)),
+			claim:   "iss",
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			token:   sign(t, other, claims(issuer, "mopsos", valid)),
+			claim:   "iss",
+			wantErr: true,
+		},
+		{
+			name:    "not a token",
+			token:   "token",
+			claim:   "iss",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := oidc.NewVerifier(map[string]string{issuer: path}, []string{"mopsos"}, tt.claim, tt.mapping)
+
+			cluster, err := verifier.Verify(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cluster != tt.wantCluster {
+				t.Errorf("Verify() = %q, want %q", cluster, tt.wantCluster)
+			}
+		})
+	}
+}
+
+func Test_VerifierFetchesKeySet(t *testing.T) {
+	key := newKey(t, "key-1")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(writeKeySet(t, key))
+	}))
+	defer server.Close()
+
+	verifier := oidc.NewVerifier(map[string]string{issuer: server.URL}, []string{"mopsos"}, "iss", map[string]string{issuer: "cluster-a"})
+	token := sign(t, key, claims(issuer, "mopsos", time.Now().Add(time.Hour)))
+	for i := 0; i < 3; i++ {
+		cluster, err := verifier.Verify(context.Background(), token)
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if cluster != "cluster-a" {
+			t.Errorf("Verify() = %q, want %q", cluster, "cluster-a")
+		}
+	}
+
+	// tokens with an unknown key must not cause a request each
+	unknown := sign(t, newKey(t, "key-2"), claims(issuer, "mopsos", time.Now().Add(time.Hour)))
+	for i := 0; i < 3; i++ {
+		if _, err := verifier.Verify(context.Background(), unknown); err == nil {
+			t.Error("expected error, got nil")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the key set to be fetched once, got %d requests", requests)
+	}
+}
+
+func Test_VerifierFailsWithoutKeySet(t *testing.T) {
+	key := newKey(t, "key-1")
+	verifier := oidc.NewVerifier(map[string]string{issuer: filepath.Join(t.TempDir(), "missing.json")}, []string{"mopsos"}, "iss", nil)
+	if _, err := verifier.Verify(context.Background(), sign(t, key, claims(issuer, "mopsos", time.Now().Add(time.Hour)))); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,172 @@
+package osv
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+// Range types of affected ranges, GIT ranges can't be matched against versions and are ignored
+const (
+	RangeSemVer    = "SEMVER"
+	RangeEcosystem = "ECOSYSTEM"
+)
+
+// semverEcosystems are the ecosystems whose versions follow semantic versioning
+//
+// ECOSYSTEM ranges of other ecosystems, i.e. PyPI or Debian, use their own
+// version ordering which mopsos can't compare.
+var semverEcosystems = map[string]bool{
+	"crates.io": true,
+	"Go":        true,
+	"Hex":       true,
+	"npm":       true,
+	"Pub":       true,
+}
+
+// Advisory is a vulnerability in the OSV format, see https://ossf.github.io/osv-schema/
+//
+// Only the fields used by mopsos are decoded.
+type Advisory struct {
+	ID               string     `json:"id"`
+	Summary          string     `json:"summary"`
+	Aliases          []string   `json:"aliases"`
+	Modified         time.Time  `json:"modified"`
+	Published        time.Time  `json:"published"`
+	Withdrawn        *time.Time `json:"withdrawn"`
+	Affected         []Affected `json:"affected"`
+	Severity         []Severity `json:"severity"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// Severity is a severity score of an advisory like a CVSS vector
+type Severity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected lists the affected versions of a package
+type Affected struct {
+	Package  Package  `json:"package"`
+	Ranges   []Range  `json:"ranges,omitempty"`
+	Versions []string `json:"versions,omitempty"`
+}
+
+// Package identifies a package within an ecosystem
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+// Range is a list of events that introduce and fix a vulnerability
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event is a change in a range, exactly one of the fields is set
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// SeverityLabel returns the severity of an advisory for display
+//
+// The rating of the database, i.e. HIGH for GitHub advisories, is preferred
+// over scores like CVSS vectors.
+func (a *Advisory) SeverityLabel() string {
+	if a.DatabaseSpecific.Severity != "" {
+		return strings.ToUpper(a.DatabaseSpecific.Severity)
+	}
+	if len(a.Severity) > 0 {
+		return a.Severity[0].Score
+	}
+	return ""
+}
+
+// Affects returns whether a version is affected and the version that fixes it, if known
+//
+// Versions listed explicitly are affected. Otherwise the SEMVER ranges and
+// the ECOSYSTEM ranges of ecosystems using semantic versioning are evaluated,
+// comparing versions like the rest of mopsos.
+func (a *Affected) Affects(installed string) (bool, string) {
+	for _, v := range a.Versions {
+		if v == installed || v == strings.TrimPrefix(installed, "v") {
+			return true, ""
+		}
+	}
+	v, err := version.Parse(installed)
+	if err != nil {
+		return false, ""
+	}
+	for _, r := range a.Ranges {
+		if r.Type != RangeSemVer && (r.Type != RangeEcosystem || !semverEcosystems[a.Package.Ecosystem]) {
+			continue
+		}
+		if affected, fixed := r.affects(v); affected {
+			return true, fixed
+		}
+	}
+	return false, ""
+}
+
+// rangeEvent is an event with its parsed version, a nil version sorts before all others
+type rangeEvent struct {
+	Event
+	version *version.Version
+}
+
+// affects walks the events of a range in version order
+func (r *Range) affects(v *version.Version) (bool, string) {
+	events := make([]rangeEvent, 0, len(r.Events))
+	for _, event := range r.Events {
+		value := event.Introduced + event.Fixed + event.LastAffected
+		if value == "" {
+			// limits only matter for git ranges
+			continue
+		}
+		parsed, err := version.Parse(value)
+		if err != nil && value != "0" {
+			continue
+		}
+		events = append(events, rangeEvent{Event: event, version: parsed})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return compare(events[i].version, events[j].version) < 0
+	})
+
+	affected, fixed := false, ""
+	for _, event := range events {
+		cmp := compare(v, event.version)
+		switch {
+		case event.Introduced != "" && cmp >= 0:
+			affected, fixed = true, ""
+		case event.Fixed != "" && cmp >= 0:
+			affected = false
+		case event.Fixed != "" && affected && fixed == "":
+			fixed = event.Fixed
+		case event.LastAffected != "" && cmp > 0:
+			affected = false
+		}
+	}
+	return affected, fixed
+}
+
+// compare compares versions where nil is the lowest version
+func compare(a, b *version.Version) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return version.Compare(a, b)
+}
@@ -0,0 +1,52 @@
+package osv_test
+
+import (
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+func Test_AffectedAffects(t *testing.T) {
+	affected := osv.Affected{
+		Package: osv.Package{Ecosystem: "Go", Name: "github.com/cert-manager/cert-manager"},
+		Ranges: []osv.Range{
+			{Type: osv.RangeSemVer, Events: []osv.Event{{Introduced: "0"}, {Fixed: "1.8.2"}, {Introduced: "1.9.0"}, {Fixed: "1.9.2"}}},
+			{Type: osv.RangeEcosystem, Events: []osv.Event{{Introduced: "2.0.0"}, {LastAffected: "2.1.0"}}},
+			{Type: "GIT", Events: []osv.Event{{Introduced: "0"}}},
+		},
+		Versions: []string{"3.0.0-custom"},
+	}
+
+	tests := []struct {
+		version   string
+		wantFixed string
+		want      bool
+	}{
+		{version: "1.0.0", want: true, wantFixed: "1.8.2"},
+		{version: "v1.8.1", want: true, wantFixed: "1.8.2"},
+		{version: "1.8.2", want: false},
+		{version: "1.9.1", want: true, wantFixed: "1.9.2"},
+		{version: "1.9.2", want: false},
+		{version: "2.1.0", want: true},
+		{version: "2.1.1", want: false},
+		{version: "3.0.0-custom", want: true},
+		{version: "latest", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, fixed := affected.Affects(tt.version)
+			if got != tt.want || fixed != tt.wantFixed {
+				t.Errorf("Affects(%q) = %v, %q, want %v, %q", tt.version, got, fixed, tt.want, tt.wantFixed)
+			}
+		})
+	}
+
+	// ecosystem ranges of ecosystems without semantic versioning are not compared
+	affected.Package = osv.Package{Ecosystem: "PyPI", Name: "cert-manager"}
+	if got, _ := affected.Affects("2.1.0"); got {
+		t.Error("expected the ECOSYSTEM range of PyPI to be ignored")
+	}
+	if got, _ := affected.Affects("1.9.1"); !got {
+		t.Error("expected the SEMVER range to be evaluated for any ecosystem")
+	}
+}
@@ -0,0 +1,129 @@
+package osv
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Load reads the advisories of an OSV database dump
+//
+// The dump is a directory of JSON files, a single JSON file or a zip or
+// gzipped tar archive like the all.zip files offered per ecosystem by OSV.
+// Files that are not JSON are skipped, withdrawn advisories are left out.
+func Load(path string) ([]Advisory, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	advisories := []Advisory{}
+	add := func(name string, r io.Reader) error {
+		advisory := Advisory{}
+		if err := json.NewDecoder(r).Decode(&advisory); err != nil {
+			return fmt.Errorf("decoding %s: %w", name, err)
+		}
+		if advisory.Withdrawn == nil {
+			advisories = append(advisories, advisory)
+		}
+		return nil
+	}
+
+	switch {
+	case info.IsDir():
+		err = loadDir(path, add)
+	case strings.HasSuffix(path, ".zip"):
+		err = loadZip(path, add)
+	case strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz"):
+		err = loadTar(path, add)
+	default:
+		err = loadFile(path, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return advisories, nil
+}
+
+func isJSON(name string) bool {
+	return strings.HasSuffix(name, ".json")
+}
+
+func loadFile(path string, add func(string, io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return add(path, f)
+}
+
+func loadDir(dir string, add func(string, io.Reader) error) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !isJSON(path) {
+			return err
+		}
+		return loadFile(path, add)
+	})
+}
+
+func loadZip(path string, add func(string, io.Reader) error) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !isJSON(file.Name) {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = add(file.Name, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadTar(path string, add func(string, io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || !isJSON(header.Name) {
+			continue
+		}
+		if err := add(header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package osv_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+// testdataFiles are the advisories in testdata relative to testdata/advisories
+var testdataFiles = []string{"go/TEST-2022-0001.json", "go/TEST-2022-0002.json", "TEST-2022-0003.json", "README.md"}
+
+func Test_Load(t *testing.T) {
+	dir := t.TempDir()
+
+	zipPath := filepath.Join(dir, "all.zip")
+	writeArchive(t, zipPath)
+	tarPath := filepath.Join(dir, "all.tar.gz")
+	writeArchive(t, tarPath)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "directory", path: "testdata/advisories", want: []string{"TEST-2022-0001", "TEST-2022-0002"}},
+		{name: "file", path: "testdata/advisories/go/TEST-2022-0002.json", want: []string{"TEST-2022-0002"}},
+		{name: "zip archive", path: zipPath, want: []string{"TEST-2022-0001", "TEST-2022-0002"}},
+		{name: "tar archive", path: tarPath, want: []string{"TEST-2022-0001", "TEST-2022-0002"}},
+		{name: "not json", path: "testdata/advisories/README.md", wantErr: true},
+		{name: "missing", path: "testdata/missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advisories, err := osv.Load(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			ids := []string{}
+			for _, advisory := range advisories {
+				ids = append(ids, advisory.ID)
+			}
+			sort.Strings(ids)
+			if len(ids) != len(tt.want) {
+				t.Fatalf("expected advisories %v, got %v", tt.want, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Errorf("expected advisories %v, got %v", tt.want, ids)
+				}
+			}
+		})
+	}
+}
+
+// writeArchive packs the testdata advisories into a zip or gzipped tar archive
+func writeArchive(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var (
+		zw *zip.Writer
+		gw *gzip.Writer
+		tw *tar.Writer
+	)
+	if filepath.Ext(path) == ".zip" {
+		zw = zip.NewWriter(f)
+	} else {
+		gw = gzip.NewWriter(f)
+		tw = tar.NewWriter(gw)
+	}
+	for _, name := range testdataFiles {
+		data, err := os.ReadFile(filepath.Join("testdata/advisories", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zw != nil {
+			w, err := zw.Create(name)
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if zw != nil {
+		err = zw.Close()
+	} else if err = tw.Close(); err == nil {
+		err = gw.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
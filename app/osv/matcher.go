@@ -0,0 +1,204 @@
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// Finding is a record affected by a vulnerability
+type Finding struct {
+	ClusterName         string `json:"cluster_name"`
+	InstanceId          string `json:"instance_id"`
+	ApplicationName     string `json:"application_name"`
+	ApplicationInstance string `json:"application_instance"`
+	ApplicationVersion  string `json:"application_version"`
+	// Image is set if the vulnerability was found in an image of the application
+	Image string `json:"image,omitempty"`
+
+	Package         string   `json:"package"`
+	Ecosystem       string   `json:"ecosystem"`
+	Version         string   `json:"version"`
+	FixedVersion    string   `json:"fixed_version,omitempty"`
+	VulnerabilityID string   `json:"vulnerability_id"`
+	Aliases         []string `json:"aliases,omitempty"`
+	Summary         string   `json:"summary"`
+	Severity        string   `json:"severity,omitempty"`
+}
+
+// Matcher matches records against the stored vulnerabilities
+//
+// Applications are matched by their name and images by their registry and
+// repository, i.e. quay.io/jetstack/cert-manager-controller. The mapping
+// translates both to the package names used by the advisories, optionally
+// prefixed with their ecosystem like Go:github.com/cert-manager/cert-manager.
+// Names without ecosystem are looked up in the default ecosystem, or in all
+// ecosystems if there is none. Applications that are not mapped are only
+// matched in the default ecosystem, as a name like redis is used by unrelated
+// packages of several ecosystems. Images are only matched if they are mapped,
+// as no ecosystem uses image names.
+type Matcher struct {
+	database *gorm.DB
+
+	mapping   map[string]string
+	ecosystem string
+}
+
+// NewMatcher creates a matcher with a mapping from application names and images to package names and a default ecosystem, which may be empty
+func NewMatcher(db *gorm.DB, mapping map[string]string, ecosystem string) *Matcher {
+	return &Matcher{
+		database:  db,
+		mapping:   mapping,
+		ecosystem: ecosystem,
+	}
+}
+
+// candidate is a version of a package found in a record
+type candidate struct {
+	record    *models.Record
+	image     string
+	ecosystem string
+	pkg       string
+	version   string
+}
+
+// splitPackage splits a mapped package into its ecosystem and name, names without ecosystem are in the default ecosystem
+//
+// Names containing a colon, i.e. of Maven packages, need an ecosystem.
+func (m *Matcher) splitPackage(value string) (string, string) {
+	if i := strings.Index(value, ":"); i >= 0 {
+		return value[:i], value[i+1:]
+	}
+	return m.ecosystem, value
+}
+
+// inEcosystem returns whether a package ecosystem belongs to the ecosystem of a candidate
+//
+// Ecosystems of distributions are matched including all their releases, i.e.
+// Debian matches Debian:11.
+func (c *candidate) inEcosystem(ecosystem string) bool {
+	return c.ecosystem == "" || ecosystem == c.ecosystem || strings.HasPrefix(ecosystem, c.ecosystem+":")
+}
+
+// Match returns the findings for records, the images of the records must be loaded
+func (m *Matcher) Match(ctx context.Context, records []models.Record) ([]Finding, error) {
+	candidates := []candidate{}
+	names := map[string]bool{}
+	for i := range records {
+		record := &records[i]
+		ecosystem, name := m.ecosystem, record.ApplicationName
+		mapped, ok := m.mapping[record.ApplicationName]
+		if ok {
+			ecosystem, name = m.splitPackage(mapped)
+		}
+		if ok || m.ecosystem != "" {
+			candidates = append(candidates, candidate{record: record, ecosystem: ecosystem, pkg: name, version: record.ApplicationVersion})
+			names[name] = true
+		}
+
+		for _, image := range record.Images {
+			mapped, ok := m.mapping[image.Registry+"/"+image.Repository]
+			if !ok || image.Tag == "" {
+				continue
+			}
+			ecosystem, name := m.splitPackage(mapped)
+			reference := image.Registry + "/" + image.Repository + ":" + image.Tag
+			candidates = append(candidates, candidate{record: record, image: reference, ecosystem: ecosystem, pkg: name, version: image.Tag})
+			names[name] = true
+		}
+	}
+	if len(names) == 0 {
+		return []Finding{}, nil
+	}
+
+	packages, vulnerabilities, err := m.load(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []Finding{}
+	for _, c := range candidates {
+		for _, pkg := range packages[c.pkg] {
+			if !c.inEcosystem(pkg.Ecosystem) {
+				continue
+			}
+			affected, fixed := pkg.affected.Affects(c.version)
+			if !affected {
+				continue
+			}
+			vulnerability := vulnerabilities[pkg.VulnerabilityID]
+			finding := Finding{
+				ClusterName:         c.record.ClusterName,
+				InstanceId:          c.record.InstanceId,
+				ApplicationName:     c.record.ApplicationName,
+				ApplicationInstance: c.record.ApplicationInstance,
+				ApplicationVersion:  c.record.ApplicationVersion,
+				Image:               c.image,
+				Package:             pkg.Name,
+				Ecosystem:           pkg.Ecosystem,
+				Version:             c.version,
+				FixedVersion:        fixed,
+				VulnerabilityID:     vulnerability.ID,
+				Summary:             vulnerability.Summary,
+				Severity:            vulnerability.Severity,
+			}
+			if vulnerability.Aliases != "" {
+				finding.Aliases = strings.Split(vulnerability.Aliases, ",")
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+// storedPackage is a vulnerable package with its decoded affected entry
+type storedPackage struct {
+	models.VulnerablePackage
+	affected Affected
+}
+
+// load reads the vulnerable packages with the given names and their vulnerabilities
+func (m *Matcher) load(ctx context.Context, names map[string]bool) (map[string][]storedPackage, map[string]models.Vulnerability, error) {
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	rows := []models.VulnerablePackage{}
+	if err := m.database.WithContext(ctx).Where("name IN ?", list).Order("vulnerability_id").Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	packages := map[string][]storedPackage{}
+	ids := map[string]bool{}
+	for _, row := range rows {
+		pkg := storedPackage{VulnerablePackage: row}
+		if err := json.Unmarshal([]byte(row.Affected), &pkg.affected); err != nil {
+			return nil, nil, err
+		}
+		packages[row.Name] = append(packages[row.Name], pkg)
+		ids[row.VulnerabilityID] = true
+	}
+
+	vulnerabilities := make(map[string]models.Vulnerability, len(ids))
+	if len(ids) == 0 {
+		return packages, vulnerabilities, nil
+	}
+	idList := make([]string, 0, len(ids))
+	for id := range ids {
+		idList = append(idList, id)
+	}
+	found := []models.Vulnerability{}
+	if err := m.database.WithContext(ctx).Where("id IN ?", idList).Find(&found).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, vulnerability := range found {
+		vulnerabilities[vulnerability.ID] = vulnerability
+	}
+	return packages, vulnerabilities, nil
+}
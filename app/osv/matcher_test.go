@@ -0,0 +1,116 @@
+package osv_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+)
+
+func Test_Matcher(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:Test_Matcher?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Vulnerability{}, &models.VulnerablePackage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// importing twice replaces the first import
+	for i := 0; i < 2; i++ {
+		if err := osv.NewImporter(gdb, "testdata/advisories").Import(context.Background()); err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+	}
+	var imported int64
+	gdb.Model(&models.Vulnerability{}).Count(&imported)
+	if imported != 2 {
+		t.Fatalf("expected 2 imported vulnerabilities, got %d", imported)
+	}
+
+	matcher := osv.NewMatcher(gdb, map[string]string{
+		"cert-manager": "github.com/cert-manager/cert-manager",
+		"registry.k8s.io/ingress-nginx/controller": "Go:k8s.io/ingress-nginx",
+	}, "")
+	findings, err := matcher.Match(context.Background(), []models.Record{
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.2"},
+		{ClusterName: "cluster-b", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.0", Images: []models.Image{
+			{Registry: "registry.k8s.io", Repository: "ingress-nginx/controller", Tag: "v1.3.0"},
+			{Registry: "docker.io", Repository: "library/nginx", Tag: "1.23"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Match() error = %v", err)
+	}
+
+	want := []osv.Finding{
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", VulnerabilityID: "TEST-2022-0001", Version: "v1.9.1", FixedVersion: "1.9.2", Severity: "HIGH"},
+		{ClusterName: "cluster-b", ApplicationName: "ingress-nginx", VulnerabilityID: "TEST-2022-0002", Version: "v1.3.0",
+			Image: "registry.k8s.io/ingress-nginx/controller:v1.3.0", Severity: "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("expected %d findings, got %+v", len(want), findings)
+	}
+	for i, w := range want {
+		got := findings[i]
+		if got.ClusterName != w.ClusterName || got.ApplicationName != w.ApplicationName || got.VulnerabilityID != w.VulnerabilityID ||
+			got.Version != w.Version || got.FixedVersion != w.FixedVersion || got.Image != w.Image || got.Severity != w.Severity {
+			t.Errorf("finding %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+	if len(findings[0].Aliases) != 1 || findings[0].Aliases[0] != "CVE-2022-0001" {
+		t.Errorf("expected the aliases of the advisory, got %v", findings[0].Aliases)
+	}
+}
+
+func Test_MatcherEcosystems(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:Test_MatcherEcosystems?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Vulnerability{}, &models.VulnerablePackage{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := osv.NewImporter(gdb, "testdata/ecosystems").Import(context.Background()); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	records := []models.Record{
+		{ClusterName: "cluster-a", ApplicationName: "ingress-nginx", ApplicationVersion: "v1.2.1"},
+		{ClusterName: "cluster-a", ApplicationName: "k8s.io/ingress-nginx", ApplicationVersion: "v1.2.1"},
+		// PyPI versions are not compared like semantic versions, so its ECOSYSTEM ranges are ignored
+		{ClusterName: "cluster-a", ApplicationName: "redis", ApplicationVersion: "4.3.0"},
+	}
+	tests := []struct {
+		name      string
+		ecosystem string
+		want      []string
+	}{
+		// unmapped applications would match the npm package of the same name
+		{name: "only mapped applications", want: []string{"ingress-nginx Go"}},
+		{name: "unmapped applications in the default ecosystem", ecosystem: "Go", want: []string{"ingress-nginx Go", "k8s.io/ingress-nginx Go"}},
+		{name: "ecosystem without semantic versioning", ecosystem: "PyPI", want: []string{"ingress-nginx Go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := osv.NewMatcher(gdb, map[string]string{"ingress-nginx": "Go:k8s.io/ingress-nginx"}, tt.ecosystem)
+			findings, err := matcher.Match(context.Background(), records)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			got := []string{}
+			for _, finding := range findings {
+				got = append(got, finding.ApplicationName+" "+finding.Ecosystem)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected findings %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// importBatchSize limits the rows per insert statement, sqlite allows only so many variables
+const importBatchSize = 500
+
+// Import replaces the stored vulnerabilities with advisories in one transaction
+//
+// Advisories that are missing from the dump, i.e. because they were
+// withdrawn, are removed.
+func Import(ctx context.Context, db *gorm.DB, advisories []Advisory) error {
+	now := time.Now()
+	vulnerabilities := make([]models.Vulnerability, 0, len(advisories))
+	packages := []models.VulnerablePackage{}
+	seen := make(map[string]bool, len(advisories))
+	for _, advisory := range advisories {
+		// merged dumps may contain an advisory twice, the first one wins
+		if seen[advisory.ID] {
+			continue
+		}
+		seen[advisory.ID] = true
+
+		vulnerabilities = append(vulnerabilities, models.Vulnerability{
+			ID:         advisory.ID,
+			ImportedAt: now,
+			Summary:    advisory.Summary,
+			Aliases:    strings.Join(advisory.Aliases, ","),
+			Severity:   advisory.SeverityLabel(),
+			Published:  advisory.Published,
+			Modified:   advisory.Modified,
+		})
+		for _, affected := range advisory.Affected {
+			data, err := json.Marshal(affected)
+			if err != nil {
+				return err
+			}
+			packages = append(packages, models.VulnerablePackage{
+				VulnerabilityID: advisory.ID,
+				Ecosystem:       affected.Package.Ecosystem,
+				Name:            affected.Package.Name,
+				Affected:        string(data),
+			})
+		}
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.VulnerablePackage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.Vulnerability{}).Error; err != nil {
+			return err
+		}
+		if len(vulnerabilities) == 0 {
+			return nil
+		}
+		if err := tx.Omit(clause.Associations).CreateInBatches(vulnerabilities, importBatchSize).Error; err != nil {
+			return err
+		}
+		if len(packages) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(packages, importBatchSize).Error
+	})
+}
+
+// Importer periodically imports an OSV database dump from the local filesystem
+//
+// Air-gapped installations update the dump out of band, i.e. from a volume
+// that is synced from https://osv.dev, and mopsos picks it up on the next import.
+type Importer struct {
+	database *gorm.DB
+
+	path string
+}
+
+// NewImporter creates an importer for the dump at path
+func NewImporter(db *gorm.DB, path string) *Importer {
+	return &Importer{
+		database: db,
+		path:     path,
+	}
+}
+
+// Run imports the dump every interval until the context is done
+func (i *Importer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := i.Import(ctx); err != nil {
+			logrus.WithError(err).WithField("path", i.path).Error("failed to import vulnerabilities")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Import loads the dump and replaces the stored vulnerabilities
+func (i *Importer) Import(ctx context.Context) error {
+	advisories, err := Load(i.path)
+	if err != nil {
+		return err
+	}
+	if err := Import(ctx, i.database, advisories); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"path":       i.path,
+		"advisories": len(advisories),
+	}).Info("imported vulnerabilities")
+	return nil
+}
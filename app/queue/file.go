@@ -0,0 +1,239 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+const (
+	opEnqueue = "enqueue"
+	opAck     = "ack"
+)
+
+// entry is a line in the write-ahead log of a FileQueue
+type entry struct {
+	Op   string            `json:"op"`
+	ID   uint64            `json:"id"`
+	Data *models.EventData `json:"data,omitempty"`
+}
+
+// FileQueue persists events to a write-ahead log so they survive restarts
+//
+// Every event is synced to the log before Enqueue returns and stays there
+// until it is acknowledged. Entries are written while holding the lock, so
+// the log keeps the order of the ids, but synced without it, so concurrent
+// calls of Enqueue, Dequeue and Ack don't wait for each other's fsync. Events that were not acknowledged are redelivered
+// after reopening the log. The log is truncated whenever all events have been
+// acknowledged so it does not grow without bounds.
+type FileQueue struct {
+	buffer *buffer
+
+	path string
+	file *os.File
+}
+
+// OpenFileQueue opens or creates the write-ahead log at path and loads unacknowledged events
+func OpenFileQueue(path string, size int) (*FileQueue, error) {
+	pending, lastID, err := readLog(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeLog(path, pending); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &FileQueue{
+		buffer: newBuffer(size),
+		path:   path,
+		file:   file,
+	}
+	q.buffer.nextID = lastID + 1
+	q.buffer.items = pending
+	q.buffer.pending = len(pending)
+	if len(pending) > 0 {
+		logrus.WithField("events", len(pending)).Info("replaying unhandled events from queue")
+	}
+	return q, nil
+}
+
+// Enqueue implements Queue
+func (q *FileQueue) Enqueue(data models.EventData) error {
+	id, err := q.buffer.reserve()
+	if err != nil {
+		return err
+	}
+	err = q.write(entry{Op: opEnqueue, ID: id, Data: &data})
+	q.buffer.mu.Unlock()
+	if err == nil {
+		err = q.file.Sync()
+	}
+	q.buffer.mu.Lock()
+	if err != nil {
+		// the log is kept open for reservations, close it if Close was called in the meantime
+		drained := q.buffer.closed && q.buffer.pending == 0 && q.buffer.reserved == 1
+		q.buffer.release()
+		if drained {
+			q.file.Close()
+		}
+		return err
+	}
+	q.buffer.push(&Item{ID: id, Data: data})
+	return nil
+}
+
+// Dequeue implements Queue
+func (q *FileQueue) Dequeue() (*Item, error) {
+	return q.buffer.pop()
+}
+
+// Ack implements Queue
+//
+// Acknowledgements are not synced, after a crash the event is redelivered
+// which is harmless as storing an event is idempotent.
+func (q *FileQueue) Ack(item *Item) error {
+	q.buffer.mu.Lock()
+	defer q.buffer.mu.Unlock()
+
+	q.buffer.pending--
+	if q.buffer.pending > 0 || q.buffer.reserved > 0 {
+		return q.write(entry{Op: opAck, ID: item.ID})
+	}
+
+	// nothing is pending or being enqueued, start over with an empty log
+	if err := q.file.Truncate(0); err != nil {
+		return err
+	}
+	if q.buffer.closed {
+		return q.file.Close()
+	}
+	return nil
+}
+
+// Len implements Queue
+func (q *FileQueue) Len() int {
+	return q.buffer.len()
+}
+
+// Close implements Queue
+//
+// The log stays open until the remaining events have been acknowledged.
+func (q *FileQueue) Close() error {
+	q.buffer.close()
+
+	q.buffer.mu.Lock()
+	defer q.buffer.mu.Unlock()
+	if q.buffer.pending == 0 && q.buffer.reserved == 0 {
+		return q.file.Close()
+	}
+	return nil
+}
+
+// write appends an entry to the log without syncing it, the caller must hold the buffer lock
+func (q *FileQueue) write(e entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = q.file.Write(append(line, '\n'))
+	return err
+}
+
+// readLog returns the unacknowledged events in a log and the highest id used
+//
+// A truncated last line, as left by a crash during a write, is skipped.
+func readLog(path string) ([]*Item, uint64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var lastID uint64
+	order := []uint64{}
+	items := map[uint64]*Item{}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				logrus.WithField("path", path).Warn("skipping incomplete entry at the end of the queue")
+			}
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		e := entry{}
+		if err := json.Unmarshal(line, &e); err != nil {
+			logrus.WithField("path", path).WithError(err).Warn("skipping invalid entry in queue")
+			continue
+		}
+		if e.ID > lastID {
+			lastID = e.ID
+		}
+		switch e.Op {
+		case opEnqueue:
+			if e.Data == nil {
+				continue
+			}
+			order = append(order, e.ID)
+			items[e.ID] = &Item{ID: e.ID, Data: *e.Data}
+		case opAck:
+			delete(items, e.ID)
+		}
+	}
+
+	pending := make([]*Item, 0, len(items))
+	for _, id := range order {
+		if item, ok := items[id]; ok {
+			pending = append(pending, item)
+		}
+	}
+	return pending, lastID, nil
+}
+
+// writeLog atomically replaces the log with one that only contains the pending events
+func writeLog(path string, pending []*Item) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, item := range pending {
+		if err := encoder.Encode(entry{Op: opEnqueue, ID: item.ID, Data: &item.Data}); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
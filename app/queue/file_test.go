@@ -0,0 +1,128 @@
+package queue_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/queue"
+)
+
+func Test_FileQueueReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+
+	q, err := queue.OpenFileQueue(path, 0)
+	if err != nil {
+		t.Fatalf("OpenFileQueue() error = %v", err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := q.Enqueue(eventData(id)); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	item, err := q.Dequeue()
+	if err != nil {
+		t.Fatalf("Dequeue() error = %v", err)
+	}
+	if err := q.Ack(item); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	// the second event is dequeued but never acknowledged, i.e. because of a crash
+	if _, err := q.Dequeue(); err != nil {
+		t.Fatalf("Dequeue() error = %v", err)
+	}
+	q.Close()
+
+	// simulate a crash while writing the next entry
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString(`{"op":"enqueue","id":4,"da`)
+	file.Close()
+
+	q, err = queue.OpenFileQueue(path, 0)
+	if err != nil {
+		t.Fatalf("OpenFileQueue() error = %v", err)
+	}
+	if q.Len() != 2 {
+		t.Fatalf("expected 2 events to be replayed, got %d", q.Len())
+	}
+	for _, want := range []string{"2", "3"} {
+		item, err := q.Dequeue()
+		if err != nil {
+			t.Fatalf("Dequeue() error = %v", err)
+		}
+		if item.Data.Event.ID() != want || item.Data.Record.ApplicationName != "app-"+want {
+			t.Errorf("expected event %s, got %s", want, item.Data.Event.ID())
+		}
+		if err := q.Ack(item); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+	}
+
+	// new events do not reuse the ids of replayed events
+	if err := q.Enqueue(eventData("4")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	item, _ = q.Dequeue()
+	if item.ID <= 3 {
+		t.Errorf("expected a new id, got %d", item.ID)
+	}
+	if err := q.Ack(item); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	q.Close()
+
+	// the log is truncated once all events are acknowledged
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected an empty log, got %d bytes", info.Size())
+	}
+}
+
+func Test_FileQueueConcurrentEnqueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+
+	q, err := queue.OpenFileQueue(path, 10)
+	if err != nil {
+		t.Fatalf("OpenFileQueue() error = %v", err)
+	}
+	// the events are enqueued while others are dequeued and acknowledged
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := q.Enqueue(eventData(fmt.Sprint(i))); err != nil {
+				t.Errorf("Enqueue() error = %v", err)
+			}
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		item, err := q.Dequeue()
+		if err != nil {
+			t.Fatalf("Dequeue() error = %v", err)
+		}
+		if err := q.Ack(item); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+	}
+	wg.Wait()
+	q.Close()
+
+	// all events that were not acknowledged are in the log
+	q, err = queue.OpenFileQueue(path, 0)
+	if err != nil {
+		t.Fatalf("OpenFileQueue() error = %v", err)
+	}
+	defer q.Close()
+	if q.Len() != 10 {
+		t.Errorf("expected 10 events to be replayed, got %d", q.Len())
+	}
+}
@@ -0,0 +1,45 @@
+package queue
+
+import "github.com/adfinis-sygroup/mopsos/app/models"
+
+// MemoryQueue keeps events in memory, events that were not handled are lost on restart
+type MemoryQueue struct {
+	buffer *buffer
+}
+
+// NewMemoryQueue creates a queue that holds up to size events, a size of 0 means unlimited
+func NewMemoryQueue(size int) *MemoryQueue {
+	return &MemoryQueue{buffer: newBuffer(size)}
+}
+
+// Enqueue implements Queue
+func (q *MemoryQueue) Enqueue(data models.EventData) error {
+	id, err := q.buffer.reserve()
+	if err != nil {
+		return err
+	}
+	q.buffer.push(&Item{ID: id, Data: data})
+	return nil
+}
+
+// Dequeue implements Queue
+func (q *MemoryQueue) Dequeue() (*Item, error) {
+	return q.buffer.pop()
+}
+
+// Ack implements Queue
+func (q *MemoryQueue) Ack(item *Item) error {
+	q.buffer.ack()
+	return nil
+}
+
+// Len implements Queue
+func (q *MemoryQueue) Len() int {
+	return q.buffer.len()
+}
+
+// Close implements Queue
+func (q *MemoryQueue) Close() error {
+	q.buffer.close()
+	return nil
+}
@@ -0,0 +1,144 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// ErrClosed is returned when using a queue that has been closed
+var ErrClosed = errors.New("queue is closed")
+
+// Queue passes events from the server to the handler
+//
+// Events stay in the queue until they are acknowledged, implementations may
+// use this to redeliver events that were not handled before a restart.
+type Queue interface {
+	// Enqueue adds an event, it blocks while the queue is full
+	Enqueue(data models.EventData) error
+	// Dequeue blocks until an event is available, it returns ErrClosed once
+	// the queue is closed and all remaining events have been dequeued
+	Dequeue() (*Item, error)
+	// Ack marks an event as handled
+	Ack(item *Item) error
+	// Len returns the number of events that have not been acknowledged
+	Len() int
+	// Close stops accepting new events, remaining events can still be dequeued
+	Close() error
+}
+
+// Item is an event on the queue
+type Item struct {
+	ID   uint64
+	Data models.EventData
+}
+
+// New creates a queue for a provider, either 'memory' or 'file'
+func New(provider string, path string, size int) (Queue, error) {
+	switch provider {
+	case "", "memory":
+		return NewMemoryQueue(size), nil
+	case "file":
+		return OpenFileQueue(path, size)
+	default:
+		return nil, fmt.Errorf("unknown queue provider %q", provider)
+	}
+}
+
+// buffer is the in memory part shared by all queue implementations
+type buffer struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	size  int
+	items []*Item
+	// reserved counts the reservations that have not been pushed or released yet
+	reserved int
+	pending  int
+	nextID   uint64
+	closed   bool
+}
+
+func newBuffer(size int) *buffer {
+	b := &buffer{size: size, nextID: 1}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// reserve waits for space in the buffer and returns the id for the next item
+//
+// The lock is held when reserve returns without error, push or release
+// releases it. The reservation takes up space in the buffer even if the lock
+// is released in between.
+func (b *buffer) reserve() (uint64, error) {
+	b.mu.Lock()
+	for !b.closed && b.size > 0 && len(b.items)+b.reserved >= b.size {
+		b.cond.Wait()
+	}
+	if b.closed {
+		b.mu.Unlock()
+		return 0, ErrClosed
+	}
+	id := b.nextID
+	b.nextID++
+	b.reserved++
+	return id, nil
+}
+
+// push adds a reserved item and releases the lock
+func (b *buffer) push(item *Item) {
+	b.items = append(b.items, item)
+	b.reserved--
+	b.pending++
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// release gives up a reservation without adding an item and releases the lock
+func (b *buffer) release() {
+	b.reserved--
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (b *buffer) pop() (*Item, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for !b.closed && len(b.items) == 0 {
+		b.cond.Wait()
+	}
+	if len(b.items) == 0 {
+		return nil, ErrClosed
+	}
+	item := b.items[0]
+	b.items[0] = nil
+	b.items = b.items[1:]
+	b.cond.Broadcast()
+	return item, nil
+}
+
+// ack marks an item as handled and returns the number of remaining items
+func (b *buffer) ack() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending--
+	return b.pending
+}
+
+func (b *buffer) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pending
+}
+
+func (b *buffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
@@ -0,0 +1,82 @@
+package queue_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/queue"
+)
+
+func eventData(id string) models.EventData {
+	evt := cloudevents.NewEvent(cloudevents.VersionV1)
+	evt.SetID(id)
+	evt.SetSource("test")
+	evt.SetType("cloud.adfinis.mopsos.updateRecord")
+	return models.EventData{
+		Event: evt,
+		Record: models.Record{
+			ClusterName:        "cluster",
+			ApplicationName:    "app-" + id,
+			ApplicationVersion: "1.0.0",
+		},
+	}
+}
+
+func Test_MemoryQueue(t *testing.T) {
+	q := queue.NewMemoryQueue(1)
+
+	if err := q.Enqueue(eventData("1")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	// the queue is full so the second enqueue blocks until the first event is dequeued
+	enqueued := make(chan error)
+	go func() {
+		enqueued <- q.Enqueue(eventData("2"))
+	}()
+	select {
+	case <-enqueued:
+		t.Fatal("expected Enqueue() to block while the queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	item, err := q.Dequeue()
+	if err != nil || item.Data.Event.ID() != "1" {
+		t.Fatalf("expected first event, got %v (%v)", item, err)
+	}
+	if err := <-enqueued; err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if q.Len() != 2 {
+		t.Errorf("expected 2 unacknowledged events, got %d", q.Len())
+	}
+	if err := q.Ack(item); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	// closing keeps the remaining events available for draining
+	q.Close()
+	if err := q.Enqueue(eventData("3")); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	item, err = q.Dequeue()
+	if err != nil || item.Data.Event.ID() != "2" {
+		t.Fatalf("expected second event, got %v (%v)", item, err)
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func Test_New(t *testing.T) {
+	if _, err := queue.New("memory", "", 0); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+	if _, err := queue.New("kafka", "", 0); err == nil {
+		t.Error("expected error for unknown provider, got nil")
+	}
+}
@@ -0,0 +1,111 @@
+// Package ratelimit limits how many events each cluster may send
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is the rate of a token bucket in events per second and its size
+//
+// A bucket starts full, so a cluster may send Burst events at once and then
+// Rate events per second. A Rate of 0 disables the limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit in the form 'rate' or 'rate:burst'
+//
+// Without burst the bucket holds one second worth of events.
+func ParseLimit(value string) (Limit, error) {
+	parts := strings.SplitN(value, ":", 2)
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate < 0 {
+		return Limit{}, fmt.Errorf("invalid rate %q, expected a positive number", parts[0])
+	}
+	limit := Limit{Rate: rate, Burst: int(math.Ceil(rate))}
+	if len(parts) == 2 {
+		if limit.Burst, err = strconv.Atoi(parts[1]); err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid burst %q, expected a number greater than 0", parts[1])
+		}
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return limit, nil
+}
+
+// bucket holds the tokens of a cluster
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket for every cluster
+type Limiter struct {
+	mu sync.Mutex
+
+	limit     Limit
+	overrides map[string]Limit
+	buckets   map[string]*bucket
+}
+
+// NewLimiter creates a limiter with a default limit and limits for some clusters
+func NewLimiter(limit Limit, overrides map[string]Limit) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		overrides: overrides,
+		buckets:   map[string]*bucket{},
+	}
+}
+
+// Allow takes a token from the bucket of a cluster
+//
+// If the bucket is empty it returns false and how long to wait for the next token.
+func (l *Limiter) Allow(cluster string) (bool, time.Duration) {
+	return l.AllowN(cluster, 1)
+}
+
+// AllowN takes a token for each of n events from the bucket of a cluster
+//
+// More events than the burst can never fit into the bucket, they are allowed
+// once the bucket is full and leave it in debt until the rate paid them off.
+// If there are not enough tokens it returns false and how long to wait for them.
+func (l *Limiter) AllowN(cluster string, n int) (bool, time.Duration) {
+	limit := l.Limit(cluster)
+	if limit.Rate == 0 || n <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[cluster]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[cluster] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	needed := math.Min(float64(n), float64(limit.Burst))
+	if b.tokens < needed {
+		return false, time.Duration((needed - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.tokens -= float64(n)
+	return true, 0
+}
+
+// Limit returns the limit of a cluster
+func (l *Limiter) Limit(cluster string) Limit {
+	if limit, ok := l.overrides[cluster]; ok {
+		return limit
+	}
+	return l.limit
+}
@@ -0,0 +1,101 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adfinis-sygroup/mopsos/app/ratelimit"
+)
+
+func Test_ParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    ratelimit.Limit
+		wantErr bool
+	}{
+		{value: "10", want: ratelimit.Limit{Rate: 10, Burst: 10}},
+		{value: "0.5", want: ratelimit.Limit{Rate: 0.5, Burst: 1}},
+		{value: "5:20", want: ratelimit.Limit{Rate: 5, Burst: 20}},
+		{value: "0", want: ratelimit.Limit{Rate: 0, Burst: 1}},
+		{value: "-1", wantErr: true},
+		{value: "5:0", wantErr: true},
+		{value: "fast", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ratelimit.ParseLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_LimiterAllow(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limit{Rate: 20, Burst: 3}, map[string]ratelimit.Limit{"bulk": {Rate: 0}})
+
+	// the burst is available right away
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("cluster-a"); !ok {
+			t.Fatalf("expected event %d to be allowed", i+1)
+		}
+	}
+	ok, retry := l.Allow("cluster-a")
+	if ok {
+		t.Fatal("expected event to be limited")
+	}
+	if retry <= 0 || retry > 50*time.Millisecond {
+		t.Errorf("expected retry within 50ms, got %s", retry)
+	}
+
+	// other clusters have their own bucket
+	if ok, _ := l.Allow("cluster-b"); !ok {
+		t.Error("expected event of other cluster to be allowed")
+	}
+
+	// tokens are refilled at the rate
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := l.Allow("cluster-a"); !ok {
+		t.Error("expected event to be allowed after refill")
+	}
+
+	// clusters without limit are never limited
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("bulk"); !ok {
+			t.Fatal("expected unlimited cluster to be allowed")
+		}
+	}
+}
+
+func Test_LimiterAllowN(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limit{Rate: 100, Burst: 5}, nil)
+
+	if ok, _ := l.AllowN("cluster-a", 3); !ok {
+		t.Fatal("expected 3 events to be allowed")
+	}
+	ok, retry := l.AllowN("cluster-a", 3)
+	if ok {
+		t.Fatal("expected 3 more events to be limited")
+	}
+	if retry <= 0 || retry > 10*time.Millisecond {
+		t.Errorf("expected retry within 10ms, got %s", retry)
+	}
+	if ok, _ := l.AllowN("cluster-a", 2); !ok {
+		t.Fatal("expected the remaining 2 events to be allowed")
+	}
+
+	// more events than the burst need a full bucket and leave it in debt
+	if ok, _ := l.AllowN("cluster-b", 15); !ok {
+		t.Fatal("expected events beyond the burst to be allowed with a full bucket")
+	}
+	ok, retry = l.AllowN("cluster-b", 1)
+	if ok {
+		t.Fatal("expected the bucket to be in debt")
+	}
+	if retry < 90*time.Millisecond {
+		t.Errorf("expected to wait for the debt to be paid off, got %s", retry)
+	}
+}
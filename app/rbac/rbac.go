@@ -0,0 +1,136 @@
+// Package rbac decides what authenticated users and clusters may do
+package rbac
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Role grants access to a group of endpoints
+type Role string
+
+const (
+	// RoleIngest may send events to the webhook
+	RoleIngest Role = "ingest"
+	// RoleReader may read the inventory through the API
+	RoleReader Role = "reader"
+	// RoleAdmin may manage clusters and dead letters, it includes RoleReader
+	RoleAdmin Role = "admin"
+)
+
+// Identity is a user or cluster with its roles
+//
+// Clusters and Labels limit which clusters the identity may read. A cluster
+// is readable if it is listed in Clusters or if it has all Labels, without
+// either all clusters are readable.
+type Identity struct {
+	Name     string            `json:"name" yaml:"name"`
+	Roles    []Role            `json:"roles" yaml:"roles"`
+	Clusters []string          `json:"clusters,omitempty" yaml:"clusters"`
+	Labels   map[string]string `json:"labels,omitempty" yaml:"labels"`
+}
+
+// Has returns whether the identity has a role
+func (i *Identity) Has(role Role) bool {
+	for _, r := range i.Roles {
+		if r == role || (r == RoleAdmin && role == RoleReader) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scoped returns whether the identity may only read some clusters
+func (i *Identity) Scoped() bool {
+	return len(i.Clusters) > 0 || len(i.Labels) > 0
+}
+
+// CanRead returns whether the identity may read a cluster with the given labels
+func (i *Identity) CanRead(cluster string, labels map[string]string) bool {
+	if !i.Scoped() {
+		return true
+	}
+	for _, name := range i.Clusters {
+		if name == cluster {
+			return true
+		}
+	}
+	if len(i.Labels) == 0 {
+		return false
+	}
+	for key, value := range i.Labels {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy holds the identities of users and clusters with explicit roles
+type Policy struct {
+	identities map[string]*Identity
+}
+
+// policyFile is the format of the files read by Load
+type policyFile struct {
+	Identities []Identity `yaml:"identities"`
+}
+
+// NewPolicy creates a policy from a list of identities
+func NewPolicy(identities []Identity) (*Policy, error) {
+	p := &Policy{identities: make(map[string]*Identity, len(identities))}
+	for i := range identities {
+		identity := identities[i]
+		if identity.Name == "" {
+			return nil, fmt.Errorf("identity %d has no name", i+1)
+		}
+		if _, ok := p.identities[identity.Name]; ok {
+			return nil, fmt.Errorf("identity %q is defined more than once", identity.Name)
+		}
+		for _, role := range identity.Roles {
+			switch role {
+			case RoleIngest, RoleReader, RoleAdmin:
+			default:
+				return nil, fmt.Errorf("identity %q has unknown role %q", identity.Name, role)
+			}
+		}
+		p.identities[identity.Name] = &identity
+	}
+	return p, nil
+}
+
+// Load reads a policy from a YAML file with a list of identities
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := policyFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return NewPolicy(file.Identities)
+}
+
+// Identity returns the identity of a user or cluster
+//
+// Names that are not part of the policy get an unscoped identity with the
+// given role, which is the role granted by the credentials they used.
+func (p *Policy) Identity(name string, role Role) *Identity {
+	if p != nil {
+		if identity, ok := p.identities[name]; ok {
+			return identity
+		}
+	}
+	return &Identity{Name: name, Roles: []Role{role}}
+}
+
+// Len returns the number of identities in the policy
+func (p *Policy) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.identities)
+}
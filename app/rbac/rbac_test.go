@@ -0,0 +1,86 @@
+package rbac_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/rbac"
+)
+
+func Test_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.yaml")
+	policy := `
+identities:
+  - name: team-a
+    roles: [reader]
+    clusters: [cluster-a]
+  - name: team-b
+    roles: [reader]
+    labels:
+      team: b
+  - name: ops
+    roles: [admin]
+  - name: cluster-c
+    roles: []
+`
+	if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := rbac.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if p.Len() != 4 {
+		t.Errorf("expected 4 identities, got %d", p.Len())
+	}
+
+	tests := []struct {
+		name     string
+		role     rbac.Role
+		want     rbac.Role
+		wantHas  bool
+		cluster  string
+		labels   map[string]string
+		wantRead bool
+	}{
+		{name: "team-a", role: rbac.RoleReader, want: rbac.RoleReader, wantHas: true, cluster: "cluster-a", wantRead: true},
+		{name: "team-a", role: rbac.RoleReader, want: rbac.RoleAdmin, wantHas: false, cluster: "cluster-b", wantRead: false},
+		{name: "team-b", role: rbac.RoleReader, want: rbac.RoleReader, wantHas: true, cluster: "cluster-b", labels: map[string]string{"team": "b", "env": "prod"}, wantRead: true},
+		{name: "team-b", role: rbac.RoleReader, want: rbac.RoleReader, wantHas: true, cluster: "cluster-a", labels: map[string]string{"team": "a"}, wantRead: false},
+		{name: "ops", role: rbac.RoleAdmin, want: rbac.RoleReader, wantHas: true, cluster: "cluster-a", wantRead: true},
+		{name: "ops", role: rbac.RoleAdmin, want: rbac.RoleIngest, wantHas: false, cluster: "cluster-a", wantRead: true},
+		{name: "cluster-c", role: rbac.RoleIngest, want: rbac.RoleIngest, wantHas: false, cluster: "cluster-c", wantRead: true},
+		{name: "cluster-d", role: rbac.RoleIngest, want: rbac.RoleIngest, wantHas: true, cluster: "cluster-a", wantRead: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+string(tt.want), func(t *testing.T) {
+			identity := p.Identity(tt.name, tt.role)
+			if got := identity.Has(tt.want); got != tt.wantHas {
+				t.Errorf("Has(%s) = %v, want %v", tt.want, got, tt.wantHas)
+			}
+			if got := identity.CanRead(tt.cluster, tt.labels); got != tt.wantRead {
+				t.Errorf("CanRead(%s) = %v, want %v", tt.cluster, got, tt.wantRead)
+			}
+		})
+	}
+}
+
+func Test_NewPolicyRejectsInvalidIdentities(t *testing.T) {
+	tests := []struct {
+		name       string
+		identities []rbac.Identity
+	}{
+		{name: "unknown role", identities: []rbac.Identity{{Name: "team-a", Roles: []rbac.Role{"writer"}}}},
+		{name: "missing name", identities: []rbac.Identity{{Roles: []rbac.Role{rbac.RoleReader}}}},
+		{name: "duplicate", identities: []rbac.Identity{{Name: "team-a"}, {Name: "team-a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := rbac.NewPolicy(tt.identities); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
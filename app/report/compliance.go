@@ -0,0 +1,61 @@
+package report
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// RuleCompliance summarizes the results of a compliance rule
+type RuleCompliance struct {
+	Rule       string                    `json:"rule"`
+	Compliant  int                       `json:"compliant"`
+	Violations int                       `json:"violations"`
+	Violating  []models.ComplianceResult `json:"violating"`
+}
+
+// Compliance builds the compliance report for the given rules, or all rules if none are given
+//
+// Rules are sorted by name and their violations by cluster and application.
+// Rules that don't match any record are listed without results.
+func Compliance(ctx context.Context, db *gorm.DB, rules []string) ([]RuleCompliance, error) {
+	ruleQuery := db.Session(&gorm.Session{NewDB: true}).WithContext(ctx).Model(&models.ComplianceRule{}).Order("name")
+	if len(rules) > 0 {
+		ruleQuery = ruleQuery.Where("name IN ?", rules)
+	}
+	names := []string{}
+	if err := ruleQuery.Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+
+	query := db.WithContext(ctx).Order("rule_name").Order("cluster_name").Order("application_name").Order("application_instance")
+	if len(rules) > 0 {
+		query = query.Where("rule_name IN ?", rules)
+	}
+	results := []models.ComplianceResult{}
+	if err := query.Find(&results).Error; err != nil {
+		return nil, err
+	}
+
+	byRule := make(map[string]*RuleCompliance, len(names))
+	summaries := make([]RuleCompliance, len(names))
+	for i, name := range names {
+		summaries[i] = RuleCompliance{Rule: name, Violating: []models.ComplianceResult{}}
+		byRule[name] = &summaries[i]
+	}
+	for _, result := range results {
+		summary, ok := byRule[result.RuleName]
+		if !ok {
+			continue
+		}
+		if result.Compliant {
+			summary.Compliant++
+			continue
+		}
+		summary.Violations++
+		summary.Violating = append(summary.Violating, result)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,165 @@
+package report
+
+import (
+	"context"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+// ApplicationDrift compares the versions of an application across all clusters
+type ApplicationDrift struct {
+	ApplicationName string `json:"application_name"`
+	NewestVersion   string `json:"newest_version"`
+	OldestVersion   string `json:"oldest_version"`
+	// Spread is the gap between the oldest and the newest version
+	Spread   version.Gap    `json:"spread"`
+	Versions []VersionGroup `json:"versions"`
+	Laggards []Laggard      `json:"laggards"`
+}
+
+// VersionGroup lists the clusters an application runs on in a single version
+type VersionGroup struct {
+	Version  string   `json:"version"`
+	Clusters []string `json:"clusters"`
+}
+
+// Laggard is a record that is behind the newest version of its application
+type Laggard struct {
+	ClusterName         string      `json:"cluster_name"`
+	InstanceId          string      `json:"instance_id"`
+	ApplicationInstance string      `json:"application_instance"`
+	ApplicationVersion  string      `json:"application_version"`
+	Gap                 version.Gap `json:"gap"`
+}
+
+// Drift builds the drift report for the given applications, or all applications if none are given
+//
+// Applications are sorted by name, their versions newest first and the laggards
+// with the biggest gap first.
+func Drift(ctx context.Context, db *gorm.DB, applications []string) ([]ApplicationDrift, error) {
+	query := db.WithContext(ctx).Order("application_name").Order("cluster_name")
+	if len(applications) > 0 {
+		query = query.Where("application_name IN ?", applications)
+	}
+	records := []models.Record{}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	byApplication := map[string][]models.Record{}
+	names := []string{}
+	for _, record := range records {
+		if _, ok := byApplication[record.ApplicationName]; !ok {
+			names = append(names, record.ApplicationName)
+		}
+		byApplication[record.ApplicationName] = append(byApplication[record.ApplicationName], record)
+	}
+
+	report := make([]ApplicationDrift, 0, len(names))
+	for _, name := range names {
+		report = append(report, applicationDrift(name, byApplication[name]))
+	}
+	return report, nil
+}
+
+func applicationDrift(name string, records []models.Record) ApplicationDrift {
+	drift := ApplicationDrift{
+		ApplicationName: name,
+		Versions:        []VersionGroup{},
+		Laggards:        []Laggard{},
+	}
+
+	parsed := map[string]*version.Version{}
+	groups := map[string]*VersionGroup{}
+	for _, record := range records {
+		group, ok := groups[record.ApplicationVersion]
+		if !ok {
+			group = &VersionGroup{Version: record.ApplicationVersion}
+			groups[record.ApplicationVersion] = group
+			parsed[record.ApplicationVersion], _ = version.Parse(record.ApplicationVersion)
+		}
+		if len(group.Clusters) == 0 || group.Clusters[len(group.Clusters)-1] != record.ClusterName {
+			group.Clusters = append(group.Clusters, record.ClusterName)
+		}
+	}
+
+	for _, group := range groups {
+		drift.Versions = append(drift.Versions, *group)
+	}
+	// parsed versions come first, newest first, followed by the versions that can't be parsed
+	sort.Slice(drift.Versions, func(i, j int) bool {
+		a, b := drift.Versions[i].Version, drift.Versions[j].Version
+		va, vb := parsed[a], parsed[b]
+		if va == nil || vb == nil {
+			if va != nil || vb != nil {
+				return va != nil
+			}
+			return a < b
+		}
+		if c := version.Compare(va, vb); c != 0 {
+			return c > 0
+		}
+		// versions like v1.9.1 and 1.9.1 are equal but still need a stable order
+		return a < b
+	})
+
+	known := make([]*version.Version, 0, len(parsed))
+	var oldest *version.Version
+	for _, v := range parsed {
+		if v == nil {
+			continue
+		}
+		known = append(known, v)
+		if oldest == nil || version.Compare(v, oldest) < 0 {
+			oldest = v
+		}
+	}
+	newest := version.Newest(known)
+	if newest == nil {
+		drift.Spread = version.Gap{Level: version.LevelUnknown}
+		return drift
+	}
+	drift.NewestVersion = newest.String()
+	drift.OldestVersion = oldest.String()
+	drift.Spread = version.GapBetween(oldest, newest)
+
+	for _, record := range records {
+		gap := version.GapBetween(parsed[record.ApplicationVersion], newest)
+		if gap.Level == version.LevelNone || gap.Level == version.LevelUnknown {
+			continue
+		}
+		drift.Laggards = append(drift.Laggards, Laggard{
+			ClusterName:         record.ClusterName,
+			InstanceId:          record.InstanceId,
+			ApplicationInstance: record.ApplicationInstance,
+			ApplicationVersion:  record.ApplicationVersion,
+			Gap:                 gap,
+		})
+	}
+	// laggards always have a parsed version
+	sort.SliceStable(drift.Laggards, func(i, j int) bool {
+		return version.Compare(parsed[drift.Laggards[i].ApplicationVersion], parsed[drift.Laggards[j].ApplicationVersion]) < 0
+	})
+
+	return drift
+}
+
+// IsDrifting reports whether the application runs in more than one version
+func (d ApplicationDrift) IsDrifting() bool {
+	return len(d.Versions) > 1
+}
+
+// OnlyDrifting filters a report down to the applications running in more than one version
+func OnlyDrifting(report []ApplicationDrift) []ApplicationDrift {
+	drifting := []ApplicationDrift{}
+	for _, drift := range report {
+		if drift.IsDrifting() {
+			drifting = append(drifting, drift)
+		}
+	}
+	return drifting
+}
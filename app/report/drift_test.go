@@ -0,0 +1,115 @@
+package report_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/report"
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+func newTestDB(t *testing.T, name string, records ...models.Record) *gorm.DB {
+	gdb, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Record{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if err := gdb.Create(&records[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gdb
+}
+
+func Test_Drift(t *testing.T) {
+	gdb := newTestDB(t, "Test_Drift",
+		models.Record{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.7.0"},
+		models.Record{ClusterName: "cluster-c", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		models.Record{ClusterName: "cluster-d", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.0"},
+		models.Record{ClusterName: "cluster-a", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.5"},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "ingress-nginx", ApplicationVersion: "4.2.5"},
+	)
+
+	drift, err := report.Drift(context.Background(), gdb, nil)
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	if len(drift) != 2 {
+		t.Fatalf("expected 2 applications, got %d", len(drift))
+	}
+
+	certManager := drift[0]
+	if certManager.ApplicationName != "cert-manager" || certManager.NewestVersion != "v1.9.1" || certManager.OldestVersion != "v1.7.0" {
+		t.Errorf("unexpected summary %+v", certManager)
+	}
+	if certManager.Spread != (version.Gap{Level: version.LevelMinor, Behind: 2}) {
+		t.Errorf("expected spread of 2 minor versions, got %+v", certManager.Spread)
+	}
+	wantVersions := []report.VersionGroup{
+		{Version: "v1.9.1", Clusters: []string{"cluster-a", "cluster-c"}},
+		{Version: "v1.9.0", Clusters: []string{"cluster-d"}},
+		{Version: "v1.7.0", Clusters: []string{"cluster-b"}},
+	}
+	if !reflect.DeepEqual(certManager.Versions, wantVersions) {
+		t.Errorf("expected versions %+v, got %+v", wantVersions, certManager.Versions)
+	}
+	if len(certManager.Laggards) != 2 || certManager.Laggards[0].ClusterName != "cluster-b" || certManager.Laggards[1].ClusterName != "cluster-d" {
+		t.Errorf("expected cluster-b and cluster-d as laggards, got %+v", certManager.Laggards)
+	}
+
+	if drift[1].IsDrifting() || len(drift[1].Laggards) != 0 {
+		t.Errorf("expected ingress-nginx to run in a single version, got %+v", drift[1])
+	}
+	if drifting := report.OnlyDrifting(drift); len(drifting) != 1 {
+		t.Errorf("expected a single drifting application, got %d", len(drifting))
+	}
+
+	drift, err = report.Drift(context.Background(), gdb, []string{"ingress-nginx"})
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	if len(drift) != 1 || drift[0].ApplicationName != "ingress-nginx" {
+		t.Errorf("expected report for ingress-nginx only, got %+v", drift)
+	}
+}
+
+func Test_DriftUnparseableVersions(t *testing.T) {
+	gdb := newTestDB(t, "Test_DriftUnparseableVersions",
+		models.Record{ClusterName: "cluster-a", ApplicationName: "redis", ApplicationVersion: "latest"},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "redis", ApplicationVersion: "7.0.10"},
+		models.Record{ClusterName: "cluster-c", ApplicationName: "redis", ApplicationVersion: "3f2a9c1"},
+		models.Record{ClusterName: "cluster-d", ApplicationName: "redis", ApplicationVersion: "7.0.9"},
+		models.Record{ClusterName: "cluster-e", ApplicationName: "redis", ApplicationVersion: "10.0.0"},
+		models.Record{ClusterName: "cluster-f", ApplicationName: "redis", ApplicationVersion: "6.2.0"},
+	)
+
+	drift, err := report.Drift(context.Background(), gdb, nil)
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	versions := []string{}
+	for _, group := range drift[0].Versions {
+		versions = append(versions, group.Version)
+	}
+	wantVersions := []string{"10.0.0", "7.0.10", "7.0.9", "6.2.0", "3f2a9c1", "latest"}
+	if !reflect.DeepEqual(versions, wantVersions) {
+		t.Errorf("expected versions %v, got %v", wantVersions, versions)
+	}
+	laggards := []string{}
+	for _, laggard := range drift[0].Laggards {
+		laggards = append(laggards, laggard.ApplicationVersion)
+	}
+	wantLaggards := []string{"6.2.0", "7.0.9", "7.0.10"}
+	if !reflect.DeepEqual(laggards, wantLaggards) {
+		t.Errorf("expected laggards %v, got %v", wantLaggards, laggards)
+	}
+}
@@ -0,0 +1,63 @@
+package report
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+)
+
+// Expiring is a record whose version is out of support or soon will be
+type Expiring struct {
+	ClusterName         string `json:"cluster_name"`
+	InstanceId          string `json:"instance_id"`
+	ApplicationName     string `json:"application_name"`
+	ApplicationInstance string `json:"application_instance"`
+	ApplicationVersion  string `json:"application_version"`
+	lifecycle.Support
+}
+
+// EndOfLife builds the report of records that reached their end of life or reach it within some days
+//
+// Records are sorted by their end of life, records that are known to be out
+// of support without a date come first.
+func EndOfLife(ctx context.Context, db *gorm.DB, checker *lifecycle.Checker, within int, now time.Time) ([]Expiring, error) {
+	records := []models.Record{}
+	if err := db.WithContext(ctx).Order("application_name").Order("cluster_name").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	supports, err := checker.Check(ctx, records, now)
+	if err != nil {
+		return nil, err
+	}
+
+	report := []Expiring{}
+	for i, record := range records {
+		support := supports[i]
+		ending := support.DaysUntilEOL != nil && *support.DaysUntilEOL <= within
+		if support.Status != lifecycle.StatusEOL && !ending {
+			continue
+		}
+		report = append(report, Expiring{
+			ClusterName:         record.ClusterName,
+			InstanceId:          record.InstanceId,
+			ApplicationName:     record.ApplicationName,
+			ApplicationInstance: record.ApplicationInstance,
+			ApplicationVersion:  record.ApplicationVersion,
+			Support:             support,
+		})
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i].DaysUntilEOL, report[j].DaysUntilEOL
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return *a < *b
+	})
+	return report, nil
+}
@@ -0,0 +1,72 @@
+package report_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adfinis-sygroup/mopsos/app/lifecycle"
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/report"
+)
+
+func Test_EndOfLife(t *testing.T) {
+	gdb := newTestDB(t, "Test_EndOfLife",
+		models.Record{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.10.0"},
+		models.Record{ClusterName: "cluster-b", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		models.Record{ClusterName: "cluster-c", ApplicationName: "cert-manager", ApplicationVersion: "v1.8.0"},
+		models.Record{ClusterName: "cluster-a", ApplicationName: "postgres", ApplicationVersion: "14.5"},
+	)
+	if err := gdb.AutoMigrate(&models.ProductCycle{}); err != nil {
+		t.Fatal(err)
+	}
+	err := lifecycle.Import(context.Background(), gdb, map[string][]lifecycle.Cycle{
+		"cert-manager": {
+			{Cycle: "1.10", EOL: lifecycle.EOL{Date: date(2023, 6, 14)}},
+			{Cycle: "1.9", EOL: lifecycle.EOL{Date: date(2023, 2, 9)}},
+			{Cycle: "1.8", EOL: lifecycle.EOL{Ended: true}},
+		},
+		"postgresql": {
+			{Cycle: "14", EOL: lifecycle.EOL{Date: date(2022, 11, 12)}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := lifecycle.NewChecker(gdb, map[string]string{"postgres": "postgresql"})
+	now := time.Date(2023, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		within int
+		want   []string
+	}{
+		{name: "already eol", within: 0, want: []string{"cluster-c/cert-manager", "cluster-a/postgres"}},
+		{name: "within 30 days", within: 30, want: []string{"cluster-c/cert-manager", "cluster-a/postgres", "cluster-b/cert-manager"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiring, err := report.EndOfLife(context.Background(), gdb, checker, tt.within, now)
+			if err != nil {
+				t.Fatalf("EndOfLife() error = %v", err)
+			}
+			got := []string{}
+			for _, e := range expiring {
+				got = append(got, e.ClusterName+"/"+e.ApplicationName)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func date(year int, month time.Month, day int) *time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &t
+}
@@ -1,60 +1,476 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/cloudevents/sdk-go/v2/event"
 	http_logrus "github.com/improbable-eng/go-httpwares/logging/logrus"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"gorm.io/gorm"
 
+	"github.com/adfinis-sygroup/mopsos/app/certs"
+	"github.com/adfinis-sygroup/mopsos/app/clusters"
+	"github.com/adfinis-sygroup/mopsos/app/compliance"
+	"github.com/adfinis-sygroup/mopsos/app/metrics"
 	"github.com/adfinis-sygroup/mopsos/app/middleware"
 	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/oidc"
+	"github.com/adfinis-sygroup/mopsos/app/osv"
+	"github.com/adfinis-sygroup/mopsos/app/queue"
+	"github.com/adfinis-sygroup/mopsos/app/ratelimit"
+	"github.com/adfinis-sygroup/mopsos/app/rbac"
 	"github.com/adfinis-sygroup/mopsos/app/types"
 )
 
+// tlsReloadInterval is how often the TLS files are checked for changes
+const tlsReloadInterval = 10 * time.Second
+
+// snapshotEventType is the type of the events snapshots are queued with
+const snapshotEventType = "cloud.adfinis.mopsos.snapshot"
+
 // Server is the main webserver struct
 type Server struct {
-	config *Config
+	config   *Config
+	database *gorm.DB
+	handler  *Handler
+	clusters *clusters.Store
+
+	compliance *compliance.Engine
+	// limiter charges clusters for the events of batches and snapshots, it is created on Start
+	limiter *ratelimit.Limiter
+
+	eventTypes middleware.EventTypes
 
-	EventChan chan<- models.EventData
+	httpServer *http.Server
+
+	Queue queue.Queue
 }
 
 // NewServer creates a server that receives CloudEvents from the network
 func NewServer(cfg *Config) *Server {
 	return &Server{
-		config: cfg,
+		config:     cfg,
+		httpServer: &http.Server{Addr: cfg.HttpListener},
 	}
 }
 
 // Start starts the server and listens for incoming events
-func (s *Server) Start() {
+//
+// It blocks until the server fails or is stopped with Shutdown.
+func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.HandleHealthCheck)
-	mux.Handle("/webhook", otelhttp.NewHandler(
-		middleware.Authenticate(
-			middleware.LoadEvent(
-				middleware.Validate(
-					http.HandlerFunc(s.HandleWebhook),
+	authenticators, err := s.webhookAuthenticators()
+	if err != nil {
+		return err
+	}
+	policy, err := s.policy()
+	if err != nil {
+		return err
+	}
+	if err := s.checkReadAccess(policy); err != nil {
+		return err
+	}
+	mux.Handle("/metrics", s.metricsHandler(policy))
+	if s.limiter, err = s.rateLimiter(); err != nil {
+		return err
+	}
+	if s.eventTypes, err = middleware.ParseEventTypes(s.config.EventTypes); err != nil {
+		return err
+	}
+	// ingest protects the endpoints clusters send their applications to
+	ingest := func(next http.Handler) http.Handler {
+		return middleware.LimitConcurrency(
+			middleware.LimitBody(
+				middleware.AuthenticateEvents(
+					middleware.Authorize(
+						middleware.RateLimit(next, s.limiter),
+						policy, rbac.RoleIngest,
+					),
+					authenticators...,
 				),
-				s.config.EnableTracing,
+				s.config.WebhookMaxBodySize,
 			),
-			s.config.BasicAuthUsers,
-		),
-		"webhook-receiver"),
-	)
+			s.config.WebhookMaxConcurrency,
+		)
+	}
+	mux.Handle("/webhook", otelhttp.NewHandler(ingest(s.webhookReceiver()), "webhook-receiver"))
+	mux.Handle("/webhook/snapshot", otelhttp.NewHandler(ingest(http.HandlerFunc(s.HandleSnapshot)), "webhook-snapshot"))
+	mux.Handle("/api/v1/records", otelhttp.NewHandler(s.readAccess(s.HandleListRecords, policy), "api-list-records"))
+	mux.Handle("/api/v1/records/", otelhttp.NewHandler(s.readAccess(s.HandleGetRecord, policy), "api-get-record"))
+	mux.Handle("/api/v1/history", otelhttp.NewHandler(s.readAccess(s.HandleListHistory, policy), "api-list-history"))
+	mux.Handle("/api/v1/upstream", otelhttp.NewHandler(s.readAccess(s.HandleListUpstream, policy), "api-list-upstream"))
+	mux.Handle("/api/v1/outdated", otelhttp.NewHandler(s.readAccess(s.HandleListOutdated, policy), "api-list-outdated"))
+	mux.Handle("/api/v1/images", otelhttp.NewHandler(s.readAccess(s.HandleListImages, policy), "api-list-images"))
+	mux.Handle("/api/v1/affected", otelhttp.NewHandler(s.readAccess(s.HandleListAffected, policy), "api-list-affected"))
+	mux.Handle("/api/v1/gaps", otelhttp.NewHandler(s.readAccess(s.HandleListGaps, policy), "api-list-gaps"))
+	mux.Handle("/api/v1/lifecycle", otelhttp.NewHandler(s.readAccess(s.HandleListLifecycle, policy), "api-list-lifecycle"))
+	mux.Handle("/api/v1/reports/eol", otelhttp.NewHandler(s.readAccess(s.HandleEOLReport, policy), "api-report-eol"))
+	mux.Handle("/api/v1/reports/drift", otelhttp.NewHandler(s.readAccess(s.HandleDriftReport, policy), "api-report-drift"))
+	mux.Handle("/api/v1/reports/compliance", otelhttp.NewHandler(s.readAccess(s.HandleComplianceReport, policy), "api-report-compliance"))
+
+	// the admin api is only available if there are admin users
+	if len(s.config.AdminUsers) > 0 {
+		mux.Handle("/api/v1/admin/deadletters", otelhttp.NewHandler(s.adminAccess(s.HandleListDeadLetters, policy), "api-admin-list-deadletters"))
+		mux.Handle("/api/v1/admin/deadletters/", otelhttp.NewHandler(s.adminAccess(s.HandleReplayDeadLetter, policy), "api-admin-replay-deadletter"))
+		mux.Handle("/api/v1/admin/clusters", otelhttp.NewHandler(s.adminAccess(s.HandleClusters, policy), "api-admin-clusters"))
+		mux.Handle("/api/v1/admin/clusters/", otelhttp.NewHandler(s.adminAccess(s.HandleCluster, policy), "api-admin-cluster"))
+		if s.compliance != nil {
+			mux.Handle("/api/v1/admin/rules", otelhttp.NewHandler(s.adminAccess(s.HandleRules, policy), "api-admin-rules"))
+			mux.Handle("/api/v1/admin/rules/", otelhttp.NewHandler(s.adminAccess(s.HandleRule, policy), "api-admin-rule"))
+		}
+	}
 
 	logrus.WithField("listener", s.config.HttpListener).Info("Starting server")
 	loggingMiddleware := http_logrus.Middleware(
 		logrus.WithFields(logrus.Fields{}),
 	)(mux)
-	logrus.Fatal(http.ListenAndServe(s.config.HttpListener, loggingMiddleware))
+	s.httpServer.Handler = loggingMiddleware
+
+	if s.config.TLSCertFile == "" {
+		err = s.httpServer.ListenAndServe()
+	} else {
+		if s.httpServer.TLSConfig, err = s.tlsConfig(); err != nil {
+			return err
+		}
+		err = s.httpServer.ListenAndServeTLS("", "")
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// tlsConfig loads the TLS certificate and the CA for client certificates, both are reloaded when they change
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var clientAuth tls.ClientAuthType
+	switch s.config.TLSClientAuth {
+	case "", "optional":
+		// clients without certificate may still use other authenticators
+		clientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid TLS client auth %q, expected 'optional' or 'require'", s.config.TLSClientAuth)
+	}
+
+	reloader, err := certs.NewReloader(s.config.TLSCertFile, s.config.TLSKeyFile, s.config.TLSClientCAFile, tlsReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	return reloader.TLSConfig(clientAuth), nil
+}
+
+// Shutdown stops accepting requests and waits for running requests to finish
+//
+// Webhooks that are still running can put their event on the queue, so the
+// queue should only be closed once Shutdown returns.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
 
-// WithEventChannel sets the event channel for the server
-func (s *Server) WithEventChannel(eventChan chan<- models.EventData) *Server {
-	s.EventChan = eventChan
+// webhookAuthenticators builds the configured authenticators of the webhook, basic auth by default
+func (s *Server) webhookAuthenticators() ([]middleware.Authenticator, error) {
+	names := s.config.WebhookAuth
+	if len(names) == 0 {
+		names = []string{"basic"}
+	}
+
+	authenticators := make([]middleware.Authenticator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "basic":
+			// clusters are either configured statically or managed in the database
+			verifiers := []middleware.Verifier{middleware.StaticUsers(s.config.BasicAuthUsers)}
+			if s.clusters != nil {
+				verifiers = append(verifiers, s.clusters.Verify)
+			}
+			authenticators = append(authenticators, middleware.NewBasicAuth(verifiers...))
+		case "hmac":
+			if s.config.HMACMaxSkew <= 0 {
+				return nil, fmt.Errorf("the hmac webhook authenticator requires a max skew greater than 0, got %s", s.config.HMACMaxSkew)
+			}
+			authenticators = append(authenticators, middleware.NewHMAC(s.config.HMACSecrets, s.config.HMACMaxSkew))
+		case "mtls":
+			if s.config.TLSCertFile == "" || s.config.TLSClientCAFile == "" {
+				return nil, errors.New("the mtls webhook authenticator requires TLS with a client CA")
+			}
+			authenticators = append(authenticators, middleware.NewClientCert(s.config.TLSClientMapping))
+		case "jwt":
+			if len(s.config.JWTIssuers) == 0 {
+				return nil, errors.New("the jwt webhook authenticator requires at least one issuer")
+			}
+			verifier := oidc.NewVerifier(s.config.JWTIssuers, s.config.JWTAudiences, s.config.JWTClusterClaim, s.config.JWTClusterMapping)
+			authenticators = append(authenticators, middleware.NewBearer(verifier))
+		default:
+			return nil, fmt.Errorf("unknown webhook authenticator %q, expected 'basic', 'hmac', 'mtls' or 'jwt'", name)
+		}
+	}
+	return authenticators, nil
+}
+
+// HandleSnapshot replaces the records of the authenticated cluster with the records in the request body
+//
+// Records may leave the cluster name and, if the snapshot is limited to an
+// instance, the instance id empty. Records of other clusters or instances
+// are rejected.
+func (s *Server) HandleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	username := r.Context().Value(types.ContextUsername).(string)
+
+	snapshot := models.Snapshot{}
+	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+		http.Error(w, "failed to decode snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if snapshot.ClusterName == "" {
+		snapshot.ClusterName = username
+	}
+	if snapshot.ClusterName != username {
+		metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+		http.Error(w, middleware.ErrClusterMismatch.Error(), http.StatusUnauthorized)
+		return
+	}
+	// the request was charged for one event already
+	if !middleware.LimitEvents(w, s.limiter, username, len(snapshot.Records)-1) {
+		return
+	}
+	for i := range snapshot.Records {
+		if err := completeSnapshotRecord(&snapshot, &snapshot.Records[i]); err != nil {
+			metrics.EventsRejected.WithLabelValues(metrics.ReasonValidate).Inc()
+			http.Error(w, fmt.Sprintf("record %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// the snapshot is queued like events so the events queued before it are stored first
+	e, err := snapshotEvent(snapshot.ClusterName)
+	if err != nil {
+		logrus.WithError(err).Error("failed to create snapshot event")
+		http.Error(w, "failed to enqueue snapshot", http.StatusInternalServerError)
+		return
+	}
+	outcome, cancel := s.handler.awaitSnapshot(e.ID())
+	defer cancel()
+	err = s.Queue.Enqueue(models.EventData{
+		Event:    e,
+		Record:   models.Record{ClusterName: snapshot.ClusterName, InstanceId: snapshot.InstanceId},
+		Action:   models.ActionSnapshot,
+		Snapshot: &snapshot,
+	})
+	if err != nil {
+		logrus.WithError(err).Error("failed to enqueue snapshot")
+		http.Error(w, "failed to enqueue snapshot", http.StatusServiceUnavailable)
+		return
+	}
+	metrics.QueueDepth.Set(float64(s.Queue.Len()))
+
+	select {
+	case o := <-outcome:
+		if o.err != nil {
+			http.Error(w, "failed to apply snapshot", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, o.result)
+	case <-r.Context().Done():
+		// the snapshot stays on the queue and is applied anyway
+		http.Error(w, "snapshot is queued but not applied yet", http.StatusAccepted)
+	}
+}
+
+// snapshotEvent creates the event a snapshot is queued with
+func snapshotEvent(cluster string) (event.Event, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return event.Event{}, err
+	}
+	e := event.New()
+	e.SetID(hex.EncodeToString(id))
+	e.SetType(snapshotEventType)
+	e.SetSource("/webhook/snapshot")
+	e.SetSubject(cluster)
+	e.SetTime(time.Now())
+	return e, nil
+}
+
+// completeSnapshotRecord fills in the cluster and instance of a record and checks that it belongs to the snapshot and is complete
+func completeSnapshotRecord(snapshot *models.Snapshot, record *models.Record) error {
+	if record.ClusterName == "" {
+		record.ClusterName = snapshot.ClusterName
+	}
+	if record.ClusterName != snapshot.ClusterName {
+		return errors.New("record belongs to another cluster")
+	}
+	if snapshot.InstanceId != "" {
+		if record.InstanceId == "" {
+			record.InstanceId = snapshot.InstanceId
+		}
+		if record.InstanceId != snapshot.InstanceId {
+			return errors.New("record belongs to another instance")
+		}
+	}
+	if record.ApplicationName == "" {
+		return errors.New("application_name is required")
+	}
+	// snapshot records are stored as they are, so they need a version to be compared with
+	if record.ApplicationVersion == "" {
+		return errors.New("application_version is required")
+	}
+	return nil
+}
+
+// webhookReceiver passes batches to HandleWebhookBatch and single events through LoadEvent and Validate to HandleWebhook
+func (s *Server) webhookReceiver() http.Handler {
+	single := middleware.LoadEvent(
+		middleware.Validate(
+			http.HandlerFunc(s.HandleWebhook),
+			s.eventTypes,
+		),
+		s.config.EnableTracing,
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isBatch(r) {
+			s.HandleWebhookBatch(w, r)
+			return
+		}
+		single.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter creates the limiter of the webhook, without limits all events are allowed
+func (s *Server) rateLimiter() (*ratelimit.Limiter, error) {
+	limit := ratelimit.Limit{}
+	if s.config.WebhookRateLimit != "" {
+		var err error
+		if limit, err = ratelimit.ParseLimit(s.config.WebhookRateLimit); err != nil {
+			return nil, err
+		}
+	}
+	overrides := make(map[string]ratelimit.Limit, len(s.config.WebhookRateLimits))
+	for cluster, value := range s.config.WebhookRateLimits {
+		override, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit of cluster %s: %w", cluster, err)
+		}
+		overrides[cluster] = override
+	}
+	return ratelimit.NewLimiter(limit, overrides), nil
+}
+
+// policy loads the RBAC policy, without RBAC file users only have the role of their credentials
+func (s *Server) policy() (*rbac.Policy, error) {
+	if s.config.RBACFile == "" {
+		return rbac.NewPolicy(nil)
+	}
+	return rbac.Load(s.config.RBACFile)
+}
+
+// checkReadAccess makes sure the read API is either public on purpose or readable by at least one user
+//
+// Reader and admin users may read unless the policy takes their reader role
+// away, a policy taking it from all of them would lock everyone out.
+func (s *Server) checkReadAccess(policy *rbac.Policy) error {
+	if s.config.APIPublic {
+		if len(s.config.ReaderUsers) > 0 || policy.Len() > 0 {
+			return errors.New("the public read API can't be combined with reader users or an RBAC file")
+		}
+		return nil
+	}
+	for _, users := range []map[string]string{s.config.ReaderUsers, s.config.AdminUsers} {
+		for name := range users {
+			if policy.Identity(name, rbac.RoleReader).Has(rbac.RoleReader) {
+				return nil
+			}
+		}
+	}
+	if policy.Len() > 0 {
+		return errors.New("the RBAC file doesn't let any reader or admin user read, make the read API public to leave it open")
+	}
+	return errors.New("the read API has no reader or admin users, make it public to leave it open")
+}
+
+// readAccess protects an endpoint of the read API
+//
+// The read API is only open if it is public, otherwise it requires a reader
+// or admin user.
+func (s *Server) readAccess(next http.HandlerFunc, policy *rbac.Policy) http.Handler {
+	if s.config.APIPublic {
+		return next
+	}
+	return middleware.AuthenticateWith(
+		middleware.Authorize(next, policy, rbac.RoleReader),
+		middleware.NewBasicAuth(middleware.StaticUsers(s.config.ReaderUsers), middleware.StaticUsers(s.config.AdminUsers)),
+	)
+}
+
+// metricsHandler serves the process metrics and, if enabled, the inventory of all clusters
+//
+// The inventory isn't scoped, so readers limited to some clusters may not scrape it.
+func (s *Server) metricsHandler(policy *rbac.Policy) http.Handler {
+	if !s.config.MetricsInventory {
+		return metrics.Handler()
+	}
+	handler := metrics.Handler(
+		metrics.NewInventoryCollector(s.database),
+		metrics.NewVulnerabilityCollector(s.database, osv.NewMatcher(s.database, s.config.OSVNameMapping, s.config.OSVDefaultEcosystem)),
+		metrics.NewComplianceCollector(s.database),
+	)
+	return s.readAccess(func(w http.ResponseWriter, r *http.Request) {
+		if identity, ok := r.Context().Value(types.ContextIdentity).(*rbac.Identity); ok && identity.Scoped() {
+			http.Error(w, "the inventory metrics require read access to all clusters", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}, policy)
+}
+
+// adminAccess protects an endpoint of the admin API
+func (s *Server) adminAccess(next http.HandlerFunc, policy *rbac.Policy) http.Handler {
+	return middleware.AuthenticateWith(
+		middleware.Authorize(next, policy, rbac.RoleAdmin),
+		middleware.NewBasicAuth(middleware.StaticUsers(s.config.AdminUsers)),
+	)
+}
+
+// WithQueue sets the queue the server puts received events on
+func (s *Server) WithQueue(q queue.Queue) *Server {
+	s.Queue = q
+	return s
+}
+
+// WithDatabase sets the database the server reads records from
+func (s *Server) WithDatabase(db *gorm.DB) *Server {
+	s.database = db
+	return s
+}
+
+// WithClusters sets the store of clusters that may send events and are managed by the admin API
+func (s *Server) WithClusters(store *clusters.Store) *Server {
+	s.clusters = store
+	return s
+}
+
+// WithCompliance sets the engine evaluating the compliance rules managed by the admin API
+func (s *Server) WithCompliance(engine *compliance.Engine) *Server {
+	s.compliance = engine
+	return s
+}
+
+// WithHandler sets the handler used to replay dead-lettered events
+func (s *Server) WithHandler(h *Handler) *Server {
+	s.handler = h
 	return s
 }
 
@@ -70,12 +486,21 @@ func (s *Server) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	// get middleware data from context
 	event := r.Context().Value(types.ContextEvent).(*event.Event)
 	record := r.Context().Value(types.ContextRecord).(*models.Record)
+	action, _ := r.Context().Value(types.ContextAction).(models.EventAction)
 
-	// send the event to the main app via the async channel
-	s.EventChan <- models.EventData{
+	// send the event to the main app via the queue
+	err := s.Queue.Enqueue(models.EventData{
 		Event:  *event,
 		Record: *record,
+		Action: action,
+	})
+	if err != nil {
+		logrus.WithError(err).Error("failed to enqueue event")
+		http.Error(w, "failed to enqueue event", http.StatusServiceUnavailable)
+		return
 	}
+	metrics.EventsAccepted.Inc()
+	metrics.QueueDepth.Set(float64(s.Queue.Len()))
 	// return 202 accepted once the event is on the queue
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -1,17 +1,28 @@
 package app_test
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"gorm.io/gorm"
 
 	mopsos "github.com/adfinis-sygroup/mopsos/app"
 	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/queue"
+	"github.com/adfinis-sygroup/mopsos/app/types"
 )
 
-func newApp() (*mopsos.App, *gorm.DB, chan models.EventData) {
+func newApp() (*mopsos.App, *gorm.DB, queue.Queue) {
 	dbMock := &gorm.DB{}
 	a, _ := mopsos.NewApp(&mopsos.Config{
 		HttpListener:  ":8080",
@@ -23,18 +34,18 @@ func newApp() (*mopsos.App, *gorm.DB, chan models.EventData) {
 		},
 	}, dbMock)
 
-	eventChan := make(chan models.EventData)
-	a.Server.WithEventChannel(eventChan)
+	q := queue.NewMemoryQueue(1)
+	a.Server.WithQueue(q)
 
-	return a, dbMock, eventChan
+	return a, dbMock, q
 }
 
-func Test_ServerWithEventChannel(t *testing.T) {
-	a, _, eventChan := newApp()
+func Test_ServerWithQueue(t *testing.T) {
+	a, _, q := newApp()
 
-	a.Server.WithEventChannel(eventChan)
-	if a.Server.EventChan != eventChan {
-		t.Errorf("event channel not set")
+	a.Server.WithQueue(q)
+	if a.Server.Queue != q {
+		t.Errorf("queue not set")
 	}
 }
 
@@ -54,3 +65,340 @@ func Test_HandleHealthCheck(t *testing.T) {
 		t.Errorf("body should be %v got %s", expected, res.Body.String())
 	}
 }
+
+func Test_HandleWebhook(t *testing.T) {
+	a, _, q := newApp()
+
+	evt := cloudevents.NewEvent()
+	record := &models.Record{ClusterName: "username"}
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil)
+	ctx := context.WithValue(req.Context(), types.ContextEvent, &evt)
+	ctx = context.WithValue(ctx, types.ContextRecord, record)
+
+	res := httptest.NewRecorder()
+	a.Server.HandleWebhook(res, req.WithContext(ctx))
+	if res.Code != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", res.Code)
+	}
+	if q.Len() != 1 {
+		t.Errorf("expected event on the queue")
+	}
+
+	q.Close()
+	res = httptest.NewRecorder()
+	a.Server.HandleWebhook(res, req.WithContext(ctx))
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 once the queue is closed, got %d", res.Code)
+	}
+}
+
+func Test_ServerStartRejectsInvalidAuthConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config mopsos.Config
+	}{
+		{name: "unknown authenticator", config: mopsos.Config{WebhookAuth: []string{"oauth"}}},
+		{name: "mtls without tls", config: mopsos.Config{WebhookAuth: []string{"mtls"}}},
+		{name: "jwt without issuers", config: mopsos.Config{WebhookAuth: []string{"jwt"}}},
+		{name: "hmac without max skew", config: mopsos.Config{WebhookAuth: []string{"hmac"}, HMACSecrets: map[string]string{"cluster": "secret"}}},
+		{name: "invalid rate limit", config: mopsos.Config{WebhookRateLimits: map[string]string{"cluster": "fast"}}},
+		{name: "invalid client auth", config: mopsos.Config{TLSCertFile: "tls.crt", TLSClientAuth: "always"}},
+		{name: "missing certificate", config: mopsos.Config{TLSCertFile: "missing.crt", TLSKeyFile: "missing.key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.HttpListener = "127.0.0.1:0"
+			tt.config.APIPublic = true
+			if err := mopsos.NewServer(&tt.config).Start(); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func Test_ServerStartRejectsInvalidReadAccess(t *testing.T) {
+	rbacFile := filepath.Join(t.TempDir(), "rbac.yaml")
+	policy := "identities:\n  - name: reader\n    roles: []\n  - name: admin\n    roles: [ingest]\n"
+	if err := os.WriteFile(rbacFile, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config mopsos.Config
+	}{
+		{name: "no users", config: mopsos.Config{}},
+		{name: "only clusters", config: mopsos.Config{BasicAuthUsers: map[string]string{"cluster": "token"}}},
+		{name: "policy without readers", config: mopsos.Config{
+			ReaderUsers: map[string]string{"reader": "token"},
+			AdminUsers:  map[string]string{"admin": "token"},
+			RBACFile:    rbacFile,
+		}},
+		{name: "public with reader users", config: mopsos.Config{APIPublic: true, ReaderUsers: map[string]string{"reader": "token"}}},
+		{name: "public with policy", config: mopsos.Config{APIPublic: true, RBACFile: rbacFile}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.HttpListener = "127.0.0.1:0"
+			if err := mopsos.NewServer(&tt.config).Start(); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func Test_HandleWebhookBatch(t *testing.T) {
+	event := func(id string, cluster string) string {
+		return `{"specversion":"1.0","id":"` + id + `","source":"argocd","type":"cloud.adfinis.mopsos.updateRecord",` +
+			`"datacontenttype":"application/json","data":{"cluster_name":"` + cluster + `","application_name":"app-` + id + `"}}`
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantStatuses []int
+		wantQueued   int
+	}{
+		{
+			name:         "all accepted",
+			body:         "[" + event("1", "username") + "," + event("2", "username") + "]",
+			wantStatus:   http.StatusAccepted,
+			wantStatuses: []int{http.StatusAccepted, http.StatusAccepted},
+			wantQueued:   2,
+		},
+		{
+			name:         "partially accepted",
+			body:         "[" + event("1", "username") + "," + event("2", "other") + `,{"specversion":"1.0"},"event"]`,
+			wantStatus:   http.StatusMultiStatus,
+			wantStatuses: []int{http.StatusAccepted, http.StatusUnauthorized, http.StatusBadRequest, http.StatusBadRequest},
+			wantQueued:   1,
+		},
+		{
+			name:         "none accepted",
+			body:         "[" + event("1", "other") + "]",
+			wantStatus:   http.StatusBadRequest,
+			wantStatuses: []int{http.StatusUnauthorized},
+		},
+		{
+			name:       "empty batch",
+			body:       "[]",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not a batch",
+			body:       event("1", "username"),
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := queue.NewMemoryQueue(0)
+			s := mopsos.NewServer(&mopsos.Config{}).WithQueue(q)
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/cloudevents-batch+json; charset=utf-8")
+			req = req.WithContext(context.WithValue(req.Context(), types.ContextUsername, "username"))
+			res := httptest.NewRecorder()
+
+			s.HandleWebhookBatch(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+			if q.Len() != tt.wantQueued {
+				t.Errorf("expected %d queued events, got %d", tt.wantQueued, q.Len())
+			}
+			if tt.wantStatuses == nil {
+				return
+			}
+			response := mopsos.BatchResponse{}
+			if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(response.Results) != len(tt.wantStatuses) {
+				t.Fatalf("expected %d results, got %+v", len(tt.wantStatuses), response.Results)
+			}
+			for i, result := range response.Results {
+				if result.Index != i || result.Status != tt.wantStatuses[i] {
+					t.Errorf("expected result %d to have status %d, got %+v", i, tt.wantStatuses[i], result)
+				}
+			}
+			if response.Accepted != tt.wantQueued || response.Accepted+response.Rejected != len(tt.wantStatuses) {
+				t.Errorf("unexpected counts in %+v", response)
+			}
+		})
+	}
+}
+
+func Test_HandleSnapshot(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleSnapshot")
+	h := mopsos.NewHandler(false, gdb)
+	q := queue.NewMemoryQueue(10)
+	s := mopsos.NewServer(&mopsos.Config{}).WithDatabase(gdb).WithHandler(h).WithQueue(q)
+	handled := make(chan error, 1)
+	go func() { handled <- h.HandleEvents(q) }()
+	defer func() {
+		q.Close()
+		if err := <-handled; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "records of the cluster", body: `{"records":[{"application_name":"cert-manager","application_version":"1.9.1"}]}`, wantStatus: http.StatusOK},
+		{name: "snapshot of another cluster", body: `{"cluster_name":"other","records":[]}`, wantStatus: http.StatusUnauthorized},
+		{name: "record of another cluster", body: `{"records":[{"cluster_name":"other","application_name":"cert-manager"}]}`, wantStatus: http.StatusBadRequest},
+		{name: "record of another instance", body: `{"instance_id":"a","records":[{"instance_id":"b","application_name":"cert-manager"}]}`, wantStatus: http.StatusBadRequest},
+		{name: "record without name", body: `{"records":[{"application_version":"1.0.0"}]}`, wantStatus: http.StatusBadRequest},
+		{name: "record without version", body: `{"records":[{"application_name":"cert-manager","application_version":""}]}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid json", body: `[`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook/snapshot", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), types.ContextUsername, "username"))
+			res := httptest.NewRecorder()
+
+			s.HandleSnapshot(res, req)
+
+			if res.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+		})
+	}
+
+	record := models.Record{}
+	if err := gdb.Where("cluster_name = ?", "username").Take(&record).Error; err != nil {
+		t.Fatalf("expected record of the snapshot to be stored: %v", err)
+	}
+}
+
+func Test_HandleSnapshotAfterQueuedEvents(t *testing.T) {
+	gdb := newTestDB(t, "Test_HandleSnapshotAfterQueuedEvents")
+	// batching holds the event back, the snapshot must still wait for it
+	h := mopsos.NewHandler(false, gdb).WithWorkers(4).WithBatching(10, 50*time.Millisecond)
+	q := queue.NewMemoryQueue(10)
+	s := mopsos.NewServer(&mopsos.Config{}).WithDatabase(gdb).WithHandler(h).WithQueue(q)
+
+	if err := q.Enqueue(eventStub(&models.Record{ClusterName: "username", ApplicationName: "removed", ApplicationVersion: "1.0.0"})); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/webhook/snapshot",
+		strings.NewReader(`{"records":[{"application_name":"cert-manager","application_version":"1.9.1"}]}`))
+	req = req.WithContext(context.WithValue(req.Context(), types.ContextUsername, "username"))
+	res := httptest.NewRecorder()
+	responded := make(chan struct{})
+	go func() {
+		defer close(responded)
+		s.HandleSnapshot(res, req)
+	}()
+	for q.Len() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	handled := make(chan error, 1)
+	go func() { handled <- h.HandleEvents(q) }()
+	<-responded
+	q.Close()
+	if err := <-handled; err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", res.Code, res.Body.String())
+	}
+	result := models.SnapshotResult{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated != 1 || result.Removed != 1 {
+		t.Errorf("expected the queued record to be removed by the snapshot, got %+v", result)
+	}
+	names := []string{}
+	if err := gdb.Model(&models.Record{}).Pluck("application_name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "cert-manager" {
+		t.Errorf("expected only the records of the snapshot, got %v", names)
+	}
+}
+
+func Test_ServerMetricsInventory(t *testing.T) {
+	rbacFile := filepath.Join(t.TempDir(), "rbac.yaml")
+	policy := "identities:\n  - name: team-a\n    roles: [reader]\n    clusters: [cluster-a]\n"
+	if err := os.WriteFile(rbacFile, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		inventory     bool
+		user          string
+		wantStatus    int
+		wantInventory bool
+	}{
+		{name: "disabled", user: "team-a", wantStatus: http.StatusOK},
+		{name: "unauthenticated", inventory: true, wantStatus: http.StatusUnauthorized},
+		{name: "scoped reader", inventory: true, user: "team-a", wantStatus: http.StatusForbidden},
+		{name: "reader of all clusters", inventory: true, user: "ops", wantStatus: http.StatusOK, wantInventory: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := listener.Addr().String()
+			listener.Close()
+
+			s := mopsos.NewServer(&mopsos.Config{
+				HttpListener:     addr,
+				ReaderUsers:      map[string]string{"team-a": "token", "ops": "token"},
+				RBACFile:         rbacFile,
+				MetricsInventory: tt.inventory,
+			}).WithDatabase(newTestDB(t, "Test_ServerMetricsInventory_"+strings.ReplaceAll(tt.name, " ", "_")))
+			started := make(chan error, 1)
+			go func() { started <- s.Start() }()
+			defer func() {
+				if err := s.Shutdown(context.Background()); err != nil {
+					t.Error(err)
+				}
+				if err := <-started; err != nil {
+					t.Error(err)
+				}
+			}()
+
+			var res *http.Response
+			for i := 0; i < 50; i++ {
+				req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/metrics", nil)
+				if tt.user != "" {
+					req.SetBasicAuth(tt.user, "token")
+				}
+				if res, err = http.DefaultClient.Do(req); err == nil {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, res.StatusCode, body)
+			}
+			if got := strings.Contains(string(body), "mopsos_inventory_scrape_error"); got != tt.wantInventory {
+				t.Errorf("expected inventory metrics %v, got %v", tt.wantInventory, got)
+			}
+		})
+	}
+}
@@ -5,3 +5,5 @@ type eventContext string
 var ContextUsername eventContext = "mopsos.username"
 var ContextEvent eventContext = "mopsos.event"
 var ContextRecord eventContext = "mopsos.record"
+var ContextAction eventContext = "mopsos.action"
+var ContextIdentity eventContext = "mopsos.identity"
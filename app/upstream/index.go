@@ -0,0 +1,51 @@
+package upstream
+
+import (
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+// HelmIndex is the subset of a Helm repository index.yaml needed to find the latest releases
+type HelmIndex struct {
+	APIVersion string                    `yaml:"apiVersion"`
+	Entries    map[string][]ChartVersion `yaml:"entries"`
+}
+
+// ChartVersion is a single release of a chart in a Helm repository index
+type ChartVersion struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	AppVersion string `yaml:"appVersion"`
+	Deprecated bool   `yaml:"deprecated"`
+}
+
+// ParseHelmIndex decodes a Helm repository index
+func ParseHelmIndex(r io.Reader) (*HelmIndex, error) {
+	index := &HelmIndex{}
+	if err := yaml.NewDecoder(r).Decode(index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Latest returns the latest stable release of every chart in the index
+func (i *HelmIndex) Latest() map[string]ChartVersion {
+	latest := make(map[string]ChartVersion, len(i.Entries))
+	for name, versions := range i.Entries {
+		var newest *version.Version
+		for _, chartVersion := range versions {
+			parsed, err := version.Parse(chartVersion.Version)
+			if err != nil || chartVersion.Deprecated || parsed.IsPrerelease() {
+				continue
+			}
+			if newest == nil || version.Compare(parsed, newest) > 0 {
+				newest = parsed
+				latest[name] = chartVersion
+			}
+		}
+	}
+	return latest
+}
@@ -0,0 +1,50 @@
+package upstream_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
+)
+
+func Test_ParseHelmIndex(t *testing.T) {
+	file, err := os.Open("testdata/index.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	index, err := upstream.ParseHelmIndex(file)
+	if err != nil {
+		t.Fatalf("ParseHelmIndex() error = %v", err)
+	}
+	if len(index.Entries) != 3 {
+		t.Errorf("expected 3 charts, got %d", len(index.Entries))
+	}
+
+	latest := index.Latest()
+	want := map[string]string{
+		"cert-manager":  "v1.9.1",
+		"ingress-nginx": "4.2.5",
+	}
+	if len(latest) != len(want) {
+		t.Errorf("expected %d latest releases, got %d", len(want), len(latest))
+	}
+	for chart, version := range want {
+		if latest[chart].Version != version {
+			t.Errorf("expected %s to be at %s, got %s", chart, version, latest[chart].Version)
+		}
+	}
+}
+
+func Test_ParseHelmIndexInvalid(t *testing.T) {
+	file, err := os.Open("index_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := upstream.ParseHelmIndex(file); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
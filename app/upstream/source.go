@@ -0,0 +1,44 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// httpClient is used to fetch remote indexes
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Open returns the index.yaml behind a source
+//
+// A source is either a http(s) URL or a path on the local filesystem, a
+// Helm repository URL or a directory is completed with /index.yaml.
+func Open(ctx context.Context, source string) (io.ReadCloser, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		if !strings.HasSuffix(source, ".yaml") && !strings.HasSuffix(source, ".yml") {
+			source = strings.TrimSuffix(source, "/") + "/index.yaml"
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("fetching %s: unexpected status %s", source, res.Status)
+		}
+		return res.Body, nil
+	}
+
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		source = strings.TrimSuffix(source, "/") + "/index.yaml"
+	}
+	return os.Open(source)
+}
@@ -0,0 +1,230 @@
+package upstream
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+// Status describes how a record relates to the latest upstream release
+type Status string
+
+const (
+	StatusCurrent  Status = "current"
+	StatusOutdated Status = "outdated"
+	StatusUnknown  Status = "unknown"
+)
+
+// evaluateBatchSize limits the rows per insert statement, sqlite allows only so many variables
+const evaluateBatchSize = 500
+
+// Tracker periodically reads the configured Helm repository indexes and stores the latest releases
+//
+// Records are compared with the latest release of their chart and the status
+// is stored with the record key, so records can be filtered by it.
+type Tracker struct {
+	database *gorm.DB
+
+	sources []string
+	mapping map[string]string
+}
+
+// NewTracker creates a tracker for a list of index sources and a mapping from application names to charts
+func NewTracker(db *gorm.DB, sources []string, mapping map[string]string) *Tracker {
+	return &Tracker{
+		database: db,
+		sources:  sources,
+		mapping:  mapping,
+	}
+}
+
+// Run refreshes the upstream releases every interval until the context is done
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Refresh(ctx); err != nil {
+			logrus.WithError(err).Error("failed to refresh upstream releases")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh reads all sources, stores the latest release of each chart and updates the status of all records
+//
+// When a chart is available from multiple sources the first source wins.
+// Sources that fail to load are logged and skipped.
+func (t *Tracker) Refresh(ctx context.Context) error {
+	now := time.Now()
+	releases := make(map[string]*models.UpstreamRelease)
+
+	for _, source := range t.sources {
+		log := logrus.WithField("source", source)
+
+		index, err := t.load(ctx, source)
+		if err != nil {
+			log.WithError(err).Error("failed to load helm index")
+			continue
+		}
+		for name, version := range index.Latest() {
+			if _, ok := releases[name]; ok {
+				continue
+			}
+			releases[name] = &models.UpstreamRelease{
+				ChartName:     name,
+				LatestVersion: version.Version,
+				AppVersion:    version.AppVersion,
+				Source:        source,
+				CheckedAt:     now,
+			}
+		}
+		log.WithField("charts", len(index.Entries)).Debug("loaded helm index")
+	}
+
+	if len(releases) == 0 {
+		return nil
+	}
+	rows := make([]*models.UpstreamRelease, 0, len(releases))
+	for _, release := range releases {
+		rows = append(rows, release)
+	}
+	err := t.database.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chart_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"latest_version", "app_version", "source", "checked_at", "updated_at"}),
+	}).Create(&rows).Error
+	if err != nil {
+		return err
+	}
+	return t.EvaluateAll(ctx)
+}
+
+// EvaluateAll replaces the statuses of all records
+func (t *Tracker) EvaluateAll(ctx context.Context) error {
+	return t.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.UpstreamStatus{}).Error; err != nil {
+			return err
+		}
+		records := []models.Record{}
+		if err := tx.Find(&records).Error; err != nil {
+			return err
+		}
+		return t.Evaluate(tx, records)
+	})
+}
+
+// Evaluate replaces the statuses of records within a transaction
+func (t *Tracker) Evaluate(tx *gorm.DB, records []models.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	keys := make([]models.RecordKey, len(records))
+	charts := make([]string, len(records))
+	names := []string{}
+	seen := map[string]bool{}
+	for i, record := range records {
+		keys[i] = record.Key()
+		charts[i] = record.ChartName
+		if charts[i] == "" {
+			charts[i] = ChartName(t.mapping, record.ApplicationName)
+		}
+		if !seen[charts[i]] {
+			seen[charts[i]] = true
+			names = append(names, charts[i])
+		}
+	}
+	if err := t.Remove(tx, keys); err != nil {
+		return err
+	}
+
+	releases := []models.UpstreamRelease{}
+	if err := tx.Where("chart_name IN ?", names).Find(&releases).Error; err != nil {
+		return err
+	}
+	releasesByChart := make(map[string]*models.UpstreamRelease, len(releases))
+	for i := range releases {
+		releasesByChart[releases[i].ChartName] = &releases[i]
+	}
+
+	now := time.Now()
+	statuses := make([]models.UpstreamStatus, len(records))
+	for i, record := range records {
+		statuses[i] = models.UpstreamStatus{
+			EvaluatedAt:         now,
+			ClusterName:         record.ClusterName,
+			InstanceId:          record.InstanceId,
+			ApplicationName:     record.ApplicationName,
+			ApplicationInstance: record.ApplicationInstance,
+			ApplicationVersion:  record.ApplicationVersion,
+			ChartName:           charts[i],
+			Status:              string(StatusOf(record.ApplicationVersion, releasesByChart[charts[i]])),
+		}
+		if release, ok := releasesByChart[charts[i]]; ok {
+			statuses[i].LatestVersion = release.LatestVersion
+		}
+	}
+	return tx.CreateInBatches(statuses, evaluateBatchSize).Error
+}
+
+// Remove deletes the statuses of records within a transaction, i.e. because the records were deleted
+func (t *Tracker) Remove(tx *gorm.DB, keys []models.RecordKey) error {
+	for _, key := range keys {
+		if err := tx.Where(key.Conditions()).Delete(&models.UpstreamStatus{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) load(ctx context.Context, source string) (*HelmIndex, error) {
+	reader, err := Open(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ParseHelmIndex(reader)
+}
+
+// ChartName returns the chart name an application is tracked under
+//
+// The mapping is only needed for applications that are not named after their chart.
+func ChartName(mapping map[string]string, applicationName string) string {
+	if chart, ok := mapping[applicationName]; ok {
+		return chart
+	}
+	return applicationName
+}
+
+// StatusOf compares an installed version with the latest upstream release
+//
+// Installed versions newer than the latest release, i.e. pre-releases of the
+// next version, are current. Versions that can't be compared, like latest or
+// a commit hash, are unknown.
+func StatusOf(installed string, release *models.UpstreamRelease) Status {
+	if release == nil {
+		return StatusUnknown
+	}
+	v, err := version.Parse(installed)
+	if err != nil {
+		return StatusUnknown
+	}
+	latest, err := version.Parse(release.LatestVersion)
+	if err != nil {
+		return StatusUnknown
+	}
+	if version.Compare(v, latest) >= 0 {
+		return StatusCurrent
+	}
+	return StatusOutdated
+}
@@ -0,0 +1,109 @@
+package upstream_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/adfinis-sygroup/mopsos/app/models"
+	"github.com/adfinis-sygroup/mopsos/app/upstream"
+)
+
+func Test_TrackerRefresh(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:Test_TrackerRefresh?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.Record{}, &models.UpstreamRelease{}, &models.UpstreamStatus{}); err != nil {
+		t.Fatal(err)
+	}
+	err = gdb.Create(&[]models.Record{
+		{ClusterName: "cluster-a", ApplicationName: "cert-manager", ApplicationVersion: "v1.9.1"},
+		{ClusterName: "cluster-a", ApplicationName: "nginx", ApplicationVersion: "4.2.5"},
+		{ClusterName: "cluster-a", ApplicationName: "custom-app", ApplicationVersion: "0.1.0"},
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the remote repository serves a newer cert-manager and takes precedence over the local file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charts/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("apiVersion: v1\nentries:\n  cert-manager:\n    - version: v1.10.0\n"))
+	}))
+	defer server.Close()
+
+	tracker := upstream.NewTracker(gdb, []string{server.URL + "/charts", server.URL + "/missing", "testdata"}, map[string]string{"nginx": "ingress-nginx"})
+	for i := 0; i < 2; i++ {
+		if err := tracker.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+	}
+
+	releases := []models.UpstreamRelease{}
+	gdb.Order("chart_name").Find(&releases)
+	if len(releases) != 2 {
+		t.Fatalf("expected 2 releases, got %d", len(releases))
+	}
+	if releases[0].ChartName != "cert-manager" || releases[0].LatestVersion != "v1.10.0" {
+		t.Errorf("expected cert-manager v1.10.0 from the remote repository, got %+v", releases[0])
+	}
+	if releases[1].ChartName != "ingress-nginx" || releases[1].Source != "testdata" {
+		t.Errorf("expected ingress-nginx from the local file, got %+v", releases[1])
+	}
+
+	// the status of every record is stored once after each refresh
+	statuses := []models.UpstreamStatus{}
+	gdb.Order("application_name").Find(&statuses)
+	want := []struct {
+		chart  string
+		status upstream.Status
+	}{
+		{chart: "cert-manager", status: upstream.StatusOutdated},
+		{chart: "custom-app", status: upstream.StatusUnknown},
+		{chart: "ingress-nginx", status: upstream.StatusCurrent},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("expected %d statuses, got %+v", len(want), statuses)
+	}
+	for i, w := range want {
+		if statuses[i].ChartName != w.chart || statuses[i].Status != string(w.status) {
+			t.Errorf("expected %s to be %s, got %+v", w.chart, w.status, statuses[i])
+		}
+	}
+}
+
+func Test_StatusOf(t *testing.T) {
+	release := &models.UpstreamRelease{ChartName: "app", LatestVersion: "1.2.3"}
+
+	if status := upstream.StatusOf("1.2.3", release); status != upstream.StatusCurrent {
+		t.Errorf("expected current, got %s", status)
+	}
+	if status := upstream.StatusOf("v1.3.0-rc.1", release); status != upstream.StatusCurrent {
+		t.Errorf("expected newer pre-release to be current, got %s", status)
+	}
+	if status := upstream.StatusOf("1.2.2", release); status != upstream.StatusOutdated {
+		t.Errorf("expected outdated, got %s", status)
+	}
+	for _, installed := range []string{"latest", "main", "3f2a9c1"} {
+		if status := upstream.StatusOf(installed, release); status != upstream.StatusUnknown {
+			t.Errorf("expected %s to be unknown, got %s", installed, status)
+		}
+	}
+	if status := upstream.StatusOf("1.2.3", &models.UpstreamRelease{ChartName: "app", LatestVersion: "stable"}); status != upstream.StatusUnknown {
+		t.Errorf("expected unknown for an unparseable release, got %s", status)
+	}
+	if status := upstream.StatusOf("1.2.2", nil); status != upstream.StatusUnknown {
+		t.Errorf("expected unknown, got %s", status)
+	}
+	if chart := upstream.ChartName(map[string]string{"app": "chart"}, "app"); chart != "chart" {
+		t.Errorf("expected mapped chart name, got %s", chart)
+	}
+}
@@ -0,0 +1,92 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operators are the comparison operators of constraints, longer operators first so they match before their prefixes
+var operators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// Constraint is a set of version comparisons like ">= 1.9, < 2 || >= 3"
+//
+// Comparisons separated by commas must all hold, alternatives separated by
+// || are combined with or.
+type Constraint struct {
+	alternatives [][]comparison
+
+	original string
+}
+
+// comparison compares a version against a fixed version
+type comparison struct {
+	operator string
+	version  *Version
+}
+
+// ParseConstraint parses a constraint, comparisons without an operator test for equality
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: s}
+	for _, alternative := range strings.Split(s, "||") {
+		comparisons := []comparison{}
+		for _, term := range strings.Split(alternative, ",") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				return nil, fmt.Errorf("invalid constraint %q: empty comparison", s)
+			}
+			operator := "="
+			for _, op := range operators {
+				if strings.HasPrefix(term, op) {
+					operator, term = op, strings.TrimSpace(strings.TrimPrefix(term, op))
+					break
+				}
+			}
+			v, err := Parse(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			comparisons = append(comparisons, comparison{operator: operator, version: v})
+		}
+		c.alternatives = append(c.alternatives, comparisons)
+	}
+	return c, nil
+}
+
+// Check reports whether a version satisfies the constraint
+func (c *Constraint) Check(v *Version) bool {
+	for _, comparisons := range c.alternatives {
+		satisfied := true
+		for _, comparison := range comparisons {
+			if !comparison.check(v) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the constraint the way it was originally written
+func (c *Constraint) String() string {
+	return c.original
+}
+
+func (c comparison) check(v *Version) bool {
+	cmp := Compare(v, c.version)
+	switch c.operator {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	}
+	return cmp == 0
+}
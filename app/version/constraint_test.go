@@ -0,0 +1,43 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+func Test_Constraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+		wantErr    bool
+	}{
+		{constraint: ">= 1.9", version: "v1.9.0", want: true},
+		{constraint: ">=1.9", version: "1.10.2", want: true},
+		{constraint: ">= 1.9", version: "1.8.7"},
+		{constraint: ">= 1.9", version: "1.9.0-rc.1"},
+		{constraint: ">= 1.9, < 2", version: "1.12.0", want: true},
+		{constraint: ">= 1.9, < 2", version: "2.0.0"},
+		{constraint: "< 1.5 || >= 2", version: "2.1.0", want: true},
+		{constraint: "< 1.5 || >= 2", version: "1.7.0"},
+		{constraint: "!= 1.9.1", version: "1.9.1"},
+		{constraint: "1.9.1", version: "v1.9.1", want: true},
+		{constraint: "> latest", wantErr: true},
+		{constraint: ">= 1.9,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := version.ParseConstraint(tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConstraint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := c.Check(version.MustParse(tt.version)); got != tt.want {
+				t.Errorf("Check(%s) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package version
+
+import "fmt"
+
+// Level classifies the gap between two versions
+type Level string
+
+const (
+	LevelNone    Level = "none"
+	LevelPatch   Level = "patch"
+	LevelMinor   Level = "minor"
+	LevelMajor   Level = "major"
+	LevelUnknown Level = "unknown"
+)
+
+// severity orders the levels, unknown gaps are never more severe than a known gap
+var severity = map[Level]int{
+	LevelUnknown: 0,
+	LevelNone:    1,
+	LevelPatch:   2,
+	LevelMinor:   3,
+	LevelMajor:   4,
+}
+
+// ParseLevel parses a level name
+func ParseLevel(s string) (Level, error) {
+	level := Level(s)
+	if _, ok := severity[level]; !ok {
+		return "", fmt.Errorf("invalid level %q, expected one of none, patch, minor, major or unknown", s)
+	}
+	return level, nil
+}
+
+// AtLeast reports whether the level is as severe as other or more
+func (l Level) AtLeast(other Level) bool {
+	return severity[l] >= severity[other]
+}
+
+// Gap describes how far an installed version is behind a newer version
+type Gap struct {
+	Level Level `json:"level"`
+	// Behind is the number of versions behind on the level of the gap,
+	// i.e. 2 for 1.7.0 compared to 1.9.1
+	Behind int `json:"behind"`
+}
+
+// GapBetween classifies how far installed is behind newest
+func GapBetween(installed, newest *Version) Gap {
+	if installed == nil || newest == nil {
+		return Gap{Level: LevelUnknown}
+	}
+	if Compare(installed, newest) >= 0 {
+		return Gap{Level: LevelNone}
+	}
+	switch {
+	case installed.Major != newest.Major:
+		return Gap{Level: LevelMajor, Behind: newest.Major - installed.Major}
+	case installed.Minor != newest.Minor:
+		return Gap{Level: LevelMinor, Behind: newest.Minor - installed.Minor}
+	default:
+		// also covers pre-releases and extra segments of the same patch version
+		return Gap{Level: LevelPatch, Behind: newest.Patch - installed.Patch}
+	}
+}
+
+// Matches reports whether a gap is at least behind versions behind on level
+//
+// Gaps on a more severe level always match, i.e. a major gap matches
+// a query for two minor versions behind. Unknown gaps only match the
+// unknown level and invalid levels match no gap.
+func (g Gap) Matches(level Level, behind int) bool {
+	if _, ok := severity[level]; !ok {
+		return false
+	}
+	if g.Level == LevelUnknown || level == LevelUnknown {
+		return g.Level == level
+	}
+	if g.Level != level {
+		return g.Level.AtLeast(level)
+	}
+	return g.Behind >= behind
+}
+
+// Newest returns the newest of a list of versions
+//
+// Pre-releases are only considered if there is no stable version.
+func Newest(versions []*Version) *Version {
+	var newest, newestPrerelease *Version
+	for _, v := range versions {
+		if v == nil {
+			continue
+		}
+		if v.IsPrerelease() {
+			if newestPrerelease == nil || Compare(v, newestPrerelease) > 0 {
+				newestPrerelease = v
+			}
+			continue
+		}
+		if newest == nil || Compare(v, newest) > 0 {
+			newest = v
+		}
+	}
+	if newest == nil {
+		return newestPrerelease
+	}
+	return newest
+}
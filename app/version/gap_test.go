@@ -0,0 +1,79 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+func Test_GapBetween(t *testing.T) {
+	tests := []struct {
+		installed string
+		newest    string
+		want      version.Gap
+	}{
+		{"1.9.1", "1.9.1", version.Gap{Level: version.LevelNone}},
+		{"1.10.0", "1.9.1", version.Gap{Level: version.LevelNone}},
+		{"1.9.0", "1.9.1", version.Gap{Level: version.LevelPatch, Behind: 1}},
+		{"1.9.1-rc.1", "1.9.1", version.Gap{Level: version.LevelPatch}},
+		{"1.7.3", "1.9.1", version.Gap{Level: version.LevelMinor, Behind: 2}},
+		{"0.16.0", "1.9.1", version.Gap{Level: version.LevelMajor, Behind: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.installed+" to "+tt.newest, func(t *testing.T) {
+			got := version.GapBetween(version.MustParse(tt.installed), version.MustParse(tt.newest))
+			if got != tt.want {
+				t.Errorf("GapBetween() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := version.GapBetween(nil, version.MustParse("1.0.0")); got.Level != version.LevelUnknown {
+		t.Errorf("expected unknown gap for unparseable version, got %s", got.Level)
+	}
+}
+
+func Test_GapMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		gap    version.Gap
+		level  version.Level
+		behind int
+		want   bool
+	}{
+		{"one minor behind", version.Gap{Level: version.LevelMinor, Behind: 1}, version.LevelMinor, 2, false},
+		{"two minor behind", version.Gap{Level: version.LevelMinor, Behind: 2}, version.LevelMinor, 2, true},
+		{"major behind", version.Gap{Level: version.LevelMajor, Behind: 1}, version.LevelMinor, 2, true},
+		{"patch behind", version.Gap{Level: version.LevelPatch, Behind: 5}, version.LevelMinor, 0, false},
+		{"unknown", version.Gap{Level: version.LevelUnknown}, version.LevelPatch, 0, false},
+		{"everything", version.Gap{Level: version.LevelNone}, version.LevelNone, 0, true},
+		{"only unknown", version.Gap{Level: version.LevelMajor, Behind: 1}, version.LevelUnknown, 0, false},
+		{"unknown queried", version.Gap{Level: version.LevelUnknown}, version.LevelUnknown, 0, true},
+		{"invalid level", version.Gap{Level: version.LevelMajor, Behind: 1}, version.Level("huge"), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.gap.Matches(tt.level, tt.behind); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Newest(t *testing.T) {
+	versions := []*version.Version{
+		version.MustParse("1.8.0"),
+		version.MustParse("1.10.0-rc.1"),
+		version.MustParse("v1.9.1"),
+		nil,
+	}
+	if got := version.Newest(versions); got.String() != "v1.9.1" {
+		t.Errorf("expected newest stable version v1.9.1, got %s", got)
+	}
+	if got := version.Newest(versions[1:2]); got.String() != "1.10.0-rc.1" {
+		t.Errorf("expected pre-release without stable versions, got %s", got)
+	}
+	if got := version.Newest(nil); got != nil {
+		t.Errorf("expected nil, got %s", got)
+	}
+}
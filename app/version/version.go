@@ -0,0 +1,188 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// calendarDate matches calendar versions that use dashes like 2022-10-01
+var calendarDate = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})(.*)$`)
+
+// prereleasePrefixes are the suffixes that mark a pre-release, other suffixes like
+// the -alpine of an image tag are treated as variants of the same release
+var prereleasePrefixes = []string{"alpha", "beta", "rc", "pre", "preview", "dev", "snapshot", "canary", "nightly"}
+
+// Version is a parsed application version
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+
+	// Prerelease is the pre-release part of a version, i.e. rc.1 in 1.2.3-rc.1
+	Prerelease string
+	// Variant is a suffix that does not change the precedence, i.e. alpine in 1.2.3-alpine
+	Variant string
+
+	// Original is the string the version was parsed from
+	Original string
+
+	// extra holds numeric segments after the patch version, i.e. 4 in 1.2.3.4
+	extra []int
+}
+
+// Parse parses SemVer versions and the common variations found in the wild
+//
+// Besides plain SemVer it accepts a v prefix, missing minor and patch
+// versions, additional numeric segments, calendar versions (2022.10.01 or
+// 2022-10-01) and image tags with variant suffixes (1.2.3-alpine).
+func Parse(s string) (*Version, error) {
+	v := &Version{Original: s}
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if match := calendarDate.FindStringSubmatch(s); match != nil {
+		s = match[1] + "." + match[2] + "." + match[3] + match[4]
+	}
+
+	// build metadata never affects precedence
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+
+	core, suffix := s, ""
+	if i := strings.IndexAny(s, "-_"); i >= 0 {
+		core, suffix = s[:i], s[i+1:]
+	}
+
+	segments := strings.Split(core, ".")
+	numbers := make([]int, 0, len(segments))
+	for _, segment := range segments {
+		number, err := strconv.Atoi(segment)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid version %q", v.Original)
+		}
+		numbers = append(numbers, number)
+	}
+	numbers = append(numbers, 0, 0)
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+	if len(segments) > 3 {
+		v.extra = numbers[3:len(segments)]
+	}
+
+	if suffix != "" {
+		if isPrerelease(suffix) {
+			v.Prerelease = suffix
+		} else {
+			v.Variant = suffix
+		}
+	}
+
+	return v, nil
+}
+
+// MustParse is like Parse but panics on invalid versions, it simplifies static versions in tests
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String returns the version the way it was originally written
+func (v *Version) String() string {
+	return v.Original
+}
+
+// IsPrerelease reports whether the version is a pre-release
+func (v *Version) IsPrerelease() bool {
+	return v.Prerelease != ""
+}
+
+// Compare returns -1, 0 or 1 if a is lower, equal or higher than b
+func Compare(a, b *Version) int {
+	if c := compareInts([]int{a.Major, a.Minor, a.Patch}, []int{b.Major, b.Minor, b.Patch}); c != 0 {
+		return c
+	}
+	if c := compareInts(a.extra, b.extra); c != 0 {
+		return c
+	}
+
+	// a release has a higher precedence than its pre-releases
+	switch {
+	case a.Prerelease == b.Prerelease:
+		return 0
+	case a.Prerelease == "":
+		return 1
+	case b.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(a.Prerelease, b.Prerelease)
+}
+
+// CompareStrings compares two version strings, versions that can't be parsed are compared as strings
+func CompareStrings(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return Compare(va, vb)
+}
+
+func isPrerelease(suffix string) bool {
+	suffix = strings.ToLower(suffix)
+	for _, prefix := range prereleasePrefixes {
+		if strings.HasPrefix(suffix, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareInts compares two lists of numbers where missing numbers count as 0
+func compareInts(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// comparePrerelease compares pre-releases by their dot separated identifiers as defined by SemVer
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.Atoi(as[i])
+		y, errY := strconv.Atoi(bs[i])
+		switch {
+		case errX == nil && errY == nil:
+			if x != y {
+				return compareInts([]int{x}, []int{y})
+			}
+		case errX == nil:
+			// numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts([]int{len(as)}, []int{len(bs)})
+}
@@ -0,0 +1,85 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/adfinis-sygroup/mopsos/app/version"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		major      int
+		minor      int
+		patch      int
+		prerelease string
+		variant    string
+		wantErr    bool
+	}{
+		{name: "semver", input: "1.2.3", major: 1, minor: 2, patch: 3},
+		{name: "v prefix", input: "v1.9.1", major: 1, minor: 9, patch: 1},
+		{name: "partial", input: "2.4", major: 2, minor: 4},
+		{name: "prerelease and metadata", input: "1.10.0-rc.1+abc", major: 1, minor: 10, prerelease: "rc.1"},
+		{name: "calendar version with dots", input: "2022.10.01", major: 2022, minor: 10, patch: 1},
+		{name: "calendar version with dashes", input: "2022-10-01", major: 2022, minor: 10, patch: 1},
+		{name: "image tag with variant", input: "1.23.1-alpine", major: 1, minor: 23, patch: 1, variant: "alpine"},
+		{name: "bitnami image tag", input: "11.7.0-debian-11-r3", major: 11, minor: 7, variant: "debian-11-r3"},
+		{name: "four segments", input: "1.2.3.4", major: 1, minor: 2, patch: 3},
+		{name: "not a version", input: "latest", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := version.Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if v.Major != tt.major || v.Minor != tt.minor || v.Patch != tt.patch {
+				t.Errorf("expected %d.%d.%d, got %d.%d.%d", tt.major, tt.minor, tt.patch, v.Major, v.Minor, v.Patch)
+			}
+			if v.Prerelease != tt.prerelease || v.Variant != tt.variant {
+				t.Errorf("expected prerelease %q and variant %q, got %q and %q", tt.prerelease, tt.variant, v.Prerelease, v.Variant)
+			}
+			if v.String() != tt.input {
+				t.Errorf("expected original %q, got %q", tt.input, v.String())
+			}
+		})
+	}
+}
+
+func Test_Compare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.2.3-rc.1", "1.2.3", -1},
+		{"1.2.3-alpha", "1.2.3-beta", -1},
+		{"1.2.3-rc.2", "1.2.3-rc.10", -1},
+		{"1.2.3-alpine", "1.2.3", 0},
+		{"1.2.3.4", "1.2.3", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := version.Compare(version.MustParse(tt.a), version.MustParse(tt.b)); got != tt.want {
+				t.Errorf("Compare() = %d, want %d", got, tt.want)
+			}
+			if got := version.Compare(version.MustParse(tt.b), version.MustParse(tt.a)); got != -tt.want {
+				t.Errorf("reversed Compare() = %d, want %d", got, -tt.want)
+			}
+		})
+	}
+
+	if version.CompareStrings("latest", "stable") != -1 {
+		t.Error("expected unparseable versions to be compared as strings")
+	}
+}